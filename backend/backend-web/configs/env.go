@@ -4,6 +4,9 @@ import (
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func LoadEnv() {
@@ -40,20 +43,268 @@ func EnvPort() string {
 	return port
 }
 
-func EnvSendgridAPIKey() string {
+func EnvFrontendURL() string {
 	LoadEnv()
-	sendGrid := os.Getenv("SENDGRID_API_KEY")
-	if sendGrid == "" {
-		log.Fatal("❌ SENDGRID_API_KEY is not set in the environment")
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		return "http://localhost:3000"
 	}
-	return sendGrid
+	return frontendURL
 }
 
-func EnvSendgridEmail() string {
+// EnvAPIURL is the public base URL of this API, used to build callback URLs
+func EnvAPIURL() string {
+	LoadEnv()
+	apiURL := os.Getenv("API_URL")
+	if apiURL == "" {
+		return "http://localhost:8081"
+	}
+	return strings.TrimSuffix(apiURL, "/")
+}
+
+// EnvOAuthReturnOrigins lists extra origins, besides FRONTEND_URL, that the
+// OAuth return_to parameter may point at (OAUTH_RETURN_TO_ORIGINS, comma separated)
+func EnvOAuthReturnOrigins() []string {
+	LoadEnv()
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("OAUTH_RETURN_TO_ORIGINS"), ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// envDuration reads a Go duration (e.g. "15m") and falls back to def when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	LoadEnv()
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: invalid %s %q, using %s", key, value, def)
+		return def
+	}
+	return d
+}
+
+// envInt reads a positive integer and falls back to def when unset or invalid
+func envInt(key string, def int) int {
+	LoadEnv()
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Warning: invalid %s %q, using %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// envBool reads a boolean (true/false, 1/0) and falls back to def when unset or invalid
+func envBool(key string, def bool) bool {
+	LoadEnv()
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %t", key, value, def)
+		return def
+	}
+	return b
+}
+
+// envString reads a string and falls back to def when unset
+func envString(key, def string) string {
+	LoadEnv()
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+func EnvAccessTokenTTL() time.Duration {
+	return envDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+func EnvRefreshTokenTTL() time.Duration {
+	return envDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// EnvJWTAlgorithm selects how access tokens are signed: RS256 (default),
+// ES256, or HS256 with SECRET for deployments that have not migrated yet
+func EnvJWTAlgorithm() string {
+	LoadEnv()
+	switch alg := os.Getenv("JWT_ALGORITHM"); alg {
+	case "":
+		return "RS256"
+	case "RS256", "ES256", "HS256":
+		return alg
+	default:
+		log.Printf("Warning: unsupported JWT_ALGORITHM %q, using RS256", alg)
+		return "RS256"
+	}
+}
+
+// EnvSigningKeyKEK is the key-encryption key that protects the stored signing
+// keys and TOTP secrets (SIGNING_KEY_KEK). It falls back to SECRET; changing
+// it makes them unreadable, so a new signing key is created and users with
+// 2FA must sign in with a recovery code and enroll again.
+func EnvSigningKeyKEK() string {
 	LoadEnv()
-	emailSendGrid := os.Getenv("EMAIL_SENDGRID")
-	if emailSendGrid == "" {
-		log.Fatal("❌ EMAIL_SENDGRID is not set in the environment")
+	if kek := os.Getenv("SIGNING_KEY_KEK"); kek != "" {
+		return kek
 	}
-	return emailSendGrid
+	return EnvSecret()
+}
+
+// EnvAccountDeletionGrace is how long a deleted account can still be restored
+// before its data is purged
+func EnvAccountDeletionGrace() time.Duration {
+	return envDuration("ACCOUNT_DELETION_GRACE", 14*24*time.Hour)
+}
+
+// EnvAuditRetention is how long security audit events are kept
+func EnvAuditRetention() time.Duration {
+	return envDuration("AUDIT_RETENTION", 365*24*time.Hour)
+}
+
+// EnvWebhookDeliveryRetention is how long webhook delivery logs are kept
+func EnvWebhookDeliveryRetention() time.Duration {
+	return envDuration("WEBHOOK_DELIVERY_RETENTION", 30*24*time.Hour)
+}
+
+func EnvSigningKeyRotation() time.Duration {
+	return envDuration("JWT_KEY_ROTATION", 30*24*time.Hour)
+}
+
+// Password policy. The defaults match the rules shown by the frontend.
+func EnvPasswordMinLength() int {
+	return envInt("PASSWORD_MIN_LENGTH", 8)
+}
+
+func EnvPasswordRequireUpper() bool {
+	return envBool("PASSWORD_REQUIRE_UPPER", true)
+}
+
+func EnvPasswordRequireLower() bool {
+	return envBool("PASSWORD_REQUIRE_LOWER", true)
+}
+
+func EnvPasswordRequireDigit() bool {
+	return envBool("PASSWORD_REQUIRE_DIGIT", true)
+}
+
+func EnvPasswordRequireSymbol() bool {
+	return envBool("PASSWORD_REQUIRE_SYMBOL", false)
+}
+
+// EnvBreachedPasswordsDir is the directory of k-anonymity range files used to
+// reject breached passwords. Set it to "off" to disable the check.
+func EnvBreachedPasswordsDir() string {
+	LoadEnv()
+	dir := os.Getenv("BREACHED_PASSWORDS_DIR")
+	if dir == "" {
+		return "breached-passwords"
+	}
+	if dir == "off" {
+		return ""
+	}
+	return dir
+}
+
+// EnvAuthUniformResponses makes registration and password/verification code
+// requests answer the same way whether or not the email has an account
+func EnvAuthUniformResponses() bool {
+	return envBool("AUTH_UNIFORM_RESPONSES", false)
+}
+
+// EnvEmailDefaultLocale is the email language for users who have not picked
+// one and requests without a usable Accept-Language header
+func EnvEmailDefaultLocale() string {
+	return envString("EMAIL_DEFAULT_LOCALE", "en")
+}
+
+// Branding variables available to every email template
+func EnvEmailBrandName() string {
+	return envString("EMAIL_BRAND_NAME", "Kale Project")
+}
+
+func EnvEmailBrandLogoURL() string {
+	return envString("EMAIL_BRAND_LOGO_URL", "")
+}
+
+func EnvEmailBrandColor() string {
+	return envString("EMAIL_BRAND_COLOR", "#2f855a")
+}
+
+func EnvEmailSupportAddress() string {
+	return envString("EMAIL_SUPPORT_ADDRESS", "")
+}
+
+// EnvEmailPreview enables the email template preview routes. Keep it off in
+// production.
+func EnvEmailPreview() bool {
+	return envBool("EMAIL_PREVIEW", false)
+}
+
+// EnvMailTransport selects how queued emails are delivered: sendgrid, smtp,
+// file or console. When unset it is sendgrid if SENDGRID_API_KEY is set and
+// "" otherwise. file and console write codes and links to disk or the log,
+// so they are only used when chosen explicitly.
+func EnvMailTransport() string {
+	LoadEnv()
+	transport := strings.ToLower(os.Getenv("MAIL_TRANSPORT"))
+	if transport == "" && os.Getenv("SENDGRID_API_KEY") != "" {
+		return "sendgrid"
+	}
+	return transport
+}
+
+// EnvMailFrom is the sender address of every email. EMAIL_SENDGRID is still
+// read for existing deployments.
+func EnvMailFrom() string {
+	return envString("MAIL_FROM", EnvSendgridEmail())
+}
+
+// EnvMailMaxAttempts is how many times an email is tried before it is marked failed
+func EnvMailMaxAttempts() int {
+	return envInt("MAIL_MAX_ATTEMPTS", 6)
+}
+
+// EnvMailFileDir is where the file transport writes .eml files
+func EnvMailFileDir() string {
+	return envString("MAIL_FILE_DIR", "mail-outbox")
+}
+
+func EnvSMTPHost() string {
+	return envString("SMTP_HOST", "")
+}
+
+func EnvSMTPPort() int {
+	return envInt("SMTP_PORT", 587)
+}
+
+func EnvSMTPUsername() string {
+	return envString("SMTP_USERNAME", "")
+}
+
+func EnvSMTPPassword() string {
+	return envString("SMTP_PASSWORD", "")
+}
+
+// EnvSendgridAPIKey is only required by the sendgrid mail transport
+func EnvSendgridAPIKey() string {
+	return envString("SENDGRID_API_KEY", "")
+}
+
+func EnvSendgridEmail() string {
+	return envString("EMAIL_SENDGRID", "")
 }
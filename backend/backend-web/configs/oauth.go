@@ -3,40 +3,103 @@ package configs
 import (
 	"log"
 	"os"
+	"strings"
+)
 
-	"github.com/joho/godotenv"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+// OAuth provider types. OIDC providers are configured through discovery from
+// their issuer; GitHub has no OpenID Connect support and is handled separately.
+const (
+	OAuthTypeOIDC   = "oidc"
+	OAuthTypeGitHub = "github"
 )
 
-var OAuthConfig *oauth2.Config
+// OAuthProviderSettings is one login provider read from the environment
+type OAuthProviderSettings struct {
+	Name         string
+	Type         string
+	ClientID     string
+	ClientSecret string
+	Issuer       string
+	RedirectURL  string
+	Scopes       []string
+	// TrustEmail accepts the provider's email when it sends no email_verified
+	// claim. Microsoft Entra, for one, never sends it.
+	TrustEmail bool
+}
+
+var OAuthProviders = map[string]OAuthProviderSettings{}
 
+// InitOAuth loads the login providers listed in OAUTH_PROVIDERS. Each
+// provider NAME is configured with OAUTH_<NAME>_CLIENT_ID, _CLIENT_SECRET,
+// _ISSUER (OIDC only), _REDIRECT_URI, _SCOPES, _TYPE and _TRUST_EMAIL.
+// The older CLIENT_ID, CLIENT_SECRET and REDIRECT_URI variables still
+// configure Google when it is not listed.
 func InitOAuth() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
+	LoadEnv()
 
-	clientID := os.Getenv("CLIENT_ID")
-	clientSecret := os.Getenv("CLIENT_SECRET")
-	redirectURI := os.Getenv("REDIRECT_URI")
+	for _, name := range strings.Split(os.Getenv("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if settings, ok := oauthProviderFromEnv(name); ok {
+			OAuthProviders[name] = settings
+		}
+	}
 
-	if clientID == "" || clientSecret == "" || redirectURI == "" {
-		log.Fatal("Missing CLIENT_ID, CLIENT_SECRET or REDIRECT_URI")
+	if _, ok := OAuthProviders["google"]; !ok && os.Getenv("CLIENT_ID") != "" {
+		OAuthProviders["google"] = OAuthProviderSettings{
+			Name:         "google",
+			Type:         OAuthTypeOIDC,
+			ClientID:     os.Getenv("CLIENT_ID"),
+			ClientSecret: os.Getenv("CLIENT_SECRET"),
+			Issuer:       "https://accounts.google.com",
+			RedirectURL:  os.Getenv("REDIRECT_URI"),
+			Scopes:       []string{"openid", "email", "profile"},
+		}
 	}
 
-	OAuthConfig = &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		RedirectURL:  redirectURI,
-		Scopes:       []string{"email", "profile"},
-		Endpoint:     google.Endpoint,
+	if len(OAuthProviders) == 0 {
+		log.Println("Warning: no OAuth providers configured, third-party login is disabled")
 	}
 }
 
-func GoogleOAuthConfig() *oauth2.Config {
-	if OAuthConfig == nil {
-		log.Fatal("OAuth config is not initialized. Call InitOAuth() first.")
+func oauthProviderFromEnv(name string) (OAuthProviderSettings, bool) {
+	prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+	settings := OAuthProviderSettings{
+		Name:         name,
+		Type:         strings.ToLower(os.Getenv(prefix + "TYPE")),
+		ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+		ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+		Issuer:       strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+		RedirectURL:  os.Getenv(prefix + "REDIRECT_URI"),
+		TrustEmail:   envBool(prefix+"TRUST_EMAIL", false),
+	}
+	if settings.Type == "" {
+		settings.Type = OAuthTypeOIDC
+		if name == "github" {
+			settings.Type = OAuthTypeGitHub
+		}
+	}
+	if settings.Type != OAuthTypeOIDC && settings.Type != OAuthTypeGitHub {
+		log.Printf("Warning: OAuth provider %s has unsupported type %q, skipping it", name, settings.Type)
+		return settings, false
 	}
-	return OAuthConfig
-}
\ No newline at end of file
+	if settings.RedirectURL == "" {
+		settings.RedirectURL = EnvAPIURL() + "/api/auth/callback/" + name
+	}
+
+	if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+		settings.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+	} else if settings.Type == OAuthTypeGitHub {
+		settings.Scopes = []string{"read:user", "user:email"}
+	} else {
+		settings.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	if settings.ClientID == "" || (settings.Type == OAuthTypeOIDC && settings.Issuer == "") {
+		log.Printf("Warning: OAuth provider %s is missing its client ID or issuer, skipping it", name)
+		return settings, false
+	}
+	return settings, true
+}
@@ -18,7 +18,8 @@ func ConnectDB() *mongo.Client {
 		log.Fatal(err)
 	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	err = client.Connect(ctx)
 	if err != nil {
 		log.Fatal(err)
@@ -32,7 +33,9 @@ func ConnectDB() *mongo.Client {
 	return client
 }
 
-var DB *mongo.Client = ConnectDB()
+// DB is the shared database client. main sets it from ConnectDB before any
+// route or worker starts; tests can assign their own client instead.
+var DB *mongo.Client
 
 func GetCollection(client *mongo.Client, collectionName string) *mongo.Collection {
 	collection := client.Database("KaleAPI").Collection(collectionName)
@@ -67,26 +70,39 @@ func InitUserIndexes() {
 	}
 }
 
-func InitPasswordResetIndexes() {
-	collection := GetCollection(DB, "password_resets")
-
-	// Drop existing TTL index if any
-	_, err := collection.Indexes().DropOne(context.TODO(), "expiresAt_1")
-	if err != nil && err != mongo.ErrNoDocuments {
-		log.Println("⚠️ Error dropping existing TTL index:", err)
-	}
-
-	// Create TTL index on expiresAt
-	indexModel := mongo.IndexModel{
-		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
-		Options: options.Index().SetExpireAfterSeconds(0),
+func InitOTPIndexes() {
+	collection := GetCollection(DB, "otp_codes")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "purpose", Value: 1}, {Key: "email", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for otp_codes:", err)
+	} else {
+		log.Println("✅ Indexes created for otp_codes")
 	}
+}
 
-	_, err = collection.Indexes().CreateOne(context.TODO(), indexModel)
+func InitPasswordResetTokenIndexes() {
+	collection := GetCollection(DB, "password_reset_tokens")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
 	if err != nil {
-		log.Println("⚠️ Failed to create TTL index for password_resets:", err)
+		log.Println("⚠️ Failed to create indexes for password_reset_tokens:", err)
 	} else {
-		log.Println("✅ TTL index created on 'expiresAt' for password_resets")
+		log.Println("✅ Indexes created for password_reset_tokens")
 	}
 }
 
@@ -105,8 +121,354 @@ func InitPredictionHistoryIndexes() {
     }
 }
 
+func InitWebhookIndexes() {
+	subscriptions := GetCollection(DB, "webhook_subscriptions")
+	_, err := subscriptions.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "events", Value: 1}},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create index for webhook_subscriptions:", err)
+	}
+
+	deliveries := GetCollection(DB, "webhook_deliveries")
+	_, err = deliveries.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "subscription_id", Value: 1}, {Key: "createdAt", Value: -1}}},
+		// Retention: payloads and responses are dropped after WEBHOOK_DELIVERY_RETENTION
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for webhook_deliveries:", err)
+	} else {
+		log.Println("✅ Indexes created for webhook_deliveries")
+	}
+}
+
+func InitOrganizationIndexes() {
+	members := GetCollection(DB, "organization_members")
+	_, err := members.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for organization_members:", err)
+	}
+
+	invitations := GetCollection(DB, "organization_invitations")
+	_, err = invitations.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for organization_invitations:", err)
+	}
+
+	history := GetCollection(DB, "prediction_history")
+	_, err = history.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "timestamp", Value: -1}},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create index on org_id:", err)
+	} else {
+		log.Println("✅ Indexes created for organizations")
+	}
+}
+
+func InitAuditEventIndexes() {
+	collection := GetCollection(DB, "audit_events")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "target_user_id", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		// Retention: each event carries its own expiry so AUDIT_RETENTION can change
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for audit_events:", err)
+	} else {
+		log.Println("✅ Indexes created for audit_events")
+	}
+}
+
+func InitRefreshTokenIndexes() {
+	collection := GetCollection(DB, "refresh_tokens")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for refresh_tokens:", err)
+	} else {
+		log.Println("✅ Indexes created for refresh_tokens")
+	}
+}
+
+func InitSessionIndexes() {
+	collection := GetCollection(DB, "sessions")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "lastSeenAt", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for sessions:", err)
+	} else {
+		log.Println("✅ Indexes created for sessions")
+	}
+}
+
+func InitBlacklistIndexes() {
+	collection := GetCollection(DB, "blacklisted_tokens")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "expired_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for blacklisted_tokens:", err)
+	} else {
+		log.Println("✅ Indexes created for blacklisted_tokens")
+	}
+}
+
+func InitSigningKeyIndexes() {
+	collection := GetCollection(DB, "signing_keys")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "kid", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for signing_keys:", err)
+	} else {
+		log.Println("✅ Indexes created for signing_keys")
+	}
+}
+
+func InitMFAChallengeIndexes() {
+	collection := GetCollection(DB, "mfa_challenges")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for mfa_challenges:", err)
+	} else {
+		log.Println("✅ Indexes created for mfa_challenges")
+	}
+}
+
+func InitAuthAttemptIndexes() {
+	collection := GetCollection(DB, "auth_attempts")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for auth_attempts:", err)
+	} else {
+		log.Println("✅ Indexes created for auth_attempts")
+	}
+}
+
+func InitOAuthFlowIndexes() {
+	collection := GetCollection(DB, "oauth_flows")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "state_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for oauth_flows:", err)
+	} else {
+		log.Println("✅ Indexes created for oauth_flows")
+	}
+}
+
+func InitIdentityIndexes() {
+	identities := GetCollection(DB, "user_identities")
+	_, err := identities.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for user_identities:", err)
+	}
+
+	requests := GetCollection(DB, "identity_link_requests")
+	_, err = requests.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for identity_link_requests:", err)
+	}
+
+	// Fails, with a warning, while older OAuth accounts still share a username
+	users := GetCollection(DB, "users")
+	_, err = users.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create unique index on username:", err)
+	} else {
+		log.Println("✅ Indexes created for user identities")
+	}
+}
+
+func InitMagicLinkIndexes() {
+	collection := GetCollection(DB, "magic_links")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for magic_links:", err)
+	} else {
+		log.Println("✅ Indexes created for magic_links")
+	}
+}
+
+func InitAPIKeyIndexes() {
+	collection := GetCollection(DB, "api_keys")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for api_keys:", err)
+	} else {
+		log.Println("✅ Indexes created for api_keys")
+	}
+}
+
+func InitDataExportIndexes() {
+	collection := GetCollection(DB, "data_exports")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for data_exports:", err)
+	} else {
+		log.Println("✅ Indexes created for data_exports")
+	}
+
+	users := GetCollection(DB, "users")
+	_, err = users.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "deletionScheduledAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create index on deletionScheduledAt:", err)
+	}
+}
+
+func InitEmailOutboxIndexes() {
+	collection := GetCollection(DB, "email_outbox")
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "to", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{
+			// Queued emails expire after a day, finished ones after the retention period
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Failed to create indexes for email_outbox:", err)
+	} else {
+		log.Println("✅ Indexes created for email_outbox")
+	}
+}
+
 func InitIndexes() {
-	InitPasswordResetIndexes()
+	InitOTPIndexes()
+	InitPasswordResetTokenIndexes()
 	InitUserIndexes()
 	InitPredictionHistoryIndexes()
+	InitWebhookIndexes()
+	InitOrganizationIndexes()
+	InitAuditEventIndexes()
+	InitRefreshTokenIndexes()
+	InitSessionIndexes()
+	InitBlacklistIndexes()
+	InitSigningKeyIndexes()
+	InitMFAChallengeIndexes()
+	InitAuthAttemptIndexes()
+	InitOAuthFlowIndexes()
+	InitIdentityIndexes()
+	InitMagicLinkIndexes()
+	InitAPIKeyIndexes()
+	InitDataExportIndexes()
+	InitEmailOutboxIndexes()
 }
\ No newline at end of file
@@ -0,0 +1,601 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// adminUserView is the account summary shown in the admin console
+func adminUserView(user models.User) fiber.Map {
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	return fiber.Map{
+		"user_id":           user.Id.Hex(),
+		"username":          user.Username,
+		"email":             user.Email,
+		"role":              role,
+		"permissions":       user.Permissions,
+		"emailVerified":     user.EmailVerified,
+		"disabled":          user.Disabled,
+		"disabledAt":        user.DisabledAt,
+		"disabledReason":    user.DisabledReason,
+		"mustResetPassword": user.MustResetPassword,
+		"twoFactorEnabled":  user.TwoFactorEnabled,
+		"locale":            user.Locale,
+		"hasPassword":       user.Password != "",
+		"createdAt":         user.CreatedAt,
+	}
+}
+
+// loadAdminTarget loads the user from the :id route parameter. On failure the
+// error response has already been written and the returned user is nil.
+func loadAdminTarget(c *fiber.Ctx) (*models.User, error) {
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID",
+		})
+	}
+
+	collection := configs.GetCollection(configs.DB, "users")
+	var user models.User
+	err = collection.FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "User not found",
+			})
+		}
+		log.Println("Error fetching user:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch user",
+		})
+	}
+	return &user, nil
+}
+
+// AdminListUsers lists accounts with optional search and pagination
+func AdminListUsers(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := bson.M{}
+	if search := c.Query("search"); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		filter["$or"] = []bson.M{{"username": pattern}, {"email": pattern}}
+	}
+	switch c.Query("status") {
+	case "disabled":
+		filter["disabled"] = true
+	case "active":
+		filter["disabled"] = bson.M{"$ne": true}
+	case "unverified":
+		filter["emailVerified"] = false
+	}
+
+	collection := configs.GetCollection(configs.DB, "users")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Printf("Error: Failed to count users - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve users",
+		})
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error: Failed to query users - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve users",
+		})
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		log.Printf("Error: Failed to decode users - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve users",
+		})
+	}
+
+	result := make([]fiber.Map, 0, len(users))
+	for _, u := range users {
+		result = append(result, adminUserView(u))
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Users retrieved successfully",
+		"data": fiber.Map{
+			"users": result,
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// AdminGetUser returns an account's verification state and usage
+func AdminGetUser(c *fiber.Ctx) error {
+	user, err := loadAdminTarget(c)
+	if user == nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userID := user.Id.Hex()
+
+	history := configs.GetCollection(configs.DB, "prediction_history")
+	predictionCount, err := history.CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		log.Printf("Error: Failed to count predictions - %v", err)
+	}
+
+	var lastPrediction models.PredictionHistory
+	var lastPredictionAt interface{}
+	err = history.FindOne(ctx, bson.M{"user_id": userID},
+		options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}})).Decode(&lastPrediction)
+	if err == nil {
+		lastPredictionAt = lastPrediction.Timestamp
+	}
+
+	// Storage used by the user's GridFS files (avatar and prediction images)
+	var storage struct {
+		Files int64 `bson:"files"`
+		Bytes int64 `bson:"bytes"`
+	}
+	fsFiles := configs.GetCollection(configs.DB, "fs.files")
+	cursor, err := fsFiles.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"metadata.user_id": bson.M{"$in": bson.A{user.Id, userID}}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "files": bson.M{"$sum": 1}, "bytes": bson.M{"$sum": "$length"}}}},
+	})
+	if err == nil {
+		if cursor.Next(ctx) {
+			cursor.Decode(&storage)
+		}
+		cursor.Close(ctx)
+	} else {
+		log.Printf("Error: Failed to aggregate storage usage - %v", err)
+	}
+
+	orgIDs, err := utils.UserOrgIDs(userID, models.OrgRoleViewer)
+	if err != nil {
+		log.Printf("Error: Failed to query memberships - %v", err)
+	}
+
+	view := adminUserView(*user)
+	view["usage"] = fiber.Map{
+		"predictions":      predictionCount,
+		"lastPredictionAt": lastPredictionAt,
+		"storageFiles":     storage.Files,
+		"storageBytes":     storage.Bytes,
+		"organizations":    len(orgIDs),
+	}
+	view["verificationPending"] = !user.EmailVerified && !user.ExpiresAt.IsZero()
+	view["lastVerificationSent"] = user.LastVerificationSent
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "User found",
+		"data":    view,
+	})
+}
+
+// AdminSetUserStatus disables or re-enables an account
+func AdminSetUserStatus(c *fiber.Ctx) error {
+	adminClaims := c.Locals("user").(*models.Claims)
+
+	user, err := loadAdminTarget(c)
+	if user == nil {
+		return err
+	}
+
+	var input struct {
+		Disabled *bool  `json:"disabled"`
+		Reason   string `json:"reason"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Disabled == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "disabled (true or false) is required",
+		})
+	}
+	if *input.Disabled && user.Id.Hex() == adminClaims.UserID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You cannot disable your own account",
+		})
+	}
+
+	update := bson.M{"$unset": bson.M{"disabled": "", "disabledAt": "", "disabledReason": ""}}
+	action := "user.enable"
+	if *input.Disabled {
+		update = bson.M{"$set": bson.M{
+			"disabled":       true,
+			"disabledAt":     time.Now(),
+			"disabledReason": input.Reason,
+		}}
+		action = "user.disable"
+	}
+
+	collection := configs.GetCollection(configs.DB, "users")
+	if _, err := collection.UpdateOne(context.TODO(), bson.M{"_id": user.Id}, update); err != nil {
+		log.Println("Error updating user status:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update user status",
+		})
+	}
+
+	if *input.Disabled {
+		if err := utils.RevokeUserSessions(user.Id.Hex(), ""); err != nil {
+			log.Println("Error revoking sessions:", err)
+		}
+	}
+
+	utils.RecordAdminAction(c, adminClaims.UserID, action, user.Id.Hex(), map[string]string{"reason": input.Reason})
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "User status updated",
+		"data":    fiber.Map{"user_id": user.Id.Hex(), "disabled": *input.Disabled},
+	})
+}
+
+// AdminForcePasswordReset blocks password login until the user resets their
+// password and emails them a reset OTP
+func AdminForcePasswordReset(c *fiber.Ctx) error {
+	adminClaims := c.Locals("user").(*models.Claims)
+
+	user, err := loadAdminTarget(c)
+	if user == nil {
+		return err
+	}
+
+	collection := configs.GetCollection(configs.DB, "users")
+	_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": user.Id}, bson.M{"$set": bson.M{"mustResetPassword": true}})
+	if err != nil {
+		log.Println("Error flagging password reset:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to force password reset",
+		})
+	}
+
+	if err := utils.RevokeUserSessions(user.Id.Hex(), ""); err != nil {
+		log.Println("Error revoking sessions:", err)
+	}
+
+	// Clear pending codes first so the user cooldown does not block the admin
+	if err := utils.DeleteOTPs(utils.OTPPurposeResetPassword, user.Email); err != nil {
+		log.Println("Error deleting old OTPs:", err)
+	}
+	otp, err := utils.IssueOTP(utils.OTPPurposeResetPassword, user.Email)
+	if err != nil {
+		log.Println("Error storing reset token:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to force password reset",
+		})
+	}
+
+	emailSent := true
+	if err := utils.SendPasswordResetOTPEmail(user.Email, user.Locale, otp); err != nil {
+		log.Println("Error sending reset email:", err)
+		emailSent = false
+	}
+
+	utils.RecordAdminAction(c, adminClaims.UserID, "user.force_password_reset", user.Id.Hex(), nil)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Password reset required for user",
+		"data":    fiber.Map{"user_id": user.Id.Hex(), "emailSent": emailSent},
+	})
+}
+
+// AdminResendVerification sends a fresh verification code to an unverified account
+func AdminResendVerification(c *fiber.Ctx) error {
+	adminClaims := c.Locals("user").(*models.Claims)
+
+	user, err := loadAdminTarget(c)
+	if user == nil {
+		return err
+	}
+	if user.EmailVerified {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Email already verified",
+		})
+	}
+
+	if err := utils.DeleteOTPs(utils.OTPPurposeVerifyEmail, user.Email); err != nil {
+		log.Println("Error deleting old OTPs:", err)
+	}
+	newCode, err := utils.IssueOTP(utils.OTPPurposeVerifyEmail, user.Email)
+	if err != nil {
+		log.Println("Error issuing verification code:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update verification code",
+		})
+	}
+
+	now := time.Now()
+	collection := configs.GetCollection(configs.DB, "users")
+	_, err = collection.UpdateOne(context.TODO(),
+		bson.M{"_id": user.Id},
+		bson.M{"$set": bson.M{
+			"lastVerificationSent": now,
+			"expiresAt":            now.Add(utils.OTPTTL),
+		}},
+	)
+	if err != nil {
+		log.Println("Error updating verification code:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update verification code",
+		})
+	}
+
+	if err := utils.SendVerificationEmail(user.Email, user.Locale, newCode); err != nil {
+		log.Println("Error sending verification email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to send verification email",
+		})
+	}
+
+	utils.RecordAdminAction(c, adminClaims.UserID, "user.resend_verification", user.Id.Hex(), nil)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Verification email resent successfully",
+	})
+}
+
+// AdminImpersonateUser issues a short-lived, read-mostly token to act as the
+// user for support. A reason is required and recorded in the audit log.
+func AdminImpersonateUser(c *fiber.Ctx) error {
+	adminClaims := c.Locals("user").(*models.Claims)
+
+	user, err := loadAdminTarget(c)
+	if user == nil {
+		return err
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A reason is required to impersonate a user",
+		})
+	}
+	if user.Role == models.RoleAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Admin accounts cannot be impersonated",
+		})
+	}
+	if user.Disabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Account is disabled",
+		})
+	}
+
+	// The token is bound to its own session so that disabling or purging the
+	// user also ends the impersonation
+	session, err := utils.CreateImpersonationSession(c, user.Id.Hex(), adminClaims.UserID)
+	if err != nil {
+		log.Println("Session error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+	token, err := utils.GenerateImpersonationJWT(user, adminClaims.UserID, session.ID.Hex())
+	if err != nil {
+		log.Println("JWT signing error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+
+	utils.RecordAdminAction(c, adminClaims.UserID, "user.impersonate", user.Id.Hex(), map[string]string{
+		"reason":     input.Reason,
+		"session_id": session.ID.Hex(),
+	})
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Impersonation token issued",
+		"token":   token,
+	})
+}
+
+// AdminDeleteUser permanently deletes an account with its files and history
+func AdminDeleteUser(c *fiber.Ctx) error {
+	adminClaims := c.Locals("user").(*models.Claims)
+
+	user, err := loadAdminTarget(c)
+	if user == nil {
+		return err
+	}
+	if user.Id.Hex() == adminClaims.UserID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You cannot delete your own account here",
+		})
+	}
+
+	if err := utils.PurgeUserData(user); err != nil {
+		log.Println("Error deleting user data:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to delete user",
+		})
+	}
+
+	utils.RecordAdminAction(c, adminClaims.UserID, "user.delete", user.Id.Hex(), map[string]string{"email": user.Email})
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "User deleted",
+	})
+}
+
+// AdminListAuditEvents searches the security audit log. Besides the filters
+// of utils.AuditQueryFilter it accepts actor_id and target_user_id.
+func AdminListAuditEvents(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	filter := utils.AuditQueryFilter(c)
+	if actorID := c.Query("actor_id"); actorID != "" {
+		filter["actor_id"] = actorID
+	}
+	if targetUserID := c.Query("target_user_id"); targetUserID != "" {
+		filter["target_user_id"] = targetUserID
+	}
+
+	events, total, err := utils.QueryAuditEvents(filter, page, limit)
+	if err != nil {
+		log.Printf("Error: Failed to query audit events - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve audit events",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Audit events retrieved successfully",
+		"data": fiber.Map{
+			"events": events,
+			"page":   page,
+			"limit":  limit,
+			"total":  total,
+		},
+	})
+}
+
+// AdminListEmails shows the email outbox. It accepts status and to filters;
+// message bodies are never included.
+func AdminListEmails(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	filter := bson.M{}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	if to := c.Query("to"); to != "" {
+		filter["to"] = to
+	}
+
+	emails, total, err := utils.QueryOutboxEmails(filter, page, limit)
+	if err != nil {
+		log.Printf("Error: Failed to query email outbox - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve emails",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Emails retrieved successfully",
+		"data": fiber.Map{
+			"emails": emails,
+			"page":   page,
+			"limit":  limit,
+			"total":  total,
+		},
+	})
+}
+
+// AdminRetryEmail attempts a pending email now instead of after its backoff
+func AdminRetryEmail(c *fiber.Ctx) error {
+	adminClaims := c.Locals("user").(*models.Claims)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid email ID",
+		})
+	}
+
+	if err := utils.RetryOutboxEmail(id); err != nil {
+		if errors.Is(err, utils.ErrOutboxEmailNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "No pending email found with this ID",
+			})
+		}
+		log.Printf("Error: Failed to retry email %s - %v", id.Hex(), err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retry email",
+		})
+	}
+
+	utils.RecordAdminAction(c, adminClaims.UserID, "email.retry", "", map[string]string{"email_id": id.Hex()})
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Email will be attempted now",
+	})
+}
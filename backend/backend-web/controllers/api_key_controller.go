@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// createAPIKey parses a create request and stores a key owned by the caller,
+// scoped to orgID when it is set
+func createAPIKey(c *fiber.Ctx, userClaims *models.Claims, orgID string) error {
+	var input struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expiresInDays"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+		})
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Name is required and must be at most 100 characters",
+		})
+	}
+
+	ttl := utils.APIKeyDefaultTTL
+	if input.ExpiresInDays != 0 {
+		ttl = time.Duration(input.ExpiresInDays) * 24 * time.Hour
+		if input.ExpiresInDays < 0 || ttl > utils.APIKeyMaxTTL {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "expiresInDays must be between 1 and 365",
+			})
+		}
+	}
+
+	userID, _ := primitive.ObjectIDFromHex(userClaims.UserID)
+	var user models.User
+	if err := configs.GetCollection(configs.DB, "users").FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&user); err != nil {
+		log.Println("Error loading user:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create API key",
+		})
+	}
+
+	raw, key, err := utils.CreateAPIKey(&user, orgID, input.Name, input.Scopes, ttl)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidAPIKeyScope) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Scopes must be a non-empty list of supported scopes that your account holds",
+				"data":    models.APIKeyScopes,
+			})
+		}
+		log.Println("Error creating API key:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create API key",
+		})
+	}
+
+	auditDetails := map[string]string{"api_key_id": key.ID.Hex(), "name": key.Name}
+	if orgID != "" {
+		auditDetails["org_id"] = orgID
+	}
+	utils.RecordAuditEvent(c, models.AuditAPIKeyCreated, userClaims.UserID, userClaims.UserID, models.AuditResultSuccess, auditDetails)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "API key created. Copy it now, it will not be shown again.",
+		"data": fiber.Map{
+			"key":    raw,
+			"apiKey": key,
+		},
+	})
+}
+
+// listAPIKeys returns the unrevoked keys matching filter, newest first
+func listAPIKeys(c *fiber.Ctx, filter bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter["revokedAt"] = bson.M{"$exists": false}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := configs.GetCollection(configs.DB, "api_keys").Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error: Failed to query API keys - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve API keys",
+		})
+	}
+	defer cursor.Close(ctx)
+
+	result := []models.APIKey{}
+	if err := cursor.All(ctx, &result); err != nil {
+		log.Printf("Error: Failed to decode API keys - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve API keys",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "API keys retrieved successfully",
+		"data":    result,
+	})
+}
+
+// revokeAPIKey marks the key from the given route parameter as revoked when it
+// also matches filter
+func revokeAPIKey(c *fiber.Ctx, userClaims *models.Claims, param string, filter bson.M) error {
+	keyID, err := primitive.ObjectIDFromHex(c.Params(param))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid API key ID",
+		})
+	}
+
+	filter["_id"] = keyID
+	filter["revokedAt"] = bson.M{"$exists": false}
+	result, err := configs.GetCollection(configs.DB, "api_keys").UpdateOne(context.TODO(), filter, bson.M{
+		"$set": bson.M{"revokedAt": time.Now()},
+	})
+	if err != nil {
+		log.Println("Error revoking API key:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to revoke API key",
+		})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "API key not found",
+		})
+	}
+
+	auditDetails := map[string]string{"api_key_id": keyID.Hex()}
+	if orgID, ok := filter["org_id"].(string); ok {
+		auditDetails["org_id"] = orgID
+	}
+	utils.RecordAuditEvent(c, models.AuditAPIKeyRevoked, userClaims.UserID, userClaims.UserID, models.AuditResultSuccess, auditDetails)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "API key revoked",
+	})
+}
+
+// CreateAPIKey creates a personal API key for the authenticated user
+func CreateAPIKey(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+	return createAPIKey(c, userClaims, "")
+}
+
+// GetAPIKeys lists the authenticated user's personal API keys
+func GetAPIKeys(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+	return listAPIKeys(c, bson.M{"user_id": userClaims.UserID, "org_id": bson.M{"$exists": false}})
+}
+
+// RevokeAPIKey revokes one of the authenticated user's personal API keys
+func RevokeAPIKey(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+	return revokeAPIKey(c, userClaims, "id", bson.M{"user_id": userClaims.UserID, "org_id": bson.M{"$exists": false}})
+}
+
+// CreateOrgAPIKey creates an API key that acts in the organization. Only
+// owners and admins may create one.
+func CreateOrgAPIKey(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	caller, err := requireOrgRole(c, userClaims.UserID, models.OrgRoleAdmin)
+	if caller == nil {
+		return err
+	}
+	return createAPIKey(c, userClaims, caller.OrgID.Hex())
+}
+
+// GetOrgAPIKeys lists the organization's API keys
+func GetOrgAPIKeys(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	caller, err := requireOrgRole(c, userClaims.UserID, models.OrgRoleAdmin)
+	if caller == nil {
+		return err
+	}
+	return listAPIKeys(c, bson.M{"org_id": caller.OrgID.Hex()})
+}
+
+// RevokeOrgAPIKey revokes one of the organization's API keys
+func RevokeOrgAPIKey(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	caller, err := requireOrgRole(c, userClaims.UserID, models.OrgRoleAdmin)
+	if caller == nil {
+		return err
+	}
+	return revokeAPIKey(c, userClaims, "keyId", bson.M{"org_id": caller.OrgID.Hex()})
+}
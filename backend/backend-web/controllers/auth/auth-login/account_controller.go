@@ -0,0 +1,353 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// dataExportView adds the download page link to finished exports
+func dataExportView(export models.DataExport) fiber.Map {
+	view := fiber.Map{
+		"id":        export.ID.Hex(),
+		"status":    export.Status,
+		"createdAt": export.CreatedAt,
+	}
+	if !export.CompletedAt.IsZero() {
+		view["completedAt"] = export.CompletedAt
+		view["expiresAt"] = export.ExpiresAt
+	}
+	if export.Status == models.DataExportReady {
+		view["size"] = export.Size
+		view["downloadUrl"] = utils.DataExportDownloadURL(export.ID)
+	}
+	if export.Error != "" {
+		view["error"] = export.Error
+	}
+	return view
+}
+
+// RequestDataExport queues a zip export of the user's profile, prediction
+// history and images. The user is emailed a download link once it is ready.
+func RequestDataExport(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	export, err := utils.CreateDataExport(userClaims.UserID)
+	if err != nil {
+		if errors.Is(err, utils.ErrDataExportInProgress) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": "A data export is already being prepared",
+			})
+		}
+		log.Println("Error creating data export:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to start data export",
+		})
+	}
+
+	utils.RecordAuditEvent(c, models.AuditDataExportRequested, userClaims.UserID, userClaims.UserID, models.AuditResultSuccess, nil)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Your data export is being prepared. We will email you when it is ready.",
+		"data":    dataExportView(*export),
+	})
+}
+
+// GetDataExports lists the user's data exports, newest first
+func GetDataExports(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := configs.GetCollection(configs.DB, "data_exports")
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userClaims.UserID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		log.Printf("Error: Failed to query data exports - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve data exports",
+		})
+	}
+	defer cursor.Close(ctx)
+
+	var exports []models.DataExport
+	if err := cursor.All(ctx, &exports); err != nil {
+		log.Printf("Error: Failed to decode data exports - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve data exports",
+		})
+	}
+
+	result := []fiber.Map{}
+	for _, export := range exports {
+		result = append(result, dataExportView(export))
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Data exports retrieved successfully",
+		"data":    result,
+	})
+}
+
+// DownloadDataExport streams a finished export zip to its owner
+func DownloadDataExport(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	exportID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid export ID",
+		})
+	}
+
+	var export models.DataExport
+	err = configs.GetCollection(configs.DB, "data_exports").FindOne(context.TODO(), bson.M{
+		"_id":       exportID,
+		"user_id":   userClaims.UserID,
+		"status":    models.DataExportReady,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&export)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Export not found or expired",
+			})
+		}
+		log.Println("Error loading data export:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to download export",
+		})
+	}
+
+	bucket := configs.GetGridFSBucket(configs.DB)
+	download, err := bucket.OpenDownloadStream(export.FileID)
+	if err != nil {
+		log.Println("Error opening data export file:", err)
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Export not found or expired",
+		})
+	}
+
+	c.Set("Content-Type", "application/zip")
+	c.Attachment("kale-export-" + export.CreatedAt.Format("20060102") + ".zip")
+	// Fiber closes the stream once the response has been sent
+	return c.SendStream(download, int(download.GetFile().Length))
+}
+
+// RequestAccountDeletionOTP emails a code that confirms an account deletion.
+// Accounts without a password, such as OAuth-only ones, must use it.
+func RequestAccountDeletionOTP(c *fiber.Ctx) error {
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+
+	otp, err := utils.IssueOTP(utils.OTPPurposeDeleteAccount, user.Email)
+	if err != nil {
+		return issueOTPErrorResponse(c, err)
+	}
+	if err := utils.SendAccountDeletionCodeEmail(user.Email, user.Locale, otp); err != nil {
+		log.Println("Error sending account deletion code:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to send verification email",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "A confirmation code has been sent to your email.",
+	})
+}
+
+// DeleteAccount schedules the account for deletion after the grace period,
+// confirmed with the password or a code from RequestAccountDeletionOTP. Other
+// sessions are signed out; signing in again lets the user cancel.
+func DeleteAccount(c *fiber.Ctx) error {
+	type DeleteAccountInput struct {
+		Password         string `json:"password"`
+		VerificationCode string `json:"verificationCode"`
+	}
+	input := new(DeleteAccountInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+	if !user.DeletionScheduledAt.IsZero() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Account deletion is already scheduled",
+			"data":    fiber.Map{"deletionScheduledAt": user.DeletionScheduledAt},
+		})
+	}
+
+	switch {
+	case input.VerificationCode != "":
+		if ok, err := checkAttemptThrottle(c, attemptScopeOTP, user.Email); !ok {
+			return err
+		}
+		if err := utils.VerifyOTP(utils.OTPPurposeDeleteAccount, user.Email, input.VerificationCode); err != nil {
+			return otpErrorResponse(c, err, user.Email, user)
+		}
+		clearFailedAttempts(attemptScopeOTP, user.Email)
+		if err := utils.DeleteOTPs(utils.OTPPurposeDeleteAccount, user.Email); err != nil {
+			log.Println("Error deleting used OTPs:", err)
+		}
+	case user.Password != "":
+		if ok, err := checkCurrentPassword(c, user, input.Password); !ok {
+			return err
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Verification code is required",
+		})
+	}
+
+	deleteAt, err := utils.ScheduleAccountDeletion(user)
+	if err != nil {
+		log.Println("Error scheduling account deletion:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to delete account",
+		})
+	}
+
+	claims := c.Locals("user").(*models.Claims)
+	if err := utils.RevokeUserSessions(user.Id.Hex(), claims.SessionID); err != nil {
+		log.Println("Error revoking sessions:", err)
+	}
+	if err := utils.SendAccountDeletionScheduledEmail(user.Email, user.Locale, deleteAt); err != nil {
+		log.Println("Error sending account deletion email:", err)
+	}
+	utils.RecordAuditEvent(c, models.AuditDeletionScheduled, user.Id.Hex(), user.Id.Hex(), models.AuditResultSuccess, map[string]string{
+		"delete_at": deleteAt.Format(time.RFC3339),
+	})
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Your account will be deleted at the end of the grace period. Sign in before then to cancel.",
+		"data":    fiber.Map{"deletionScheduledAt": deleteAt},
+	})
+}
+
+// CancelAccountDeletion keeps an account that is waiting to be deleted
+func CancelAccountDeletion(c *fiber.Ctx) error {
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+
+	cancelled, err := utils.CancelAccountDeletion(user.Id)
+	if err != nil {
+		log.Println("Error cancelling account deletion:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to cancel account deletion",
+		})
+	}
+	if !cancelled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Account is not scheduled for deletion",
+		})
+	}
+
+	utils.RecordAuditEvent(c, models.AuditDeletionCancelled, user.Id.Hex(), user.Id.Hex(), models.AuditResultSuccess, nil)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Account deletion cancelled",
+	})
+}
+
+// GetAuditEvents lists security events on the user's own account, including
+// actions admins took on it. It accepts the filters of utils.AuditQueryFilter.
+func GetAuditEvents(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := utils.AuditQueryFilter(c)
+	filter["target_user_id"] = userClaims.UserID
+
+	events, total, err := utils.QueryAuditEvents(filter, page, limit)
+	if err != nil {
+		log.Printf("Error: Failed to query audit events - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve audit events",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Audit events retrieved successfully",
+		"data": fiber.Map{
+			"events": events,
+			"page":   page,
+			"limit":  limit,
+			"total":  total,
+		},
+	})
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Attempt scopes keep login, emailed-code and signed-in 2FA code failures in
+// separate counters
+const (
+	attemptScopeLogin     = "login"
+	attemptScopeOTP       = "otp"
+	attemptScopeTwoFactor = "2fa"
+)
+
+// checkAttemptThrottle rejects the request with 429 while the account or the
+// client IP is locked or waiting out a delay. The account's attempt is
+// reserved atomically, so parallel requests cannot race past its limit; the
+// IP counter is only checked, as serializing every login from a shared
+// address would cost more than the few guesses a race allows there. On
+// rejection the response has already been written and false is returned.
+func checkAttemptThrottle(c *fiber.Ctx, scope, account string) (bool, error) {
+	wait, err := utils.CheckAttempts(utils.AttemptKey("auth", "ip", c.IP()), utils.IPAttemptPolicy)
+	if err == nil && wait <= 0 {
+		wait, err = utils.ReserveAttempt(utils.AttemptKey(scope, "account", account), utils.AccountAttemptPolicy)
+	}
+	if err != nil {
+		// Fail open so a database hiccup does not lock everyone out
+		log.Println("Error checking failed attempts:", err)
+		return true, nil
+	}
+	if wait <= 0 {
+		return true, nil
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return false, c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"status":  "error",
+		"message": fmt.Sprintf("Too many failed attempts. Please try again in %d seconds.", seconds),
+	})
+}
+
+// recordFailedAttempt counts a failure against the account and the client IP.
+// When it locks an existing account the owner is notified by email.
+func recordFailedAttempt(c *fiber.Ctx, scope, account string, user *models.User) {
+	locked, err := utils.RecordFailure(utils.AttemptKey(scope, "account", account), utils.AccountAttemptPolicy)
+	if err != nil {
+		log.Println("Error recording failed attempt:", err)
+	}
+	if _, err := utils.RecordFailure(utils.AttemptKey("auth", "ip", c.IP()), utils.IPAttemptPolicy); err != nil {
+		log.Println("Error recording failed attempt:", err)
+	}
+
+	if locked && user != nil {
+		log.Printf("Account %s locked after repeated failed %s attempts", user.Id.Hex(), scope)
+		go func(email, locale string) {
+			if err := utils.SendAccountLockedEmail(email, locale, utils.AccountAttemptPolicy.LockFor); err != nil {
+				log.Println("Error sending lockout email:", err)
+			}
+		}(user.Email, user.Locale)
+	}
+}
+
+// otpErrorResponse writes the response for a failed utils.VerifyOTP and
+// counts wrong guesses against the account and IP
+func otpErrorResponse(c *fiber.Ctx, err error, email string, user *models.User) error {
+	switch {
+	case errors.Is(err, utils.ErrOTPExpired):
+		releaseAttempt(attemptScopeOTP, email)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Code has expired. Please request a new one.",
+		})
+	case errors.Is(err, utils.ErrOTPAttemptsExceeded):
+		recordFailedAttempt(c, attemptScopeOTP, email, user)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Too many wrong codes. Please request a new one.",
+		})
+	case errors.Is(err, utils.ErrOTPInvalid):
+		recordFailedAttempt(c, attemptScopeOTP, email, user)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid or expired code",
+		})
+	}
+	releaseAttempt(attemptScopeOTP, email)
+	log.Println("Error verifying OTP:", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Error verifying code",
+	})
+}
+
+// issueOTPErrorResponse writes the response for a failed utils.IssueOTP
+func issueOTPErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, utils.ErrOTPCooldown) {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Please wait before requesting another code",
+		})
+	}
+	log.Println("Error issuing OTP:", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Could not send a new code",
+	})
+}
+
+// releaseAttempt ends the account's reserved attempt without counting it, for
+// requests that failed before a guess was checked
+func releaseAttempt(scope, account string) {
+	if err := utils.ReleaseAttempt(utils.AttemptKey(scope, "account", account)); err != nil {
+		log.Println("Error releasing attempt:", err)
+	}
+}
+
+// clearFailedAttempts resets the account's counter after a successful attempt
+func clearFailedAttempts(scope, account string) {
+	if err := utils.ResetAttempts(utils.AttemptKey(scope, "account", account)); err != nil {
+		log.Println("Error resetting failed attempts:", err)
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"backend-web/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -26,8 +25,9 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-// getUserByField retrieves a user by a specified field (e.g., email or username)
-func getUserByField(field, value string) (*models.User, error) {
+// getUserByField retrieves a user by a specified field (e.g., email or username).
+// It is a variable so tests can stub the database.
+var getUserByField = func(field string, value interface{}) (*models.User, error) {
 	collection := configs.GetCollection(configs.DB, "users")
 
 	var user models.User
@@ -99,6 +99,11 @@ func GetUserInfo(c *fiber.Ctx) error {
 			"avatar":        avatarURL,
 			"createdAt":     user.CreatedAt,
 			"emailVerified": user.EmailVerified,
+			"pendingEmail":  user.PendingEmail,
+			"twoFactor":     user.TwoFactorEnabled,
+			"locale":        user.Locale,
+			// Set while the account is waiting to be deleted
+			"deletionScheduledAt": user.DeletionScheduledAt,
 		},
 	})
 }
@@ -138,41 +143,168 @@ func Login(c *fiber.Ctx) error {
 			"message": "Internal Server Error",
 		})
 	}
+
+	// Count failures per account once it is known, so switching between
+	// username and email does not earn extra guesses
+	account := identity
+	if user != nil {
+		account = user.Id.Hex()
+	}
+	if ok, err := checkAttemptThrottle(c, attemptScopeLogin, account); !ok {
+		return err
+	}
+
 	if user == nil || !CheckPasswordHash(password, user.Password) {
+		recordFailedAttempt(c, attemptScopeLogin, account, user)
+		auditLogin(c, user, models.AuditResultFailure, map[string]string{
+			"method":   "password",
+			"identity": identity,
+			"reason":   "invalid_credentials",
+		})
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Invalid credentials",
 		})
 	}
+	clearFailedAttempts(attemptScopeLogin, account)
+
+	return finishLogin(c, user, "password", nil)
+}
+
+// auditLogin records a sign-in attempt against the account, if one was found
+func auditLogin(c *fiber.Ctx, user *models.User, result string, details map[string]string) {
+	userID := ""
+	if user != nil {
+		userID = user.Id.Hex()
+	}
+	utils.RecordAuditEvent(c, models.AuditLogin, userID, userID, result, details)
+}
+
+// finishLogin signs in a user whose password was checked. Accounts with 2FA
+// get an MFA challenge instead of tokens. Extra fields are added to the
+// success response, and method (e.g. "password") is recorded in the audit log.
+func finishLogin(c *fiber.Ctx, user *models.User, method string, extra fiber.Map) error {
+	if user.Disabled {
+		auditLogin(c, user, models.AuditResultFailure, map[string]string{"method": method, "reason": "account_disabled"})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Account is disabled",
+		})
+	}
+	if user.MustResetPassword {
+		auditLogin(c, user, models.AuditResultFailure, map[string]string{"method": method, "reason": "password_reset_required"})
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Password reset required. Please reset your password to continue.",
+		})
+	}
+
+	// Accounts with 2FA get a short-lived challenge instead of tokens and
+	// finish signing in through /login/2fa
+	if user.TwoFactorEnabled {
+		mfaToken, err := utils.CreateMFAChallenge(user.Id.Hex())
+		if err != nil {
+			log.Println("MFA challenge error:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Internal Server Error",
+			})
+		}
+		response := fiber.Map{
+			"status":       "success",
+			"message":      "Two-factor authentication required",
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"expires_in":   int(utils.MFAChallengeTTL.Seconds()),
+		}
+		for key, value := range extra {
+			response[key] = value
+		}
+		return c.JSON(response)
+	}
+
+	// Create access and refresh tokens
+	pair, err := utils.IssueTokenPair(c, user, "")
+	if err != nil {
+		log.Println("Token issuing error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+
+	auditLogin(c, user, models.AuditResultSuccess, map[string]string{"method": method})
+
+	response := fiber.Map{
+		"status":        "success",
+		"message":       "Login successful",
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	return c.JSON(response)
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh token pair.
+// The refresh token is read from the body or, for browser flows, the cookie.
+func RefreshToken(c *fiber.Ctx) error {
+	type RefreshInput struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	input := new(RefreshInput)
+	c.BodyParser(input)
 
-	// Create JWT token
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["user_id"] = user.Id.Hex()
-	claims["username"] = user.Username
-	claims["exp"] = time.Now().Add(time.Hour * 72).Unix()
+	fromCookie := false
+	if input.RefreshToken == "" {
+		input.RefreshToken = c.Cookies("refresh_token")
+		fromCookie = input.RefreshToken != ""
+	}
+	if input.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Refresh token required",
+		})
+	}
 
-	// Retrieve JWT secret from environment
-	secret := configs.EnvSecret()
-	t, err := token.SignedString([]byte(secret))
+	_, pair, err := utils.RotateRefreshToken(c, input.RefreshToken)
 	if err != nil {
-		log.Println("JWT signing error:", err)
+		if errors.Is(err, utils.ErrInvalidRefreshToken) || errors.Is(err, utils.ErrRefreshTokenReuse) {
+			if fromCookie {
+				utils.ClearAuthCookies(c)
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid or expired refresh token",
+			})
+		}
+		log.Println("Refresh token error:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Internal Server Error",
 		})
 	}
 
+	if fromCookie {
+		utils.SetAuthCookies(c, pair)
+	}
+
 	return c.JSON(fiber.Map{
-		"status":  "success",
-		"message": "Login successful",
-		"token":   t,
+		"status":        "success",
+		"message":       "Token refreshed",
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
 	})
 }
 
-// Logout blacklists the user's token
+// Logout blacklists the user's token and ends its session, which also
+// revokes the session's refresh tokens
 func Logout(c *fiber.Ctx) error {
-	token := c.Get("Authorization")
+	token := utils.ExtractToken(c)
 	if token == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
@@ -180,7 +312,41 @@ func Logout(c *fiber.Ctx) error {
 		})
 	}
 
-	err := utils.BlacklistToken(token, time.Hour*72)
+	type LogoutInput struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	input := new(LogoutInput)
+	c.BodyParser(input)
+	if input.RefreshToken == "" {
+		input.RefreshToken = c.Cookies("refresh_token")
+	}
+
+	if claims, ok := c.Locals("user").(*models.Claims); ok {
+		sessionIDs := []string{claims.SessionID}
+		if input.RefreshToken != "" {
+			sessionID, err := utils.RefreshTokenSession(input.RefreshToken)
+			if err != nil {
+				log.Println("Refresh token lookup error:", err)
+			}
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+		for _, sessionID := range sessionIDs {
+			if sessionID == "" {
+				continue
+			}
+			if _, err := utils.RevokeSession(claims.UserID, sessionID); err != nil {
+				log.Println("Revoke session error:", err)
+			}
+		}
+	}
+	utils.ClearAuthCookies(c)
+
+	// Keep the token blacklisted until it would have expired on its own
+	expiration := configs.EnvAccessTokenTTL()
+	if claims, ok := c.Locals("user").(*models.Claims); ok && claims.ExpiresAt != nil {
+		expiration = time.Until(claims.ExpiresAt.Time)
+	}
+	err := utils.BlacklistToken(token, expiration)
 	if err != nil {
 		log.Println("Blacklist token error:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -189,6 +355,10 @@ func Logout(c *fiber.Ctx) error {
 		})
 	}
 
+	if claims, ok := c.Locals("user").(*models.Claims); ok {
+		utils.RecordAuditEvent(c, models.AuditLogout, claims.UserID, claims.UserID, models.AuditResultSuccess, nil)
+	}
+
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "Logged out successfully",
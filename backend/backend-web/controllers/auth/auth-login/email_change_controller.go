@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RequestEmailChange starts moving the account to a new address. The new
+// address only takes effect once ConfirmEmailChange receives the OTP sent to it.
+// In uniform mode an address that belongs to another account gets the usual
+// response, and its owner is emailed instead of the caller being told.
+func RequestEmailChange(c *fiber.Ctx) error {
+	type EmailChangeInput struct {
+		NewEmail string `json:"newEmail"`
+		Password string `json:"password"`
+	}
+	input := new(EmailChangeInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+
+	newEmail := strings.TrimSpace(input.NewEmail)
+	if !isEmail(newEmail) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid email address",
+		})
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "New email must be different from the current email",
+		})
+	}
+
+	// OAuth-only accounts have no password to confirm with
+	if user.Password != "" {
+		if ok, err := checkCurrentPassword(c, user, input.Password); !ok {
+			return err
+		}
+	}
+
+	started := time.Now()
+	existingUser, err := getUserByField("email", newEmail)
+	if err != nil {
+		log.Println("Database error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+	uniform := uniformResponses()
+	if existingUser != nil && !uniform {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Email already in use",
+		})
+	}
+
+	var otp string
+	if !uniform {
+		otp, err = utils.IssueOTP(utils.OTPPurposeChangeEmail, newEmail)
+		if err != nil {
+			return issueOTPErrorResponse(c, err)
+		}
+	}
+
+	// A new request replaces any earlier pending address. In uniform mode a
+	// taken address is stored too, so the account looks the same either way;
+	// ConfirmEmailChange rejects it because no code was sent.
+	if user.PendingEmail != "" && user.PendingEmail != newEmail {
+		if err := utils.DeleteOTPs(utils.OTPPurposeChangeEmail, user.PendingEmail); err != nil {
+			log.Println("Error deleting old OTPs:", err)
+		}
+	}
+	users := configs.GetCollection(configs.DB, "users")
+	_, err = users.UpdateOne(context.TODO(), bson.M{"_id": user.Id}, bson.M{"$set": bson.M{"pendingEmail": newEmail}})
+	if err != nil {
+		log.Println("Error saving pending email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to start email change",
+		})
+	}
+
+	if uniform {
+		go func(locale string, owner *models.User) {
+			var err error
+			if owner != nil {
+				err = sendAccountExistsNotice(owner.Email, owner.Locale)
+			} else {
+				err = sendEmailChangeCode(newEmail, locale)
+			}
+			if err != nil && !errors.Is(err, utils.ErrOTPCooldown) {
+				log.Println("Error emailing new address:", err)
+			}
+		}(user.Locale, existingUser)
+
+		if wait := uniformResponseFloor - time.Since(started); wait > 0 {
+			time.Sleep(wait)
+		}
+		return c.JSON(fiber.Map{
+			"status":  "success",
+			"message": uniformEmailChangeMessage,
+			"data":    fiber.Map{"pendingEmail": newEmail},
+		})
+	}
+
+	if err := utils.SendVerificationEmail(newEmail, user.Locale, otp); err != nil {
+		log.Println("Error sending verification email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to send verification email",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Verification code sent to the new email address",
+		"data":    fiber.Map{"pendingEmail": newEmail},
+	})
+}
+
+// ConfirmEmailChange switches the account to the pending address once the OTP
+// sent there is verified. The old address is notified and other sessions are
+// signed out.
+func ConfirmEmailChange(c *fiber.Ctx) error {
+	type ConfirmInput struct {
+		VerificationCode string `json:"verificationCode"`
+	}
+	input := new(ConfirmInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+	if user.PendingEmail == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No email change in progress",
+		})
+	}
+	newEmail := user.PendingEmail
+
+	if ok, err := checkAttemptThrottle(c, attemptScopeOTP, newEmail); !ok {
+		return err
+	}
+	if err := utils.VerifyOTP(utils.OTPPurposeChangeEmail, newEmail, input.VerificationCode); err != nil {
+		return otpErrorResponse(c, err, newEmail, user)
+	}
+	clearFailedAttempts(attemptScopeOTP, newEmail)
+	if err := utils.DeleteOTPs(utils.OTPPurposeChangeEmail, newEmail); err != nil {
+		log.Println("Error deleting used OTPs:", err)
+	}
+
+	// The address may have been registered since the change was requested
+	existingUser, err := getUserByField("email", newEmail)
+	if err != nil {
+		log.Println("Database error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+	if existingUser != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Email already in use",
+		})
+	}
+
+	users := configs.GetCollection(configs.DB, "users")
+	result, err := users.UpdateOne(context.TODO(),
+		bson.M{"_id": user.Id, "pendingEmail": newEmail},
+		bson.M{
+			"$set":   bson.M{"email": newEmail, "emailVerified": true},
+			"$unset": bson.M{"pendingEmail": ""},
+		},
+	)
+	if err != nil {
+		log.Println("Error updating email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update email",
+		})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The email change was replaced by a newer request",
+		})
+	}
+
+	claims := c.Locals("user").(*models.Claims)
+	if err := utils.RevokeUserSessions(user.Id.Hex(), claims.SessionID); err != nil {
+		log.Println("Error revoking sessions:", err)
+	}
+
+	utils.RecordAuditEvent(c, models.AuditEmailChanged, user.Id.Hex(), user.Id.Hex(), models.AuditResultSuccess, map[string]string{
+		"old_email": user.Email,
+		"new_email": newEmail,
+	})
+
+	if err := utils.SendEmailChangedEmail(user.Email, user.Locale, newEmail); err != nil {
+		log.Println("Error sending email changed notice:", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Email changed successfully",
+		"data":    fiber.Map{"email": newEmail},
+	})
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetIdentities lists the external logins linked to the user's account
+func GetIdentities(c *fiber.Ctx) error {
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+
+	identities, err := utils.UserIdentities(user.Id.Hex())
+	if err != nil {
+		log.Println("Error fetching identities:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Linked identities",
+		"data": fiber.Map{
+			"identities":  identities,
+			"hasPassword": user.Password != "",
+			"providers":   utils.OAuthProviderNames(),
+		},
+	})
+}
+
+// StartIdentityLink begins connecting another login provider to the
+// logged-in account. It returns the provider URL for the browser to open;
+// the OAuth callback links the identity instead of signing in.
+func StartIdentityLink(c *fiber.Ctx) error {
+	type LinkInput struct {
+		ReturnTo string `json:"return_to"`
+	}
+	input := new(LinkInput)
+	c.BodyParser(input)
+
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+
+	provider, err := utils.GetOAuthProvider(c.Params("provider"))
+	if err != nil {
+		if errors.Is(err, utils.ErrUnknownOAuthProvider) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Unknown login provider",
+			})
+		}
+		log.Println("OAuth provider error:", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Login provider is unavailable",
+		})
+	}
+
+	returnTo, err := utils.ResolveReturnTo(input.ReturnTo)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid return_to",
+		})
+	}
+
+	state, flow, err := utils.CreateOAuthFlow(provider.Name, returnTo, user.Id.Hex())
+	if err != nil {
+		log.Println("OAuth flow error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to start linking",
+		})
+	}
+	utils.SetOAuthStateCookie(c, state, provider.Name)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Continue at the login provider",
+		"data":    fiber.Map{"url": provider.AuthCodeURL(state, flow.Nonce, flow.CodeVerifier)},
+	})
+}
+
+// UnlinkIdentity disconnects a login provider from the logged-in account
+func UnlinkIdentity(c *fiber.Ctx) error {
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+
+	if err := utils.UnlinkIdentity(user, c.Params("provider")); err != nil {
+		switch {
+		case errors.Is(err, utils.ErrIdentityNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "No login linked for this provider",
+			})
+		case errors.Is(err, utils.ErrLastLoginMethod):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Set a password or link another login before removing this one",
+			})
+		}
+		log.Println("Error unlinking identity:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+
+	utils.RecordAuditEvent(c, models.AuditIdentityUnlinked, user.Id.Hex(), user.Id.Hex(), models.AuditResultSuccess, map[string]string{"provider": c.Params("provider")})
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Login unlinked",
+	})
+}
+
+// ConfirmIdentityLink finishes an OAuth login whose email matched an existing
+// password account. The owner proves it is theirs with the account password,
+// then the identity is linked and the login continues as a password login.
+func ConfirmIdentityLink(c *fiber.Ctx) error {
+	type ConfirmInput struct {
+		LinkToken string `json:"link_token"`
+		Password  string `json:"password"`
+	}
+	input := new(ConfirmInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	request, err := utils.GetIdentityLinkRequest(input.LinkToken)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidLinkRequest) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid or expired link request. Please sign in again.",
+			})
+		}
+		log.Println("Database error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+
+	objID, err := primitive.ObjectIDFromHex(request.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid or expired link request. Please sign in again.",
+		})
+	}
+	user, err := getUserByField("_id", objID)
+	if err != nil {
+		log.Println("Database error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+	if user == nil || user.Password == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid or expired link request. Please sign in again.",
+		})
+	}
+
+	if ok, err := checkAttemptThrottle(c, attemptScopeLogin, request.UserID); !ok {
+		return err
+	}
+	if !CheckPasswordHash(input.Password, user.Password) {
+		recordFailedAttempt(c, attemptScopeLogin, request.UserID, user)
+		auditLogin(c, user, models.AuditResultFailure, map[string]string{"method": request.Provider, "reason": "invalid_credentials"})
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid credentials",
+		})
+	}
+	clearFailedAttempts(attemptScopeLogin, request.UserID)
+
+	if err := utils.CompleteIdentityLinkRequest(request.ID); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid or expired link request. Please sign in again.",
+		})
+	}
+	err = utils.LinkIdentity(request.UserID, &utils.OAuthProfile{
+		Provider: request.Provider,
+		Subject:  request.Subject,
+		Email:    request.Email,
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrIdentityLinked) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": "This login is already linked to another account",
+			})
+		}
+		log.Println("Error linking identity:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+
+	utils.RecordAuditEvent(c, models.AuditIdentityLinked, request.UserID, request.UserID, models.AuditResultSuccess, map[string]string{"provider": request.Provider})
+
+	return finishLogin(c, user, request.Provider, fiber.Map{"return_to": request.ReturnTo})
+}
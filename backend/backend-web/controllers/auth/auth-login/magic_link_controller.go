@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// magicLinkSentMessage is returned whether or not the email has an account,
+// so the endpoint cannot be used to find out who is registered
+const magicLinkSentMessage = "If an account exists for this email, a sign-in link has been sent."
+
+// RequestMagicLink emails a single-use login link to a verified account
+func RequestMagicLink(c *fiber.Ctx) error {
+	type MagicLinkInput struct {
+		Email string `json:"email"`
+	}
+	input := new(MagicLinkInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+	if !isEmail(input.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid email address",
+		})
+	}
+
+	user, err := getUserByField("email", input.Email)
+	if err != nil {
+		log.Println("Database error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+
+	if user != nil && user.EmailVerified && !user.Disabled {
+		link, err := utils.CreateMagicLink(user)
+		switch {
+		case errors.Is(err, utils.ErrMagicLinkCooldown):
+			// Answer as usual; the earlier link is still valid
+		case err != nil:
+			log.Println("Error creating magic link:", err)
+		default:
+			// Send in the background so the response time does not reveal
+			// whether the account exists
+			go func(email, locale, link string) {
+				if err := utils.SendMagicLinkEmail(email, locale, link, utils.MagicLinkTTL); err != nil {
+					log.Println("Error sending magic link email:", err)
+				}
+			}(user.Email, user.Locale, link)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": magicLinkSentMessage,
+	})
+}
+
+// VerifyMagicLink exchanges a login link token for the normal session tokens
+func VerifyMagicLink(c *fiber.Ctx) error {
+	type VerifyInput struct {
+		Token string `json:"token"`
+	}
+	input := new(VerifyInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	link, err := utils.ConsumeMagicLink(input.Token)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidMagicLink) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid or expired sign-in link. Please request a new one.",
+			})
+		}
+		log.Println("Database error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+
+	objID, err := primitive.ObjectIDFromHex(link.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid or expired sign-in link. Please request a new one.",
+		})
+	}
+	user, err := getUserByField("_id", objID)
+	if err != nil {
+		log.Println("Database error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+	// A link sent before an email change must not work for the new address
+	if user == nil || user.Email != link.Email {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid or expired sign-in link. Please request a new one.",
+		})
+	}
+
+	return finishLogin(c, user, "magic_link", nil)
+}
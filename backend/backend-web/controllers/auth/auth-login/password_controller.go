@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"backend-web/configs"
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// checkPasswordPolicy rejects a new password that breaks the password policy
+// with 400 and the list of violations. On rejection the response has already
+// been written and false is returned.
+func checkPasswordPolicy(c *fiber.Ctx, password, username, email string) (bool, error) {
+	violations := utils.ValidatePassword(password, username, email)
+	if len(violations) == 0 {
+		return true, nil
+	}
+	return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"status":  "error",
+		"message": violations[0].Message,
+		"data": fiber.Map{
+			"violations": violations,
+			"policy":     utils.CurrentPasswordPolicy(),
+		},
+	})
+}
+
+// checkCurrentPassword confirms the logged-in user knows their password before
+// a sensitive change. Wrong guesses count toward login throttling. On failure
+// the response has already been written and false is returned.
+func checkCurrentPassword(c *fiber.Ctx, user *models.User, password string) (bool, error) {
+	account := user.Id.Hex()
+	if ok, err := checkAttemptThrottle(c, attemptScopeLogin, account); !ok {
+		return false, err
+	}
+	if !CheckPasswordHash(password, user.Password) {
+		recordFailedAttempt(c, attemptScopeLogin, account, user)
+		return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Current password is incorrect",
+		})
+	}
+	clearFailedAttempts(attemptScopeLogin, account)
+	return true, nil
+}
+
+// GetPasswordPolicy returns the password rules so clients can show them
+func GetPasswordPolicy(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Password policy",
+		"data":    utils.CurrentPasswordPolicy(),
+	})
+}
+
+// ChangePassword replaces the password of the logged-in user after checking
+// the current one. Accounts created through OAuth have no password yet and
+// can set their first one without it. Other sessions are signed out.
+func ChangePassword(c *fiber.Ctx) error {
+	type ChangePasswordInput struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	input := new(ChangePasswordInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+	account := user.Id.Hex()
+	firstPassword := user.Password == ""
+	if !firstPassword {
+		if ok, err := checkCurrentPassword(c, user, input.CurrentPassword); !ok {
+			return err
+		}
+		if CheckPasswordHash(input.NewPassword, user.Password) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "New password must be different from the current password",
+			})
+		}
+	}
+	if ok, err := checkPasswordPolicy(c, input.NewPassword, user.Username, user.Email); !ok {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("Error hashing password:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error processing password",
+		})
+	}
+
+	users := configs.GetCollection(configs.DB, "users")
+	_, err = users.UpdateOne(context.TODO(),
+		bson.M{"_id": user.Id},
+		bson.M{"$set": bson.M{"password": string(hashedPassword)}},
+	)
+	if err != nil {
+		log.Println("Error updating password:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update password",
+		})
+	}
+
+	// Keep the current session and sign out everywhere else
+	claims := c.Locals("user").(*models.Claims)
+	if err := utils.RevokeUserSessions(account, claims.SessionID); err != nil {
+		log.Println("Error revoking sessions:", err)
+	}
+
+	if err := utils.SendPasswordChangedEmail(user.Email, user.Locale); err != nil {
+		log.Println("Error sending password changed email:", err)
+	}
+
+	utils.RecordAuditEvent(c, models.AuditPasswordChanged, account, account, models.AuditResultSuccess, map[string]string{"first_password": strconv.FormatBool(firstPassword)})
+
+	message := "Password changed successfully"
+	if firstPassword {
+		message = "Password set successfully"
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+	})
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
@@ -15,12 +16,42 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Register creates a new user account
+// refreshVerificationCode issues a new email verification code, keeps the
+// unverified account alive as long as the code and emails it
+func refreshVerificationCode(email, locale string) error {
+	code, err := utils.IssueOTP(utils.OTPPurposeVerifyEmail, email)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	collection := configs.GetCollection(configs.DB, "users")
+	_, err = collection.UpdateOne(
+		context.TODO(),
+		bson.M{"email": email},
+		bson.M{
+			"$set": bson.M{
+				"lastVerificationSent": now,
+				"expiresAt":            now.Add(utils.OTPTTL),
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return utils.SendVerificationEmail(email, locale, code)
+}
+
+// Register creates a new user account. In uniform mode an email that is
+// already registered gets the usual success response, and its owner is
+// emailed instead of the caller being told.
 func Register(c *fiber.Ctx) error {
 	type RegisterInput struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
 		Email    string `json:"email"`
+		Locale   string `json:"locale"`
 	}
 
 	input := new(RegisterInput)
@@ -31,6 +62,7 @@ func Register(c *fiber.Ctx) error {
 			"data":    err.Error(),
 		})
 	}
+	started := time.Now()
 
 	if input.Username == "" || input.Password == "" || !isEmail(input.Email) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -39,7 +71,12 @@ func Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if username exists
+	if ok, err := checkPasswordPolicy(c, input.Password, input.Username, input.Email); !ok {
+		return err
+	}
+
+	// Check if username exists. Usernames are public, so this is reported
+	// even in uniform mode.
 	existingUser, _ := getUserByField("username", input.Username)
 	if existingUser != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -50,6 +87,20 @@ func Register(c *fiber.Ctx) error {
 
 	// Check if email exists
 	existingUser, _ = getUserByField("email", input.Email)
+	if existingUser != nil && uniformResponses() {
+		go func(user models.User) {
+			var err error
+			if user.EmailVerified {
+				err = sendAccountExistsNotice(user.Email, user.Locale)
+			} else {
+				err = resendVerificationCode(user.Email, user.Locale)
+			}
+			if err != nil && !errors.Is(err, utils.ErrOTPCooldown) {
+				log.Println("Error emailing existing account:", err)
+			}
+		}(*existingUser)
+		return respondUniform(c, started, uniformRegisterMessage)
+	}
 	if existingUser != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
@@ -67,23 +118,26 @@ func Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate verification code
-	verificationCode := utils.GenerateVerificationCode()
+	// Emails follow the chosen language, or the browser's when none was picked
+	locale := utils.NormalizeLocale(input.Locale)
+	if locale == "" {
+		locale = utils.NormalizeLocale(c.Get(fiber.HeaderAcceptLanguage))
+	}
 
+	now := time.Now()
 	newUser := models.User{
-		Id:               primitive.NewObjectID(),
-		Username:         input.Username,
-		Password:         string(hashedPassword),
-		Email:            input.Email,
-		CreatedAt:        time.Now(),
-		EmailVerified:    false,
-		VerificationCode: verificationCode,
-		ExpiresAt:        time.Now().Add(10 * time.Minute),
+		Id:                   primitive.NewObjectID(),
+		Username:             input.Username,
+		Password:             string(hashedPassword),
+		Email:                input.Email,
+		CreatedAt:            now,
+		EmailVerified:        false,
+		LastVerificationSent: now,
+		ExpiresAt:            now.Add(utils.OTPTTL),
+		Locale:               locale,
 	}
 
-	collection := configs.GetCollection(configs.DB, "users")
-	_, err = collection.InsertOne(context.TODO(), newUser)
-	if err != nil {
+	if err := insertUser(newUser); err != nil {
 		log.Println("Error inserting user:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -91,13 +145,19 @@ func Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// Send verification email
-	if err := utils.SendEmailVerification(input.Email, verificationCode, false); err != nil {
+	if uniformResponses() {
+		go func(email, locale string) {
+			if err := sendVerificationCode(email, locale); err != nil {
+				log.Println("Error sending verification email:", err)
+			}
+		}(input.Email, locale)
+		return respondUniform(c, started, uniformRegisterMessage)
+	}
+
+	// Generate and queue the verification code. The account already exists,
+	// so a failure is only logged; the user can ask for a new code.
+	if err := sendVerificationCode(input.Email, locale); err != nil {
 		log.Println("Error sending verification email:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to send verification email",
-		})
 	}
 
 	return c.JSON(fiber.Map{
@@ -122,6 +182,10 @@ func VerifyEmail(c *fiber.Ctx) error {
 		})
 	}
 
+	if ok, err := checkAttemptThrottle(c, attemptScopeOTP, input.Email); !ok {
+		return err
+	}
+
 	// Check if user exists and verification code matches
 	user, err := getUserByField("email", input.Email)
 	if err != nil {
@@ -131,19 +195,15 @@ func VerifyEmail(c *fiber.Ctx) error {
 			"message": "Error checking user",
 		})
 	}
-	if user == nil || user.VerificationCode != input.VerificationCode {
+	if user == nil || user.EmailVerified {
+		recordFailedAttempt(c, attemptScopeOTP, input.Email, user)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Invalid verification code",
 		})
 	}
-
-	// Check if verification code has expired
-	if time.Now().After(user.ExpiresAt) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Verification code has expired",
-		})
+	if err := utils.VerifyOTP(utils.OTPPurposeVerifyEmail, input.Email, input.VerificationCode); err != nil {
+		return otpErrorResponse(c, err, input.Email, user)
 	}
 
 	// Mark email as verified
@@ -153,7 +213,7 @@ func VerifyEmail(c *fiber.Ctx) error {
 		bson.M{"email": input.Email},
 		bson.M{
 			"$set":   bson.M{"emailVerified": true},
-			"$unset": bson.M{"expiresAt": "", "verificationCode": ""},
+			"$unset": bson.M{"expiresAt": ""},
 		},
 	)
 	if err != nil {
@@ -164,6 +224,10 @@ func VerifyEmail(c *fiber.Ctx) error {
 		})
 	}
 	log.Println("Matched:", res.MatchedCount, "Modified:", res.MatchedCount)
+	clearFailedAttempts(attemptScopeOTP, input.Email)
+	if err := utils.DeleteOTPs(utils.OTPPurposeVerifyEmail, input.Email); err != nil {
+		log.Println("Error cleaning up OTPs:", err)
+	}
 
 	return c.JSON(fiber.Map{
 		"status":  "success",
@@ -171,7 +235,8 @@ func VerifyEmail(c *fiber.Ctx) error {
 	})
 }
 
-// ResendVerification resends a verification email to the user
+// ResendVerification resends a verification email to the user. In uniform
+// mode unknown and already verified emails get the same response.
 func ResendVerification(c *fiber.Ctx) error {
 	type Input struct {
 		Email string `json:"email"`
@@ -192,6 +257,7 @@ func ResendVerification(c *fiber.Ctx) error {
 		})
 	}
 
+	started := time.Now()
 	user, err := getUserByField("email", input.Email)
 	if err != nil {
 		log.Println("Database error:", err)
@@ -200,6 +266,16 @@ func ResendVerification(c *fiber.Ctx) error {
 			"message": "Database error",
 		})
 	}
+	if uniformResponses() {
+		if user != nil && !user.EmailVerified {
+			go func(email, locale string) {
+				if err := resendVerificationCode(email, locale); err != nil && !errors.Is(err, utils.ErrOTPCooldown) {
+					log.Println("Error resending verification code:", err)
+				}
+			}(user.Email, user.Locale)
+		}
+		return respondUniform(c, started, uniformVerificationMessage)
+	}
 	if user == nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
@@ -214,40 +290,11 @@ func ResendVerification(c *fiber.Ctx) error {
 		})
 	}
 
-	// Cooldown check (60 seconds)
-	if time.Since(user.LastVerificationSent) < time.Minute {
-		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Please wait before resending verification email",
-		})
-	}
-
-	// Generate new code
-	newCode := utils.GenerateVerificationCode()
-	now := time.Now()
-	collection := configs.GetCollection(configs.DB, "users")
-
-	_, err = collection.UpdateOne(
-		context.TODO(),
-		bson.M{"email": user.Email},
-		bson.M{
-			"$set": bson.M{
-				"verificationCode":     newCode,
-				"lastVerificationSent": now,
-				"expiresAt":            now.Add(10 * time.Minute),
-			},
-		},
-	)
-	if err != nil {
-		log.Println("Error updating verification code:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to update verification code",
-		})
-	}
-
-	// Send email
-	if err := utils.SendEmailVerification(user.Email, newCode, false); err != nil {
+	// Generate and send a new code, subject to the OTP cooldown
+	if err := resendVerificationCode(user.Email, user.Locale); err != nil {
+		if errors.Is(err, utils.ErrOTPCooldown) {
+			return issueOTPErrorResponse(c, err)
+		}
 		log.Println("Error sending verification email:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
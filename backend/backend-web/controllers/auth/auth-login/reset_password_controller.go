@@ -7,115 +7,128 @@ import (
 	"time"
 
 	"backend-web/configs"
+	"backend-web/models"
 	"backend-web/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// ForgotPassword initiates a password reset by sending an OTP
-func ForgotPassword(c *fiber.Ctx) error {
-	type ForgotInput struct {
-		Email string `json:"email"`
-	}
-
-	input := new(ForgotInput)
-	if err := c.BodyParser(input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request format",
-			"data":    err.Error(),
-		})
-	}
-
-	if !isEmail(input.Email) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+// getResetUser loads the verified account a password reset request is for.
+// On failure the error response has already been written and the returned
+// user is nil.
+func getResetUser(c *fiber.Ctx, email string) (*models.User, error) {
+	if !isEmail(email) {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Invalid email address",
 		})
 	}
 
-	// Check if user exists
-	user, err := getUserByField("email", input.Email)
+	user, err := getUserByField("email", email)
 	if err != nil {
 		log.Println("Database error:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Internal Server Error",
 		})
 	}
 	if user == nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"status":  "error",
 			"message": "User not found",
 		})
 	}
-
-	// Check if email is verified
 	if !user.EmailVerified {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		return nil, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Email not verified. Please verify your email before resetting password.",
 		})
 	}
+	return user, nil
+}
+
+// sendResetOTP issues a password reset OTP and emails it to the user
+func sendResetOTP(c *fiber.Ctx, user *models.User, successMessage string) error {
+	otp, err := utils.IssueOTP(utils.OTPPurposeResetPassword, user.Email)
+	if err != nil {
+		return issueOTPErrorResponse(c, err)
+	}
 
-	// Cooldown check (60 seconds)
-	collection := configs.GetCollection(configs.DB, "password_resets")
-	existing := collection.FindOne(context.TODO(), bson.M{
-		"email":     input.Email,
-		"createdAt": bson.M{"$gt": time.Now().Add(-time.Minute)},
-	})
-	if existing.Err() == nil {
-		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+	if err := utils.SendPasswordResetOTPEmail(user.Email, user.Locale, otp); err != nil {
+		log.Println("Error sending reset email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Please wait before requesting another OTP",
+			"message": "Failed to send reset email",
 		})
 	}
-	// Delete old unused OTPs for this email
-	_, err = collection.DeleteMany(context.TODO(), bson.M{
-		"email": input.Email,
-		"used":  false,
-	})
-	if err != nil {
-		log.Println("Error deleting old OTPs:", err)
-	}
+	utils.RecordAuditEvent(c, models.AuditPasswordResetRequested, "", user.Id.Hex(), models.AuditResultSuccess, nil)
 
-	// Generate OTP
-	otp := utils.GenerateVerificationCode()
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": successMessage,
+	})
+}
 
-	// Store OTP in password_resets collection
-	resetEntry := bson.M{
-		"email":            input.Email,
-		"verificationCode": otp,
-		"expiresAt":        time.Now().Add(10 * time.Minute),
-		"used":             false,
-		"createdAt":        time.Now(),
+// requestResetOTP emails a reset code to the account. In uniform mode the
+// response is the same whether or not a verified account exists and the code
+// is sent in the background.
+func requestResetOTP(c *fiber.Ctx, email, successMessage string) error {
+	if !uniformResponses() {
+		user, err := getResetUser(c, email)
+		if user == nil {
+			return err
+		}
+		return sendResetOTP(c, user, successMessage)
 	}
 
-	_, err = collection.InsertOne(context.TODO(), resetEntry)
+	if !isEmail(email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid email address",
+		})
+	}
+	started := time.Now()
+	user, err := getUserByField("email", email)
 	if err != nil {
-		log.Println("Error storing reset token:", err)
+		log.Println("Database error:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Could not initiate password reset",
+			"message": "Internal Server Error",
 		})
 	}
 
-	// Send OTP via email
-	if err := utils.SendEmailVerification(input.Email, otp, true); err != nil {
-		log.Println("Error sending reset email:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	if user != nil && user.EmailVerified {
+		utils.RecordAuditEvent(c, models.AuditPasswordResetRequested, "", user.Id.Hex(), models.AuditResultSuccess, nil)
+		go func(email, locale string) {
+			// During the cooldown the earlier code is still valid
+			if err := sendResetCode(email, locale); err != nil && !errors.Is(err, utils.ErrOTPCooldown) {
+				log.Println("Error sending reset email:", err)
+			}
+		}(user.Email, user.Locale)
+	}
+
+	return respondUniform(c, started, uniformResetMessage)
+}
+
+// ForgotPassword initiates a password reset by sending an OTP
+func ForgotPassword(c *fiber.Ctx) error {
+	type ForgotInput struct {
+		Email string `json:"email"`
+	}
+
+	input := new(ForgotInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Failed to send reset email",
+			"message": "Invalid request format",
+			"data":    err.Error(),
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"status":  "success",
-		"message": "Password reset OTP sent. Please check your email.",
-	})
+	return requestResetOTP(c, input.Email, "Password reset OTP sent. Please check your email.")
 }
 
 // VerifyResetOTP verifies the OTP for password reset
@@ -134,93 +147,67 @@ func VerifyResetOTP(c *fiber.Ctx) error {
 		})
 	}
 
-	if !isEmail(input.Email) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid email address",
-		})
+	if ok, err := checkAttemptThrottle(c, attemptScopeOTP, input.Email); !ok {
+		return err
 	}
 
-	// Check if user exists and email is verified
-	user, err := getUserByField("email", input.Email)
-	if err != nil {
-		log.Println("Database error:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Error checking user",
-		})
-	}
-	if user == nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"status":  "error",
-			"message": "User not found",
-		})
-	}
-	if !user.EmailVerified {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Email not verified. Please verify your email before resetting password.",
-		})
-	}
-
-	// Check OTP in password_resets collection
-	collection := configs.GetCollection(configs.DB, "password_resets")
-	var resetEntry struct {
-		Email            string    `bson:"email"`
-		VerificationCode string    `bson:"verificationCode"`
-		ExpiresAt        time.Time `bson:"expiresAt"`
-		Used             bool      `bson:"used"`
-	}
-	err = collection.FindOne(context.TODO(), bson.M{
-		"email":            input.Email,
-		"verificationCode": input.VerificationCode,
-		"used":             false,
-	}).Decode(&resetEntry)
-	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+	var user *models.User
+	if uniformResponses() {
+		// A missing or unverified account looks like a wrong code
+		found, err := getUserByField("email", input.Email)
+		if err != nil {
+			log.Println("Database error:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"status":  "error",
-				"message": "Invalid or expired OTP",
+				"message": "Internal Server Error",
 			})
 		}
-		log.Println("Database error:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Error verifying OTP",
-		})
+		if found == nil || !found.EmailVerified {
+			return otpErrorResponse(c, utils.ErrOTPInvalid, input.Email, found)
+		}
+		user = found
+	} else {
+		found, err := getResetUser(c, input.Email)
+		if found == nil {
+			releaseAttempt(attemptScopeOTP, input.Email)
+			return err
+		}
+		user = found
 	}
 
-	if time.Now().After(resetEntry.ExpiresAt) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "OTP has expired",
-		})
+	if err := utils.VerifyOTP(utils.OTPPurposeResetPassword, input.Email, input.VerificationCode); err != nil {
+		return otpErrorResponse(c, err, input.Email, user)
+	}
+	clearFailedAttempts(attemptScopeOTP, input.Email)
+	if err := utils.DeleteOTPs(utils.OTPPurposeResetPassword, input.Email); err != nil {
+		log.Println("Error deleting used OTPs:", err)
 	}
 
-	// Mark OTP as verified
-	_, err = collection.UpdateOne(
-		context.TODO(),
-		bson.M{"email": input.Email, "verificationCode": input.VerificationCode},
-		bson.M{"$set": bson.M{"used": true}},
-	)
+	// Only the holder of this token can finish the reset
+	resetToken, err := utils.CreateResetToken(user)
 	if err != nil {
-		log.Println("Error marking OTP as used:", err)
+		log.Println("Error creating reset token:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Error verifying OTP",
+			"message": "Internal Server Error",
 		})
 	}
 
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "OTP verified successfully. You can now reset your password.",
+		"data": fiber.Map{
+			"resetToken": resetToken,
+			"expiresIn":  int(utils.ResetTokenTTL.Seconds()),
+		},
 	})
 }
 
-// ResetPassword updates the user's password after OTP verification
+// ResetPassword sets a new password using the reset token returned by
+// VerifyResetOTP. The token works once.
 func ResetPassword(c *fiber.Ctx) error {
 	type ResetInput struct {
-		Email       string `json:"email"`
+		ResetToken  string `json:"resetToken"`
 		NewPassword string `json:"newPassword"`
 	}
 
@@ -233,66 +220,63 @@ func ResetPassword(c *fiber.Ctx) error {
 		})
 	}
 
-	if !isEmail(input.Email) {
+	if input.NewPassword == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Invalid email address",
+			"message": "New password is required",
 		})
 	}
 
-	if input.NewPassword == "" {
+	if input.ResetToken == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
-			"message": "New password is required",
+			"message": "Reset token is required",
 		})
 	}
 
-	// Check if user exists and email is verified
-	user, err := getUserByField("email", input.Email)
+	// Look the token up first so a password that breaks the policy does not use it up
+	resetToken, err := utils.GetResetToken(input.ResetToken)
 	if err != nil {
+		if errors.Is(err, utils.ErrInvalidResetToken) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid or expired reset token. Please verify OTP again.",
+			})
+		}
 		log.Println("Database error:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Internal Server Error",
+			"message": "Error checking reset token",
 		})
 	}
-	if user == nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"status":  "error",
-			"message": "User not found",
-		})
-	}
-	if !user.EmailVerified {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+
+	objID, err := primitive.ObjectIDFromHex(resetToken.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Email not verified. Please verify your email before resetting password.",
+			"message": "Invalid or expired reset token. Please verify OTP again.",
 		})
 	}
-
-	// Check if a verified OTP exists
-	collection := configs.GetCollection(configs.DB, "password_resets")
-	var resetEntry struct {
-		Email string `bson:"email"`
-		Used  bool   `bson:"used"`
-	}
-	err = collection.FindOne(context.TODO(), bson.M{
-		"email": input.Email,
-		"used":  true,
-	}).Decode(&resetEntry)
+	user, err := getUserByField("_id", objID)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"status":  "error",
-				"message": "No verified OTP found. Please verify OTP first.",
-			})
-		}
 		log.Println("Database error:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Error checking OTP",
+			"message": "Internal Server Error",
+		})
+	}
+	// The token was issued for the address the OTP went to
+	if user == nil || user.Email != resetToken.Email {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid or expired reset token. Please verify OTP again.",
 		})
 	}
 
+	if ok, err := checkPasswordPolicy(c, input.NewPassword, user.Username, user.Email); !ok {
+		return err
+	}
+
 	// Hash new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
@@ -303,12 +287,30 @@ func ResetPassword(c *fiber.Ctx) error {
 		})
 	}
 
+	// Using the token up is atomic, so only one request can finish the reset
+	if _, err := utils.ConsumeResetToken(input.ResetToken); err != nil {
+		if errors.Is(err, utils.ErrInvalidResetToken) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid or expired reset token. Please verify OTP again.",
+			})
+		}
+		log.Println("Database error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Error checking reset token",
+		})
+	}
+
 	// Update user password
 	userCollection := configs.GetCollection(configs.DB, "users")
 	_, err = userCollection.UpdateOne(
 		context.TODO(),
-		bson.M{"email": input.Email},
-		bson.M{"$set": bson.M{"password": string(hashedPassword)}},
+		bson.M{"_id": user.Id},
+		bson.M{
+			"$set":   bson.M{"password": string(hashedPassword)},
+			"$unset": bson.M{"mustResetPassword": ""},
+		},
 	)
 	if err != nil {
 		log.Println("Error updating password:", err)
@@ -318,15 +320,15 @@ func ResetPassword(c *fiber.Ctx) error {
 		})
 	}
 
-	// Clean up used OTPs
-	_, err = collection.DeleteMany(context.TODO(), bson.M{
-		"email": input.Email,
-		"used":  true,
-	})
-	if err != nil {
-		log.Println("Error cleaning up OTPs:", err)
-		// Continue despite this error to avoid blocking the user
+	// Sign out every existing session now that the password changed
+	if err := utils.RevokeUserSessions(user.Id.Hex(), ""); err != nil {
+		log.Println("Error revoking sessions:", err)
+	}
+
+	if err := utils.SendPasswordChangedEmail(user.Email, user.Locale); err != nil {
+		log.Println("Error sending password changed email:", err)
 	}
+	utils.RecordAuditEvent(c, models.AuditPasswordReset, user.Id.Hex(), user.Id.Hex(), models.AuditResultSuccess, nil)
 
 	return c.JSON(fiber.Map{
 		"status":  "success",
@@ -349,91 +351,5 @@ func ResendResetOTP(c *fiber.Ctx) error {
 		})
 	}
 
-	if !isEmail(input.Email) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid email address",
-		})
-	}
-
-	// Check if user exists
-	user, err := getUserByField("email", input.Email)
-	if err != nil {
-		log.Println("Database error:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Internal Server Error",
-		})
-	}
-	if user == nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Email not found. Please check your email address.",
-		})
-	}
-
-	// Check if email is verified
-	if !user.EmailVerified {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Email not verified. Please verify your email first.",
-		})
-	}
-
-	// Cooldown check (60 seconds)
-	collection := configs.GetCollection(configs.DB, "password_resets")
-	existing := collection.FindOne(context.TODO(), bson.M{
-		"email":     input.Email,
-		"createdAt": bson.M{"$gt": time.Now().Add(-time.Minute)},
-	})
-	if existing.Err() == nil {
-		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Too many requests. Please wait before trying again.",
-		})
-	}
-
-	// Delete old unused OTPs for this email
-	_, err = collection.DeleteMany(context.TODO(), bson.M{
-		"email": input.Email,
-		"used":  false,
-	})
-	if err != nil {
-		log.Println("Error deleting old OTPs:", err)
-	}
-
-	// Generate new OTP
-	otp := utils.GenerateVerificationCode()
-
-	// Store OTP in password_resets collection
-	resetEntry := bson.M{
-		"email":            input.Email,
-		"verificationCode": otp,
-		"expiresAt":        time.Now().Add(10 * time.Minute),
-		"used":             false,
-		"createdAt":        time.Now(),
-	}
-
-	_, err = collection.InsertOne(context.TODO(), resetEntry)
-	if err != nil {
-		log.Println("Error storing reset token:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Could not initiate OTP resend",
-		})
-	}
-
-	// Send OTP via email
-	if err := utils.SendEmailVerification(input.Email, otp, true); err != nil {
-		log.Println("Error sending reset email:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to send reset email",
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"status":  "success",
-		"message": "A new OTP has been sent to your email.",
-	})
-}
\ No newline at end of file
+	return requestResetOTP(c, input.Email, "A new OTP has been sent to your email.")
+}
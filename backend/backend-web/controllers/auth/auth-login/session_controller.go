@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetSessions lists the authenticated user's active sessions
+func GetSessions(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	collection := configs.GetCollection(configs.DB, "sessions")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":   userClaims.UserID,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error: Failed to query sessions - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve sessions",
+		})
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		log.Printf("Error: Failed to decode sessions - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve sessions",
+		})
+	}
+
+	data := make([]fiber.Map, 0, len(sessions))
+	for _, session := range sessions {
+		data = append(data, fiber.Map{
+			"id":         session.ID.Hex(),
+			"device":     session.Device,
+			"userAgent":  session.UserAgent,
+			"ip":         session.IP,
+			"createdAt":  session.CreatedAt,
+			"lastSeenAt": session.LastSeenAt,
+			"current":    session.ID.Hex() == userClaims.SessionID,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Sessions retrieved successfully",
+		"data":    data,
+	})
+}
+
+// RevokeSession signs out one of the authenticated user's sessions
+func RevokeSession(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	revoked, err := utils.RevokeSession(userClaims.UserID, c.Params("id"))
+	if err != nil {
+		log.Printf("Error: Failed to revoke session - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to revoke session",
+		})
+	}
+	if !revoked {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Session not found",
+		})
+	}
+
+	utils.RecordAuditEvent(c, models.AuditSessionRevoked, userClaims.UserID, userClaims.UserID, models.AuditResultSuccess, map[string]string{"session_id": c.Params("id")})
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Session revoked successfully",
+	})
+}
+
+// RevokeAllSessions signs the authenticated user out on every device
+func RevokeAllSessions(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	if err := utils.RevokeUserSessions(userClaims.UserID, ""); err != nil {
+		log.Printf("Error: Failed to revoke sessions - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to revoke sessions",
+		})
+	}
+	utils.ClearAuthCookies(c)
+	utils.RecordAuditEvent(c, models.AuditSessionRevoked, userClaims.UserID, userClaims.UserID, models.AuditResultSuccess, map[string]string{"session_id": "all"})
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Signed out of all sessions",
+	})
+}
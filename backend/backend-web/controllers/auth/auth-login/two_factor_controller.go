@@ -0,0 +1,474 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// getClaimsUser loads the authenticated user. On failure the error response
+// has already been written and the returned user is nil.
+func getClaimsUser(c *fiber.Ctx) (*models.User, error) {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return nil, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+	objID, err := primitive.ObjectIDFromHex(userClaims.UserID)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID format",
+		})
+	}
+
+	user, err := getUserByField("_id", objID)
+	if err != nil {
+		log.Println("Database error:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+	if user == nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+	return user, nil
+}
+
+// EnrollTwoFactor generates a new TOTP secret for the user. It is only
+// enabled once ConfirmTwoFactor receives a valid code from the app.
+func EnrollTwoFactor(c *fiber.Ctx) error {
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+	if user.TwoFactorEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		log.Println("Error generating TOTP secret:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to start two-factor enrollment",
+		})
+	}
+
+	// Only the sealed secret is stored; the plaintext is shown once below
+	sealed, err := utils.SealTOTPSecret(user.Id.Hex(), secret)
+	if err != nil {
+		log.Println("Error encrypting TOTP secret:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to start two-factor enrollment",
+		})
+	}
+	users := configs.GetCollection(configs.DB, "users")
+	_, err = users.UpdateOne(context.TODO(), bson.M{"_id": user.Id}, bson.M{"$set": bson.M{"twoFactorPending": sealed}})
+	if err != nil {
+		log.Println("Error saving TOTP secret:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to start two-factor enrollment",
+		})
+	}
+
+	// The otpauth URI is the QR code payload; the secret is for manual entry
+	uri := utils.TOTPURI(utils.TOTPIssuer, user.Email, secret)
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Scan the QR code with your authenticator app, then confirm with a code",
+		"data": fiber.Map{
+			"secret":     secret,
+			"otpauthUrl": uri,
+			"qrPayload":  uri,
+		},
+	})
+}
+
+// ConfirmTwoFactor enables 2FA after the user proves their app produces valid
+// codes, and returns the one-time recovery codes. They are shown only once.
+func ConfirmTwoFactor(c *fiber.Ctx) error {
+	type ConfirmInput struct {
+		Code string `json:"code"`
+	}
+	input := new(ConfirmInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+	if user.TwoFactorEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+	if user.TwoFactorPending == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Start two-factor enrollment first",
+		})
+	}
+
+	secret, err := utils.OpenTOTPSecret(user.Id.Hex(), user.TwoFactorPending)
+	if err != nil {
+		log.Println("Error decrypting TOTP secret:", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Start two-factor enrollment again",
+		})
+	}
+	step, valid := utils.ValidateTOTP(secret, input.Code, time.Now())
+	if !valid {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid verification code",
+		})
+	}
+
+	codes, hashes, err := utils.GenerateRecoveryCodes()
+	if err != nil {
+		log.Println("Error generating recovery codes:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to enable two-factor authentication",
+		})
+	}
+
+	// The pending secret is sealed to the same user, so it moves over as is
+	users := configs.GetCollection(configs.DB, "users")
+	result, err := users.UpdateOne(context.TODO(),
+		bson.M{"_id": user.Id, "twoFactorPending": user.TwoFactorPending},
+		bson.M{
+			"$set": bson.M{
+				"twoFactorEnabled":  true,
+				"twoFactorSecret":   user.TwoFactorPending,
+				"twoFactorLastStep": step,
+				"recoveryCodes":     hashes,
+			},
+			"$unset": bson.M{"twoFactorPending": ""},
+		},
+	)
+	if err != nil {
+		log.Println("Error enabling two-factor authentication:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to enable two-factor authentication",
+		})
+	}
+	if result.ModifiedCount == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Enrollment changed, please start again",
+		})
+	}
+
+	utils.RecordAuditEvent(c, models.AuditTwoFactorEnabled, user.Id.Hex(), user.Id.Hex(), models.AuditResultSuccess, nil)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Two-factor authentication enabled. Store your recovery codes somewhere safe.",
+		"data":    fiber.Map{"recoveryCodes": codes},
+	})
+}
+
+// verifyTwoFactorInput checks the password (for accounts that have one) and a
+// second factor before a 2FA setting changes. Wrong passwords count toward
+// login throttling and wrong codes toward their own counter, which a correct
+// password does not reset. On failure the error response has already been
+// written and false is returned.
+func verifyTwoFactorInput(c *fiber.Ctx, user *models.User, password, code string) (bool, error) {
+	if !user.TwoFactorEnabled {
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Two-factor authentication is not enabled",
+		})
+	}
+	if user.Password != "" {
+		if ok, err := checkCurrentPassword(c, user, password); !ok {
+			return false, err
+		}
+	}
+
+	account := user.Id.Hex()
+	if ok, err := checkAttemptThrottle(c, attemptScopeTwoFactor, account); !ok {
+		return false, err
+	}
+	valid, err := utils.VerifySecondFactor(user, code)
+	if err != nil {
+		releaseAttempt(attemptScopeTwoFactor, account)
+		log.Println("Error verifying second factor:", err)
+		return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+	if !valid {
+		recordFailedAttempt(c, attemptScopeTwoFactor, account, user)
+		return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid verification code",
+		})
+	}
+	clearFailedAttempts(attemptScopeTwoFactor, account)
+	return true, nil
+}
+
+// DisableTwoFactor turns 2FA off. It needs the password and a current code or
+// recovery code, and is refused while an organization requires 2FA.
+func DisableTwoFactor(c *fiber.Ctx) error {
+	type DisableInput struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	input := new(DisableInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+	if ok, err := verifyTwoFactorInput(c, user, input.Password, input.Code); !ok {
+		return err
+	}
+
+	orgIDs, err := utils.UserOrgIDs(user.Id.Hex(), models.OrgRoleViewer)
+	if err != nil {
+		log.Println("Error listing organizations:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to disable two-factor authentication",
+		})
+	}
+	objIDs := make([]primitive.ObjectID, 0, len(orgIDs))
+	for _, id := range orgIDs {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+	orgs := configs.GetCollection(configs.DB, "organizations")
+	required, err := orgs.CountDocuments(context.TODO(), bson.M{"_id": bson.M{"$in": objIDs}, "require2FA": true})
+	if err != nil {
+		log.Println("Error checking organization 2FA requirement:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to disable two-factor authentication",
+		})
+	}
+	if required > 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "An organization you belong to requires two-factor authentication",
+		})
+	}
+
+	users := configs.GetCollection(configs.DB, "users")
+	_, err = users.UpdateOne(context.TODO(), bson.M{"_id": user.Id}, bson.M{"$unset": bson.M{
+		"twoFactorEnabled":  "",
+		"twoFactorSecret":   "",
+		"twoFactorPending":  "",
+		"twoFactorLastStep": "",
+		"recoveryCodes":     "",
+	}})
+	if err != nil {
+		log.Println("Error disabling two-factor authentication:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to disable two-factor authentication",
+		})
+	}
+
+	utils.RecordAuditEvent(c, models.AuditTwoFactorDisabled, user.Id.Hex(), user.Id.Hex(), models.AuditResultSuccess, nil)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes with a new set
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	type RegenerateInput struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	input := new(RegenerateInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	user, err := getClaimsUser(c)
+	if user == nil {
+		return err
+	}
+	if ok, err := verifyTwoFactorInput(c, user, input.Password, input.Code); !ok {
+		return err
+	}
+
+	codes, hashes, err := utils.GenerateRecoveryCodes()
+	if err != nil {
+		log.Println("Error generating recovery codes:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to regenerate recovery codes",
+		})
+	}
+	users := configs.GetCollection(configs.DB, "users")
+	_, err = users.UpdateOne(context.TODO(), bson.M{"_id": user.Id}, bson.M{"$set": bson.M{"recoveryCodes": hashes}})
+	if err != nil {
+		log.Println("Error saving recovery codes:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to regenerate recovery codes",
+		})
+	}
+
+	utils.RecordAuditEvent(c, models.AuditRecoveryCodesRenewed, user.Id.Hex(), user.Id.Hex(), models.AuditResultSuccess, nil)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Recovery codes regenerated. Previous codes no longer work.",
+		"data":    fiber.Map{"recoveryCodes": codes},
+	})
+}
+
+// LoginTwoFactor completes a login that returned an MFA challenge. It takes
+// the challenge token and a TOTP or recovery code and issues the real tokens.
+func LoginTwoFactor(c *fiber.Ctx) error {
+	type LoginTwoFactorInput struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	input := new(LoginTwoFactorInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	challenge, err := utils.GetMFAChallenge(input.MFAToken)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidMFAChallenge) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid or expired MFA token. Please log in again.",
+			})
+		}
+		log.Println("Database error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+
+	objID, err := primitive.ObjectIDFromHex(challenge.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid or expired MFA token. Please log in again.",
+		})
+	}
+	user, err := getUserByField("_id", objID)
+	if err != nil {
+		log.Println("Database error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+	if user == nil || user.Disabled {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid or expired MFA token. Please log in again.",
+		})
+	}
+
+	if ok, err := checkAttemptThrottle(c, attemptScopeLogin, challenge.UserID); !ok {
+		return err
+	}
+
+	valid, err := utils.VerifySecondFactor(user, input.Code)
+	if err != nil {
+		log.Println("Error verifying second factor:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+	if !valid {
+		if err := utils.RecordMFAFailure(challenge.ID); err != nil {
+			log.Println("Error recording MFA failure:", err)
+		}
+		recordFailedAttempt(c, attemptScopeLogin, challenge.UserID, user)
+		auditLogin(c, user, models.AuditResultFailure, map[string]string{"method": "2fa", "reason": "invalid_code"})
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid verification code",
+		})
+	}
+	if err := utils.CompleteMFAChallenge(challenge.ID); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid or expired MFA token. Please log in again.",
+		})
+	}
+	clearFailedAttempts(attemptScopeLogin, challenge.UserID)
+
+	pair, err := utils.IssueTokenPair(c, user, "")
+	if err != nil {
+		log.Println("Token issuing error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Internal Server Error",
+		})
+	}
+
+	auditLogin(c, user, models.AuditResultSuccess, map[string]string{"method": "2fa"})
+
+	return c.JSON(fiber.Map{
+		"status":        "success",
+		"message":       "Login successful",
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
+	})
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// uniformResponseFloor is the shortest time a uniform response takes, so the
+// account lookup and the branch taken after it do not show in response times
+const uniformResponseFloor = 400 * time.Millisecond
+
+// Messages returned in uniform mode whether or not the email is registered
+const (
+	uniformResetMessage        = "If a verified account exists for this email, a password reset code has been sent."
+	uniformVerificationMessage = "If this email is waiting for verification, a new code has been sent."
+	uniformRegisterMessage     = "Registration successful. Please check your email for the verification code."
+	uniformEmailChangeMessage  = "If this email can be used, a verification code has been sent to it."
+)
+
+// The user insert and the emails sent by registration and code requests are
+// variables so tests can run these handlers without a database or mail
+// transport
+var (
+	insertUser = func(user models.User) error {
+		_, err := configs.GetCollection(configs.DB, "users").InsertOne(context.TODO(), user)
+		return err
+	}
+	sendAccountExistsNotice = utils.SendAccountExistsEmail
+	sendVerificationCode    = func(email, locale string) error {
+		code, err := utils.IssueOTP(utils.OTPPurposeVerifyEmail, email)
+		if err != nil {
+			return err
+		}
+		return utils.SendVerificationEmail(email, locale, code)
+	}
+	resendVerificationCode = refreshVerificationCode
+	sendResetCode          = func(email, locale string) error {
+		otp, err := utils.IssueOTP(utils.OTPPurposeResetPassword, email)
+		if err != nil {
+			return err
+		}
+		return utils.SendPasswordResetOTPEmail(email, locale, otp)
+	}
+	sendEmailChangeCode = func(email, locale string) error {
+		otp, err := utils.IssueOTP(utils.OTPPurposeChangeEmail, email)
+		if err != nil {
+			return err
+		}
+		return utils.SendVerificationEmail(email, locale, otp)
+	}
+)
+
+// uniformResponses reports whether AUTH_UNIFORM_RESPONSES is on. In that mode
+// registration and code requests never reveal whether an email is registered;
+// the outcome is sent by email in the background instead.
+func uniformResponses() bool {
+	return configs.EnvAuthUniformResponses()
+}
+
+// respondUniform writes a success response once uniformResponseFloor has
+// passed since started
+func respondUniform(c *fiber.Ctx, started time.Time, message string) error {
+	if wait := uniformResponseFloor - time.Since(started); wait > 0 {
+		time.Sleep(wait)
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+	})
+}
@@ -0,0 +1,331 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const testPassword = "Kale-Gr0wth-2024!"
+
+// TestMain gives the handlers a client that is never connected, so any query
+// a test does not stub fails right away instead of needing a database
+func TestMain(m *testing.M) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	configs.DB = client
+	os.Exit(m.Run())
+}
+
+// stubUsers makes getUserByField look users up by email or username in users
+func stubUsers(t *testing.T, users ...models.User) {
+	t.Helper()
+	original := getUserByField
+	t.Cleanup(func() { getUserByField = original })
+
+	getUserByField = func(field string, value interface{}) (*models.User, error) {
+		for _, user := range users {
+			if (field == "email" && user.Email == value) || (field == "username" && user.Username == value) {
+				found := user
+				return &found, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// stubSends records the user inserts and emails of the handlers under test as
+// "<kind> <email>" on the returned channel
+func stubSends(t *testing.T) <-chan string {
+	t.Helper()
+	sent := make(chan string, 10)
+	originalInsert, originalExists := insertUser, sendAccountExistsNotice
+	originalVerify, originalResend, originalReset := sendVerificationCode, resendVerificationCode, sendResetCode
+	t.Cleanup(func() {
+		insertUser, sendAccountExistsNotice = originalInsert, originalExists
+		sendVerificationCode, resendVerificationCode, sendResetCode = originalVerify, originalResend, originalReset
+	})
+
+	record := func(kind string) func(email, locale string) error {
+		return func(email, locale string) error {
+			sent <- kind + " " + email
+			return nil
+		}
+	}
+	insertUser = func(user models.User) error {
+		sent <- "insert " + user.Email
+		return nil
+	}
+	sendAccountExistsNotice = record("account_exists")
+	sendVerificationCode = record("verify")
+	resendVerificationCode = record("resend_verify")
+	sendResetCode = record("reset")
+	return sent
+}
+
+// expectSends checks that exactly the given actions happen, in order
+func expectSends(t *testing.T, sent <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-sent:
+			if got != w {
+				t.Fatalf("got %q, want %q", got, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", w)
+		}
+	}
+	select {
+	case got := <-sent:
+		t.Fatalf("unexpected %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func setUniform(t *testing.T, on bool) {
+	t.Helper()
+	if on {
+		t.Setenv("AUTH_UNIFORM_RESPONSES", "true")
+	} else {
+		t.Setenv("AUTH_UNIFORM_RESPONSES", "false")
+	}
+	t.Setenv("BREACHED_PASSWORDS_DIR", "off")
+}
+
+// post calls handler with a JSON body and returns the status and decoded response
+func post(t *testing.T, handler fiber.Handler, body string) (int, fiber.Map) {
+	t.Helper()
+	app := fiber.New()
+	app.Post("/", handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var data fiber.Map
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+func verifiedUser() models.User {
+	return models.User{Id: primitive.NewObjectID(), Username: "farmer", Email: "farmer@example.com", EmailVerified: true}
+}
+
+func unverifiedUser() models.User {
+	return models.User{Id: primitive.NewObjectID(), Username: "grower", Email: "grower@example.com"}
+}
+
+func TestRespondUniformWaitsForFloor(t *testing.T) {
+	app := fiber.New()
+	app.Get("/fresh", func(c *fiber.Ctx) error { return respondUniform(c, time.Now(), "ok") })
+	app.Get("/late", func(c *fiber.Ctx) error { return respondUniform(c, time.Now().Add(-time.Second), "ok") })
+
+	start := time.Now()
+	if _, err := app.Test(httptest.NewRequest(http.MethodGet, "/fresh", nil), 5000); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < uniformResponseFloor {
+		t.Errorf("fresh request answered after %v, want at least %v", elapsed, uniformResponseFloor)
+	}
+
+	start = time.Now()
+	if _, err := app.Test(httptest.NewRequest(http.MethodGet, "/late", nil), 5000); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= uniformResponseFloor {
+		t.Errorf("late request waited %v, want no extra wait", elapsed)
+	}
+}
+
+func TestRegisterExistingEmail(t *testing.T) {
+	body := `{"username":"newname","email":"farmer@example.com","password":"` + testPassword + `"}`
+
+	t.Run("reported when uniform responses are off", func(t *testing.T) {
+		setUniform(t, false)
+		stubUsers(t, verifiedUser())
+		sent := stubSends(t)
+
+		status, data := post(t, Register, body)
+		if status != fiber.StatusBadRequest || data["message"] != "Email already in use" {
+			t.Fatalf("got %d %v", status, data)
+		}
+		expectSends(t, sent)
+	})
+
+	t.Run("verified account gets the account exists notice", func(t *testing.T) {
+		setUniform(t, true)
+		stubUsers(t, verifiedUser())
+		sent := stubSends(t)
+
+		status, data := post(t, Register, body)
+		if status != fiber.StatusOK || data["message"] != uniformRegisterMessage {
+			t.Fatalf("got %d %v", status, data)
+		}
+		expectSends(t, sent, "account_exists farmer@example.com")
+	})
+
+	t.Run("unverified account gets a new code", func(t *testing.T) {
+		setUniform(t, true)
+		stubUsers(t, unverifiedUser())
+		sent := stubSends(t)
+
+		body := `{"username":"newname","email":"grower@example.com","password":"` + testPassword + `"}`
+		status, data := post(t, Register, body)
+		if status != fiber.StatusOK || data["message"] != uniformRegisterMessage {
+			t.Fatalf("got %d %v", status, data)
+		}
+		expectSends(t, sent, "resend_verify grower@example.com")
+	})
+}
+
+func TestRegisterNewAccount(t *testing.T) {
+	body := `{"username":"newname","email":"new@example.com","password":"` + testPassword + `"}`
+
+	for _, uniform := range []bool{false, true} {
+		setUniform(t, uniform)
+		stubUsers(t)
+		sent := stubSends(t)
+
+		status, data := post(t, Register, body)
+		if status != fiber.StatusOK || data["status"] != "success" {
+			t.Fatalf("uniform=%v: got %d %v", uniform, status, data)
+		}
+		if uniform && data["message"] != uniformRegisterMessage {
+			t.Errorf("uniform=%v: got message %v", uniform, data["message"])
+		}
+		expectSends(t, sent, "insert new@example.com", "verify new@example.com")
+	}
+}
+
+func TestRegisterTakenUsernameIsAlwaysReported(t *testing.T) {
+	setUniform(t, true)
+	stubUsers(t, verifiedUser())
+	sent := stubSends(t)
+
+	body := `{"username":"farmer","email":"other@example.com","password":"` + testPassword + `"}`
+	status, data := post(t, Register, body)
+	if status != fiber.StatusBadRequest || data["message"] != "Username already taken" {
+		t.Fatalf("got %d %v", status, data)
+	}
+	expectSends(t, sent)
+}
+
+func TestForgotPassword(t *testing.T) {
+	t.Run("unknown email is reported when uniform responses are off", func(t *testing.T) {
+		setUniform(t, false)
+		stubUsers(t)
+		sent := stubSends(t)
+
+		status, data := post(t, ForgotPassword, `{"email":"nobody@example.com"}`)
+		if status != fiber.StatusNotFound {
+			t.Fatalf("got %d %v", status, data)
+		}
+		expectSends(t, sent)
+	})
+
+	t.Run("unverified email is reported when uniform responses are off", func(t *testing.T) {
+		setUniform(t, false)
+		stubUsers(t, unverifiedUser())
+		sent := stubSends(t)
+
+		status, data := post(t, ForgotPassword, `{"email":"grower@example.com"}`)
+		if status != fiber.StatusForbidden {
+			t.Fatalf("got %d %v", status, data)
+		}
+		expectSends(t, sent)
+	})
+
+	cases := []struct {
+		name  string
+		email string
+		want  []string
+	}{
+		{"unknown email", "nobody@example.com", nil},
+		{"unverified email", "grower@example.com", nil},
+		{"verified email", "farmer@example.com", []string{"reset farmer@example.com"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name+" gets the uniform response", func(t *testing.T) {
+			setUniform(t, true)
+			stubUsers(t, verifiedUser(), unverifiedUser())
+			sent := stubSends(t)
+
+			status, data := post(t, ForgotPassword, `{"email":"`+tc.email+`"}`)
+			if status != fiber.StatusOK || data["message"] != uniformResetMessage {
+				t.Fatalf("got %d %v", status, data)
+			}
+			expectSends(t, sent, tc.want...)
+		})
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	t.Run("unknown email is reported when uniform responses are off", func(t *testing.T) {
+		setUniform(t, false)
+		stubUsers(t)
+		sent := stubSends(t)
+
+		status, data := post(t, ResendVerification, `{"email":"nobody@example.com"}`)
+		if status != fiber.StatusNotFound {
+			t.Fatalf("got %d %v", status, data)
+		}
+		expectSends(t, sent)
+	})
+
+	t.Run("verified email is reported when uniform responses are off", func(t *testing.T) {
+		setUniform(t, false)
+		stubUsers(t, verifiedUser())
+		sent := stubSends(t)
+
+		status, data := post(t, ResendVerification, `{"email":"farmer@example.com"}`)
+		if status != fiber.StatusBadRequest || data["message"] != "Email already verified" {
+			t.Fatalf("got %d %v", status, data)
+		}
+		expectSends(t, sent)
+	})
+
+	cases := []struct {
+		name  string
+		email string
+		want  []string
+	}{
+		{"unknown email", "nobody@example.com", nil},
+		{"verified email", "farmer@example.com", nil},
+		{"unverified email", "grower@example.com", []string{"resend_verify grower@example.com"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name+" gets the uniform response", func(t *testing.T) {
+			setUniform(t, true)
+			stubUsers(t, verifiedUser(), unverifiedUser())
+			sent := stubSends(t)
+
+			status, data := post(t, ResendVerification, `{"email":"`+tc.email+`"}`)
+			if status != fiber.StatusOK || data["message"] != uniformVerificationMessage {
+				t.Fatalf("got %d %v", status, data)
+			}
+			expectSends(t, sent, tc.want...)
+		})
+	}
+}
@@ -2,115 +2,281 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"log"
+	"net/url"
+	"strings"
 	"time"
 
 	"backend-web/configs"
 	"backend-web/models"
+	"backend-web/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/oauth2"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// defaultOAuthProvider serves the provider-less /oauth and /callback routes
+// kept for existing Google client registrations
+const defaultOAuthProvider = "google"
+
+// getOAuthProvider resolves the :provider route parameter. On failure the
+// error response has already been written and the returned provider is nil.
+func getOAuthProvider(c *fiber.Ctx) (*utils.OAuthProvider, error) {
+	name := c.Params("provider", defaultOAuthProvider)
+	provider, err := utils.GetOAuthProvider(name)
+	if err != nil {
+		if errors.Is(err, utils.ErrUnknownOAuthProvider) {
+			return nil, c.Status(fiber.StatusNotFound).SendString("Unknown login provider: " + name)
+		}
+		log.Println("OAuth provider error:", err)
+		return nil, c.Status(fiber.StatusBadGateway).SendString("Login provider is unavailable")
+	}
+	return provider, nil
+}
+
+// GetOAuthProviders lists the configured login providers for the login page
+func GetOAuthProviders(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "OAuth providers",
+		"data":    utils.OAuthProviderNames(),
+	})
+}
+
 func OAuthLoginHandler(c *fiber.Ctx) error {
-	url := configs.GoogleOAuthConfig().AuthCodeURL("random-state-token", oauth2.AccessTypeOffline)
-	return c.Redirect(url)
+	provider, err := getOAuthProvider(c)
+	if provider == nil {
+		return err
+	}
+
+	returnTo, err := utils.ResolveReturnTo(c.Query("return_to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid return_to")
+	}
+
+	state, flow, err := utils.CreateOAuthFlow(provider.Name, returnTo, "")
+	if err != nil {
+		log.Println("OAuth flow error:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to start login")
+	}
+	utils.SetOAuthStateCookie(c, state, provider.Name)
+	return c.Redirect(provider.AuthCodeURL(state, flow.Nonce, flow.CodeVerifier))
 }
 
 func OAuthCallbackHandler(c *fiber.Ctx) error {
-    code := c.Query("code")
-    if code == "" {
-        return c.Status(fiber.StatusBadRequest).SendString("Missing code")
-    }
-
-    // Exchange code for token
-    token, err := configs.GoogleOAuthConfig().Exchange(context.Background(), code)
-    if err != nil {
-        log.Println("Token exchange error:", err)
-        return c.Status(fiber.StatusBadRequest).SendString("Token exchange error: " + err.Error())
-    }
-
-    client := configs.GoogleOAuthConfig().Client(context.Background(), token)
-    resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
-    if err != nil {
-        log.Println("User info error:", err)
-        return c.Status(fiber.StatusInternalServerError).SendString("User info error: " + err.Error())
-    }
-    defer resp.Body.Close()
-
-    var userData struct {
-        ID      string `json:"id"`
-        Email   string `json:"email"`
-        Name    string `json:"name"`
-        Picture string `json:"picture"`
-    }
-    if err := json.NewDecoder(resp.Body).Decode(&userData); err != nil {
-        log.Println("JSON decode error:", err)
-        return c.Status(fiber.StatusInternalServerError).SendString("JSON decode error: " + err.Error())
-    }
-
-    userCollection := configs.GetCollection(configs.DB, "users")
-    var existingUser models.User
-    err = userCollection.FindOne(context.TODO(), bson.M{"email": userData.Email}).Decode(&existingUser)
-
-    if err != nil {
-        newUser := models.User{
-            Id:            primitive.NewObjectID(),
-            Username:      userData.Name,
-            Password:      "",
-            Email:         userData.Email,
-            EmailVerified: true,
-            CreatedAt:     time.Now(),
-        }
-
-        _, insertErr := userCollection.InsertOne(context.TODO(), newUser)
-        if insertErr != nil {
-            log.Println("Error inserting new user:", insertErr)
-            return c.Status(fiber.StatusInternalServerError).SendString("Failed to create user")
-        }
-        existingUser = newUser
-    }
-
-    userID := existingUser.Id.Hex()
-
-    // Generate JWT token
-    jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-        "email":    userData.Email,
-        "username": userData.Name,
-        "exp":      time.Now().Add(time.Hour * 72).Unix(),
-        "user_id":  userID,
-    })
-    tokenString, err := jwtToken.SignedString([]byte(configs.EnvSecret()))
-    if err != nil {
-        log.Println("JWT signing error:", err)
-        return c.Status(fiber.StatusInternalServerError).SendString("JWT signing error: " + err.Error())
-    }
-
-    // Set cookie
-    c.Cookie(&fiber.Cookie{
-        Name:     "token",
-        Value:    tokenString,
-        Expires:  time.Now().Add(time.Hour * 72),
-        HTTPOnly: false,
-        Secure:   false,
-        SameSite: "Lax",
-    })
-
-    // Set refresh token cookie (optional)
-    if refreshToken := token.RefreshToken; refreshToken != "" {
-        c.Cookie(&fiber.Cookie{
-            Name:     "refresh_token",
-            Value:    refreshToken,
-            Expires:  time.Now().Add(time.Hour * 24 * 30),
-            HTTPOnly: true,
-            Secure:   false,
-            SameSite: "Lax",
-        })
-    }
-
-    return c.Redirect("http://localhost:3000/auth/oauth-callback")
-}
\ No newline at end of file
+	provider, err := getOAuthProvider(c)
+	if provider == nil {
+		return err
+	}
+
+	// The state must match the cookie set for this browser at login
+	state := c.Query("state")
+	if err := utils.VerifyOAuthStateCookie(c, state, provider.Name); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid or expired login request. Please try again.")
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		return c.Status(fiber.StatusBadRequest).SendString("Login was cancelled or failed: " + providerErr)
+	}
+
+	flow, err := utils.ConsumeOAuthFlow(state, provider.Name)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidOAuthState) {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid or expired login request. Please try again.")
+		}
+		log.Println("OAuth flow error:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to finish login")
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("Missing code")
+	}
+
+	// Exchange the code and validate the ID token or provider profile
+	profile, err := provider.Exchange(context.Background(), code, flow.CodeVerifier, flow.Nonce)
+	if err != nil {
+		if errors.Is(err, utils.ErrOAuthEmailUnverified) {
+			return c.Status(fiber.StatusBadRequest).SendString("Your " + provider.Name + " account has no verified email address")
+		}
+		log.Println("OAuth exchange error:", err)
+		return c.Status(fiber.StatusBadRequest).SendString("Login with " + provider.Name + " failed")
+	}
+
+	if flow.LinkUserID != "" {
+		return finishIdentityLink(c, flow, profile)
+	}
+
+	existingUser, err := resolveOAuthUser(c, flow, profile)
+	if existingUser == nil {
+		return err
+	}
+
+	userID := existingUser.Id.Hex()
+	if existingUser.Disabled {
+		utils.RecordAuditEvent(c, models.AuditLogin, userID, userID, models.AuditResultFailure, map[string]string{
+			"method": provider.Name,
+			"reason": "account_disabled",
+		})
+		return c.Status(fiber.StatusForbidden).SendString("Account is disabled")
+	}
+
+	// Accounts with 2FA finish signing in on the frontend's code prompt
+	if existingUser.TwoFactorEnabled {
+		mfaToken, err := utils.CreateMFAChallenge(existingUser.Id.Hex())
+		if err != nil {
+			log.Println("MFA challenge error:", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("MFA challenge error: " + err.Error())
+		}
+		return c.Redirect(configs.EnvFrontendURL() + "/auth/two-factor?mfa_token=" + url.QueryEscape(mfaToken) +
+			"&return_to=" + url.QueryEscape(flow.ReturnTo))
+	}
+
+	// Issue the same access/refresh pair as password login
+	pair, err := utils.IssueTokenPair(c, existingUser, "")
+	if err != nil {
+		log.Println("Token issuing error:", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Token issuing error: " + err.Error())
+	}
+
+	// Set cookies
+	utils.SetAuthCookies(c, pair)
+	utils.RecordAuditEvent(c, models.AuditLogin, userID, userID, models.AuditResultSuccess, map[string]string{"method": provider.Name})
+
+	return c.Redirect(flow.ReturnTo)
+}
+
+// resolveOAuthUser finds the account for a provider login. Linked identities
+// win; a new email gets a new account. An email that belongs to a password
+// account is only linked after its owner confirms with the password. On
+// failure or redirect the response has already been written and the
+// returned user is nil.
+func resolveOAuthUser(c *fiber.Ctx, flow *models.OAuthFlow, profile *utils.OAuthProfile) (*models.User, error) {
+	identity, err := utils.FindIdentity(profile.Provider, profile.Subject)
+	if err != nil {
+		log.Println("Error finding identity:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).SendString("Failed to finish login")
+	}
+	if identity != nil {
+		if objID, err := primitive.ObjectIDFromHex(identity.UserID); err == nil {
+			user, err := findUser(bson.M{"_id": objID})
+			if err != nil {
+				log.Println("Error finding user:", err)
+				return nil, c.Status(fiber.StatusInternalServerError).SendString("Failed to finish login")
+			}
+			if user != nil {
+				return user, nil
+			}
+		}
+	}
+
+	existingUser, err := findUser(bson.M{"email": profile.Email})
+	if err != nil {
+		log.Println("Error finding user:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).SendString("Failed to finish login")
+	}
+
+	if existingUser == nil {
+		localPart, _, _ := strings.Cut(profile.Email, "@")
+		username, err := utils.GenerateUniqueUsername(profile.Username, profile.Name, localPart)
+		if err != nil {
+			log.Println("Error generating username:", err)
+			return nil, c.Status(fiber.StatusInternalServerError).SendString("Failed to create user")
+		}
+		newUser := models.User{
+			Id:            primitive.NewObjectID(),
+			Username:      username,
+			Password:      "",
+			Email:         profile.Email,
+			EmailVerified: true,
+			CreatedAt:     time.Now(),
+		}
+
+		userCollection := configs.GetCollection(configs.DB, "users")
+		_, insertErr := userCollection.InsertOne(context.TODO(), newUser)
+		if insertErr != nil {
+			log.Println("Error inserting new user:", insertErr)
+			return nil, c.Status(fiber.StatusInternalServerError).SendString("Failed to create user")
+		}
+		if err := utils.LinkIdentity(newUser.Id.Hex(), profile); err != nil {
+			log.Println("Error linking identity:", err)
+		} else {
+			utils.RecordAuditEvent(c, models.AuditIdentityLinked, newUser.Id.Hex(), newUser.Id.Hex(), models.AuditResultSuccess, map[string]string{"provider": profile.Provider})
+		}
+		return &newUser, nil
+	}
+
+	// Accounts created by Google login before identities existed have no
+	// password and no identity; claim them on their next Google login
+	if existingUser.Password == "" && profile.Provider == defaultOAuthProvider {
+		identities, err := utils.UserIdentities(existingUser.Id.Hex())
+		if err != nil {
+			log.Println("Error fetching identities:", err)
+			return nil, c.Status(fiber.StatusInternalServerError).SendString("Failed to finish login")
+		}
+		if len(identities) == 0 {
+			if err := utils.LinkIdentity(existingUser.Id.Hex(), profile); err != nil {
+				log.Println("Error linking identity:", err)
+				return nil, c.Status(fiber.StatusInternalServerError).SendString("Failed to finish login")
+			}
+			utils.RecordAuditEvent(c, models.AuditIdentityLinked, existingUser.Id.Hex(), existingUser.Id.Hex(), models.AuditResultSuccess, map[string]string{"provider": profile.Provider})
+			return existingUser, nil
+		}
+	}
+
+	// Never merge silently: the owner confirms with their password, or signs
+	// in the usual way and links this provider from their settings
+	if existingUser.Password == "" {
+		return nil, c.Redirect(withQuery(configs.EnvFrontendURL()+"/auth/login", "link_error", "account_exists"))
+	}
+	linkToken, err := utils.CreateIdentityLinkRequest(existingUser.Id.Hex(), profile, flow.ReturnTo)
+	if err != nil {
+		log.Println("Error creating link request:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).SendString("Failed to finish login")
+	}
+	return nil, c.Redirect(configs.EnvFrontendURL() + "/auth/link-account?link_token=" + url.QueryEscape(linkToken) +
+		"&provider=" + url.QueryEscape(profile.Provider))
+}
+
+// finishIdentityLink completes a flow started by StartIdentityLink and sends
+// the browser back with the outcome in the query string
+func finishIdentityLink(c *fiber.Ctx, flow *models.OAuthFlow, profile *utils.OAuthProfile) error {
+	err := utils.LinkIdentity(flow.LinkUserID, profile)
+	if err != nil {
+		if errors.Is(err, utils.ErrIdentityLinked) {
+			return c.Redirect(withQuery(flow.ReturnTo, "link_error", "already_linked"))
+		}
+		log.Println("Error linking identity:", err)
+		return c.Redirect(withQuery(flow.ReturnTo, "link_error", "failed"))
+	}
+	utils.RecordAuditEvent(c, models.AuditIdentityLinked, flow.LinkUserID, flow.LinkUserID, models.AuditResultSuccess, map[string]string{"provider": profile.Provider})
+	return c.Redirect(withQuery(flow.ReturnTo, "linked", profile.Provider))
+}
+
+// findUser returns the user matching the filter, or nil if none
+func findUser(filter bson.M) (*models.User, error) {
+	var user models.User
+	err := configs.GetCollection(configs.DB, "users").FindOne(context.TODO(), filter).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// withQuery adds a query parameter to a URL that was validated earlier
+func withQuery(rawURL, key, value string) string {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := target.Query()
+	query.Set(key, value)
+	target.RawQuery = query.Encode()
+	return target.String()
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListEmailTemplates lists the email templates that can be previewed
+func ListEmailTemplates(c *fiber.Ctx) error {
+	names, err := utils.EmailTemplateNames()
+	if err != nil {
+		log.Println("Error loading email templates:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to load email templates",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Email templates",
+		"data": fiber.Map{
+			"templates": names,
+			"locales":   []string{utils.LocaleEnglish, utils.LocaleThai},
+		},
+	})
+}
+
+// PreviewEmailTemplate renders a template with sample data. ?locale picks the
+// language and ?format is html (default), text or json.
+func PreviewEmailTemplate(c *fiber.Ctx) error {
+	name := c.Params("name")
+	locale := utils.ResolveLocale(c.Query("locale"))
+	rendered, err := utils.RenderEmail(name, locale, utils.EmailPreviewData(name, locale))
+	if err != nil {
+		if errors.Is(err, utils.ErrUnknownEmailTemplate) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Email template not found",
+			})
+		}
+		log.Println("Error rendering email template:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to render email template: " + err.Error(),
+		})
+	}
+
+	switch c.Query("format", "html") {
+	case "text":
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendString("Subject: " + rendered.Subject + "\n\n" + rendered.Text)
+	case "json":
+		return c.JSON(fiber.Map{
+			"status":  "success",
+			"message": "Email preview",
+			"data": fiber.Map{
+				"template": name,
+				"locale":   locale,
+				"subject":  rendered.Subject,
+				"text":     rendered.Text,
+				"html":     rendered.HTML,
+			},
+		})
+	default:
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.SendString(rendered.HTML)
+	}
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+const eventStreamHeartbeat = 25 * time.Second
+
+// writeStreamEvent writes one event in text/event-stream format
+func writeStreamEvent(w *bufio.Writer, event utils.StreamEvent) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// streamCredentialValid checks the credential a stream was opened with again.
+// A database error on an API key check keeps the stream open.
+func streamCredentialValid(apiKey, token, ip string) bool {
+	if apiKey != "" {
+		_, err := utils.VerifyAPIKey(apiKey, ip)
+		if err != nil && !errors.Is(err, utils.ErrInvalidAPIKey) {
+			log.Println("Error verifying API key:", err)
+			return true
+		}
+		return err == nil
+	}
+	_, err := utils.VerifyToken(token)
+	return err == nil
+}
+
+// StreamEvents pushes real-time events for the authenticated user using
+// server-sent events. Clients resume with the Last-Event-ID header (sent
+// automatically by EventSource) or the lastEventId query parameter. The
+// credential is checked again on every heartbeat, so logging out, revoking
+// the session or disabling the account also closes open streams.
+func StreamEvents(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	lastEventID := c.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	lastID, _ := strconv.ParseInt(lastEventID, 10, 64)
+
+	// The request buffers are reused once the handler returns, so the
+	// credential is copied for the stream writer
+	apiKey := strings.Clone(c.Get("X-API-Key"))
+	token := strings.Clone(utils.ExtractToken(c))
+	ip := c.IP()
+
+	userID := userClaims.UserID
+	replay, events, unsubscribe := utils.SubscribeEvents(userID, lastID)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		// Ask EventSource to wait 3s before reconnecting
+		fmt.Fprint(w, "retry: 3000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for _, event := range replay {
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(eventStreamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event := <-events:
+				if err := writeStreamEvent(w, event); err != nil {
+					log.Printf("Event stream for user %s closed: %v", userID, err)
+					return
+				}
+			case <-heartbeat.C:
+				if !streamCredentialValid(apiKey, token, ip) {
+					log.Printf("Event stream for user %s closed: credential no longer valid", userID)
+					return
+				}
+				// Comment line keeps proxies from closing the idle connection
+				fmt.Fprint(w, ": ping\n\n")
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	}))
+
+	return nil
+}
@@ -4,7 +4,9 @@ package controllers
 import (
 	"backend-web/configs"
 	"backend-web/models"
+	"backend-web/utils"
 	"context"
+	"errors"
 	"log"
 	"time"
 
@@ -15,6 +17,36 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// historyScope returns the filter that limits history queries to the caller's
+// active organization, or to their own personal records when no organization
+// is active. API keys are limited to the organization they were created for.
+// The membership is nil in the personal workspace.
+func historyScope(claims *models.Claims) (bson.M, *models.OrganizationMember, error) {
+	member, err := utils.ClaimsOrgMembership(claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if member == nil {
+		return bson.M{"user_id": claims.UserID, "org_id": bson.M{"$exists": false}}, nil, nil
+	}
+	return bson.M{"org_id": member.OrgID.Hex()}, member, nil
+}
+
+// historyScopeError writes the response for a failed historyScope lookup
+func historyScopeError(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, utils.ErrOrgRequiresTwoFactor) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Your active organization requires two-factor authentication",
+		})
+	}
+	log.Printf("Error: Failed to resolve active organization - %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": message,
+	})
+}
+
 // GetPredictionHistory retrieves the prediction history for the authenticated user
 func GetPredictionHistory(c *fiber.Ctx) error {
 
@@ -30,9 +62,12 @@ func GetPredictionHistory(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{"user_id": userClaims.UserID}
+	filter, _, err := historyScope(userClaims)
+	if err != nil {
+		return historyScopeError(c, err, "Failed to retrieve prediction history")
+	}
 
-	opts := options.Find().SetSort(bson.D{{"timestamp", -1}})
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}})
 
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
@@ -93,8 +128,13 @@ func GetPredictionHistoryByID(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	filter, _, err := historyScope(userClaims)
+	if err != nil {
+		return historyScopeError(c, err, "Failed to retrieve prediction history")
+	}
+	filter["_id"] = objID
+
 	var history models.PredictionHistory
-	filter := bson.M{"_id": objID, "user_id": userClaims.UserID}
 	err = collection.FindOne(ctx, filter).Decode(&history)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -153,15 +193,38 @@ func DeleteHistory(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Filter to ensure the history item belongs to the user
-	filter := bson.M{
-		"_id":     objID,
-		"user_id": userClaims.UserID, // Use userClaims.UserID instead of userClaims.Id
+	// Filter to ensure the history item belongs to the user's workspace
+	filter, member, err := historyScope(userClaims)
+	if err != nil {
+		return historyScopeError(c, err, "Failed to delete history item")
 	}
+	filter["_id"] = objID
 
-	// Attempt to delete the history item
-	result, err := collection.DeleteOne(ctx, filter)
+	// Viewers are read-only and inspectors may only delete their own scans
+	if member != nil {
+		if !models.OrgRoleAtLeast(member.Role, models.OrgRoleInspector) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Your organization role does not allow deleting history",
+			})
+		}
+		if !models.OrgRoleAtLeast(member.Role, models.OrgRoleAdmin) {
+			filter["user_id"] = userClaims.UserID
+		}
+	}
+
+	// Attempt to delete the history item, keeping a copy for the webhook payload
+	var deleted models.PredictionHistory
+	err = collection.FindOneAndDelete(ctx, filter).Decode(&deleted)
 	if err != nil {
+		// Check if any document was deleted
+		if err == mongo.ErrNoDocuments {
+			log.Printf("Warning: History item with ID %s not found or not owned by user %s", historyID, userClaims.UserID)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "History item not found or not owned by user",
+			})
+		}
 		log.Printf("Error: Failed to delete history item with ID %s for user %s: %v", historyID, userClaims.UserID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
@@ -169,16 +232,22 @@ func DeleteHistory(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if any document was deleted
-	if result.DeletedCount == 0 {
-		log.Printf("Warning: History item with ID %s not found or not owned by user %s", historyID, userClaims.UserID)
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"status":  "error",
-			"message": "History item not found or not owned by user",
-		})
-	}
-
 	log.Printf("Success: History item with ID %s deleted by user %s", historyID, userClaims.UserID)
+	deletedEvent := fiber.Map{
+		"history_id": deleted.ID.Hex(),
+		"file_name":  deleted.FileName,
+		"timestamp":  deleted.Timestamp,
+	}
+	if deleted.OrgID != "" {
+		deletedEvent["org_id"] = deleted.OrgID
+	}
+	utils.PublishOrgEvent(deleted.OrgID, userClaims.UserID, utils.StreamEventHistoryDeleted, deletedEvent)
+	go utils.EmitWebhookEvent(userClaims.UserID, deleted.OrgID, models.WebhookEventHistoryDeleted, deletedEvent)
+	auditDetails := map[string]string{"history_id": deleted.ID.Hex(), "file_name": deleted.FileName}
+	if deleted.OrgID != "" {
+		auditDetails["org_id"] = deleted.OrgID
+	}
+	utils.RecordAuditEvent(c, models.AuditHistoryDeleted, userClaims.UserID, deleted.UserID, models.AuditResultSuccess, auditDetails)
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "History item deleted successfully",
@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetJWKS publishes the public keys that verify our access tokens so other
+// services can check them without sharing a secret
+func GetJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(fiber.Map{
+		"keys": utils.PublicJWKS(),
+	})
+}
@@ -0,0 +1,950 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+// isValidEmail validates if a string is a valid email address
+func isValidEmail(email string) bool {
+	_, err := mail.ParseAddress(email)
+	return err == nil
+}
+
+// requireOrgRole loads the caller's membership in the organization from the
+// :id route parameter and checks it grants at least minRole and meets the
+// organization's 2FA requirement. On failure the error response has already
+// been written and the returned membership is nil.
+func requireOrgRole(c *fiber.Ctx, userID, minRole string) (*models.OrganizationMember, error) {
+	member, err := loadOrgRole(c, userID, minRole)
+	if member == nil {
+		return nil, err
+	}
+	if err := utils.CheckOrgTwoFactor(member.OrgID, userID); err != nil {
+		return nil, orgTwoFactorError(c, err)
+	}
+	return member, nil
+}
+
+// loadOrgRole is requireOrgRole without the 2FA check, for leaving an
+// organization that requires 2FA the caller has not enabled
+func loadOrgRole(c *fiber.Ctx, userID, minRole string) (*models.OrganizationMember, error) {
+	orgID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid organization ID",
+		})
+	}
+
+	member, err := utils.GetOrgMembership(orgID, userID)
+	if err != nil {
+		log.Println("Error checking organization membership:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to check organization membership",
+		})
+	}
+	if member == nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Organization not found",
+		})
+	}
+	if !models.OrgRoleAtLeast(member.Role, minRole) {
+		return nil, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Your organization role does not allow this action",
+		})
+	}
+	return member, nil
+}
+
+// orgTwoFactorError writes the response for a failed CheckOrgTwoFactor
+func orgTwoFactorError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, utils.ErrOrgRequiresTwoFactor) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This organization requires two-factor authentication. Enable 2FA to continue.",
+		})
+	}
+	log.Println("Error checking organization 2FA requirement:", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Failed to check organization membership",
+	})
+}
+
+// countOrgOwners returns how many owners the organization has
+func countOrgOwners(orgID primitive.ObjectID) (int64, error) {
+	collection := configs.GetCollection(configs.DB, "organization_members")
+	return collection.CountDocuments(context.TODO(), bson.M{"org_id": orgID, "role": models.OrgRoleOwner})
+}
+
+// CreateOrganization creates an organization owned by the authenticated user
+func CreateOrganization(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+		})
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Organization name is required",
+		})
+	}
+
+	now := time.Now()
+	org := models.Organization{
+		ID:        primitive.NewObjectID(),
+		Name:      input.Name,
+		CreatedBy: userClaims.UserID,
+		CreatedAt: now,
+	}
+
+	orgs := configs.GetCollection(configs.DB, "organizations")
+	if _, err := orgs.InsertOne(context.TODO(), org); err != nil {
+		log.Println("Error inserting organization:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create organization",
+		})
+	}
+
+	members := configs.GetCollection(configs.DB, "organization_members")
+	_, err := members.InsertOne(context.TODO(), models.OrganizationMember{
+		ID:       primitive.NewObjectID(),
+		OrgID:    org.ID,
+		UserID:   userClaims.UserID,
+		Role:     models.OrgRoleOwner,
+		JoinedAt: now,
+	})
+	if err != nil {
+		log.Println("Error inserting organization owner:", err)
+		orgs.DeleteOne(context.TODO(), bson.M{"_id": org.ID})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create organization",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Organization created",
+		"data":    org,
+	})
+}
+
+// GetOrganizations lists the organizations the authenticated user belongs to
+func GetOrganizations(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	members := configs.GetCollection(configs.DB, "organization_members")
+	cursor, err := members.Find(ctx, bson.M{"user_id": userClaims.UserID})
+	if err != nil {
+		log.Printf("Error: Failed to query memberships - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve organizations",
+		})
+	}
+	defer cursor.Close(ctx)
+
+	var memberships []models.OrganizationMember
+	if err := cursor.All(ctx, &memberships); err != nil {
+		log.Printf("Error: Failed to decode memberships - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve organizations",
+		})
+	}
+
+	active, err := utils.GetActiveOrgMembership(userClaims.UserID)
+	if err != nil && !errors.Is(err, utils.ErrOrgRequiresTwoFactor) {
+		log.Printf("Error: Failed to resolve active organization - %v", err)
+	}
+
+	orgs := configs.GetCollection(configs.DB, "organizations")
+	result := []fiber.Map{}
+	for _, m := range memberships {
+		var org models.Organization
+		if err := orgs.FindOne(ctx, bson.M{"_id": m.OrgID}).Decode(&org); err != nil {
+			continue
+		}
+		result = append(result, fiber.Map{
+			"id":         org.ID.Hex(),
+			"name":       org.Name,
+			"role":       m.Role,
+			"active":     active != nil && active.OrgID == org.ID,
+			"require2FA": org.Require2FA,
+			"joinedAt":   m.JoinedAt,
+			"createdAt":  org.CreatedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Organizations retrieved successfully",
+		"data":    result,
+	})
+}
+
+// GetOrganization returns an organization and its members
+func GetOrganization(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	member, err := requireOrgRole(c, userClaims.UserID, models.OrgRoleViewer)
+	if member == nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var org models.Organization
+	orgs := configs.GetCollection(configs.DB, "organizations")
+	if err := orgs.FindOne(ctx, bson.M{"_id": member.OrgID}).Decode(&org); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Organization not found",
+		})
+	}
+
+	members := configs.GetCollection(configs.DB, "organization_members")
+	cursor, err := members.Find(ctx, bson.M{"org_id": member.OrgID},
+		options.Find().SetSort(bson.D{{Key: "joinedAt", Value: 1}}))
+	if err != nil {
+		log.Printf("Error: Failed to query organization members - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve organization",
+		})
+	}
+	defer cursor.Close(ctx)
+
+	var memberships []models.OrganizationMember
+	if err := cursor.All(ctx, &memberships); err != nil {
+		log.Printf("Error: Failed to decode organization members - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve organization",
+		})
+	}
+
+	users := configs.GetCollection(configs.DB, "users")
+	memberList := []fiber.Map{}
+	for _, m := range memberships {
+		entry := fiber.Map{
+			"user_id":  m.UserID,
+			"role":     m.Role,
+			"joinedAt": m.JoinedAt,
+		}
+		if objID, err := primitive.ObjectIDFromHex(m.UserID); err == nil {
+			var user models.User
+			if err := users.FindOne(ctx, bson.M{"_id": objID}).Decode(&user); err == nil {
+				entry["username"] = user.Username
+				entry["email"] = user.Email
+			}
+		}
+		memberList = append(memberList, entry)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Organization retrieved successfully",
+		"data": fiber.Map{
+			"id":         org.ID.Hex(),
+			"name":       org.Name,
+			"role":       member.Role,
+			"require2FA": org.Require2FA,
+			"createdAt":  org.CreatedAt,
+			"members":    memberList,
+		},
+	})
+}
+
+// UpdateOrganization renames the organization or changes its 2FA requirement.
+// Only admins and owners may change settings, and enabling the 2FA requirement
+// needs the caller to have 2FA themselves so they are not locked out.
+func UpdateOrganization(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	type UpdateOrganizationInput struct {
+		Name       *string `json:"name"`
+		Require2FA *bool   `json:"require2FA"`
+	}
+	input := new(UpdateOrganizationInput)
+	if err := c.BodyParser(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request format",
+			"data":    err.Error(),
+		})
+	}
+
+	caller, err := requireOrgRole(c, userClaims.UserID, models.OrgRoleAdmin)
+	if caller == nil {
+		return err
+	}
+
+	set := bson.M{}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Organization name is required",
+			})
+		}
+		set["name"] = name
+	}
+	if input.Require2FA != nil {
+		if *input.Require2FA {
+			objID, err := primitive.ObjectIDFromHex(userClaims.UserID)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Invalid user ID",
+				})
+			}
+			var user models.User
+			users := configs.GetCollection(configs.DB, "users")
+			if err := users.FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&user); err != nil {
+				log.Println("Error fetching user:", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Failed to update organization",
+				})
+			}
+			if !user.TwoFactorEnabled {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Enable two-factor authentication on your account before requiring it",
+				})
+			}
+		}
+		set["require2FA"] = *input.Require2FA
+	}
+	if len(set) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No changes provided",
+		})
+	}
+
+	var org models.Organization
+	orgs := configs.GetCollection(configs.DB, "organizations")
+	err = orgs.FindOneAndUpdate(context.TODO(), bson.M{"_id": caller.OrgID}, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&org)
+	if err != nil {
+		log.Println("Error updating organization:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update organization",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Organization updated successfully",
+		"data":    org,
+	})
+}
+
+// SetActiveOrganization switches the workspace that history queries are scoped
+// to. An empty org_id switches back to the personal workspace.
+func SetActiveOrganization(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	var input struct {
+		OrgID string `json:"org_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+		})
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userClaims.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID",
+		})
+	}
+
+	update := bson.M{"$unset": bson.M{"activeOrgId": ""}}
+	if input.OrgID != "" {
+		orgID, err := primitive.ObjectIDFromHex(input.OrgID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid organization ID",
+			})
+		}
+		member, err := utils.GetOrgMembership(orgID, userClaims.UserID)
+		if err != nil {
+			log.Println("Error checking organization membership:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to switch organization",
+			})
+		}
+		if member == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Organization not found",
+			})
+		}
+		if err := utils.CheckOrgTwoFactor(orgID, userClaims.UserID); err != nil {
+			return orgTwoFactorError(c, err)
+		}
+		update = bson.M{"$set": bson.M{"activeOrgId": orgID}}
+	}
+
+	users := configs.GetCollection(configs.DB, "users")
+	if _, err := users.UpdateOne(context.TODO(), bson.M{"_id": userObjID}, update); err != nil {
+		log.Println("Error switching active organization:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to switch organization",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Active organization updated",
+		"data":    fiber.Map{"org_id": input.OrgID},
+	})
+}
+
+// UpdateOrganizationMember changes a member's role
+func UpdateOrganizationMember(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	caller, err := requireOrgRole(c, userClaims.UserID, models.OrgRoleAdmin)
+	if caller == nil {
+		return err
+	}
+
+	var input struct {
+		Role string `json:"role"`
+	}
+	if err := c.BodyParser(&input); err != nil || !models.IsValidOrgRole(input.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Role must be one of owner, admin, inspector or viewer",
+		})
+	}
+
+	target, err := utils.GetOrgMembership(caller.OrgID, c.Params("userId"))
+	if err != nil {
+		log.Println("Error fetching organization member:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update member",
+		})
+	}
+	if target == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Member not found",
+		})
+	}
+
+	// Only owners may grant or take away ownership
+	if (input.Role == models.OrgRoleOwner || target.Role == models.OrgRoleOwner) && caller.Role != models.OrgRoleOwner {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only owners can change ownership",
+		})
+	}
+	if target.Role == models.OrgRoleOwner && input.Role != models.OrgRoleOwner {
+		owners, err := countOrgOwners(caller.OrgID)
+		if err != nil || owners <= 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "An organization must keep at least one owner",
+			})
+		}
+	}
+
+	members := configs.GetCollection(configs.DB, "organization_members")
+	_, err = members.UpdateOne(context.TODO(), bson.M{"_id": target.ID}, bson.M{"$set": bson.M{"role": input.Role}})
+	if err != nil {
+		log.Println("Error updating organization member:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update member",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member role updated",
+		"data":    fiber.Map{"user_id": target.UserID, "role": input.Role},
+	})
+}
+
+// RemoveOrganizationMember removes a member; any member may remove themselves
+func RemoveOrganizationMember(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	// Leaving needs no 2FA, so a member is never stuck in an organization
+	// that started requiring it
+	targetUserID := c.Params("userId")
+	var caller *models.OrganizationMember
+	var err error
+	if targetUserID == userClaims.UserID {
+		caller, err = loadOrgRole(c, userClaims.UserID, models.OrgRoleViewer)
+	} else {
+		caller, err = requireOrgRole(c, userClaims.UserID, models.OrgRoleAdmin)
+	}
+	if caller == nil {
+		return err
+	}
+
+	target, err := utils.GetOrgMembership(caller.OrgID, targetUserID)
+	if err != nil {
+		log.Println("Error fetching organization member:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to remove member",
+		})
+	}
+	if target == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Member not found",
+		})
+	}
+	if target.Role == models.OrgRoleOwner {
+		if caller.Role != models.OrgRoleOwner {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Only owners can remove an owner",
+			})
+		}
+		owners, err := countOrgOwners(caller.OrgID)
+		if err != nil || owners <= 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "An organization must keep at least one owner",
+			})
+		}
+	}
+
+	members := configs.GetCollection(configs.DB, "organization_members")
+	if _, err := members.DeleteOne(context.TODO(), bson.M{"_id": target.ID}); err != nil {
+		log.Println("Error removing organization member:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to remove member",
+		})
+	}
+
+	// Send the removed user back to their personal workspace
+	if targetObjID, err := primitive.ObjectIDFromHex(targetUserID); err == nil {
+		users := configs.GetCollection(configs.DB, "users")
+		_, err = users.UpdateOne(context.TODO(),
+			bson.M{"_id": targetObjID, "activeOrgId": caller.OrgID},
+			bson.M{"$unset": bson.M{"activeOrgId": ""}},
+		)
+		if err != nil {
+			log.Println("Error resetting active organization:", err)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member removed",
+	})
+}
+
+// CreateInvitation invites an email address to join the organization
+func CreateInvitation(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	caller, err := requireOrgRole(c, userClaims.UserID, models.OrgRoleAdmin)
+	if caller == nil {
+		return err
+	}
+
+	var input struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+		})
+	}
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+	if input.Role == "" {
+		input.Role = models.OrgRoleViewer
+	}
+	if !isValidEmail(input.Email) || !models.IsValidOrgRole(input.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A valid email and role are required",
+		})
+	}
+	if input.Role == models.OrgRoleOwner && caller.Role != models.OrgRoleOwner {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only owners can invite owners",
+		})
+	}
+
+	var org models.Organization
+	orgs := configs.GetCollection(configs.DB, "organizations")
+	if err := orgs.FindOne(context.TODO(), bson.M{"_id": caller.OrgID}).Decode(&org); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Organization not found",
+		})
+	}
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		log.Println("Error generating invitation token:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create invitation",
+		})
+	}
+
+	now := time.Now()
+	invitation := models.OrganizationInvitation{
+		ID:        primitive.NewObjectID(),
+		OrgID:     caller.OrgID,
+		Email:     input.Email,
+		Role:      input.Role,
+		TokenHash: utils.HashToken(token),
+		InvitedBy: userClaims.UserID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(invitationTTL),
+	}
+
+	invitations := configs.GetCollection(configs.DB, "organization_invitations")
+	// A new invitation replaces any pending one for the same address
+	_, err = invitations.DeleteMany(context.TODO(), bson.M{
+		"org_id":     caller.OrgID,
+		"email":      input.Email,
+		"acceptedAt": bson.M{"$exists": false},
+	})
+	if err != nil {
+		log.Println("Error deleting old invitations:", err)
+	}
+	if _, err := invitations.InsertOne(context.TODO(), invitation); err != nil {
+		log.Println("Error inserting invitation:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create invitation",
+		})
+	}
+
+	// Invitees with an account get their own language, others the inviter's
+	locale := c.Get(fiber.HeaderAcceptLanguage)
+	var invitee models.User
+	users := configs.GetCollection(configs.DB, "users")
+	if err := users.FindOne(context.TODO(), bson.M{"email": input.Email}).Decode(&invitee); err == nil && invitee.Locale != "" {
+		locale = invitee.Locale
+	}
+
+	inviteLink := configs.EnvFrontendURL() + "/orgs/invitations/accept?token=" + url.QueryEscape(token)
+	if err := utils.SendOrganizationInvitation(input.Email, locale, org.Name, inviteLink); err != nil {
+		log.Println("Error sending invitation email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to send invitation email",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation sent",
+		"data":    invitation,
+	})
+}
+
+// GetInvitations lists the organization's pending invitations
+func GetInvitations(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	caller, err := requireOrgRole(c, userClaims.UserID, models.OrgRoleAdmin)
+	if caller == nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	invitations := configs.GetCollection(configs.DB, "organization_invitations")
+	cursor, err := invitations.Find(ctx, bson.M{
+		"org_id":     caller.OrgID,
+		"acceptedAt": bson.M{"$exists": false},
+		"expiresAt":  bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		log.Printf("Error: Failed to query invitations - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve invitations",
+		})
+	}
+	defer cursor.Close(ctx)
+
+	result := []models.OrganizationInvitation{}
+	if err := cursor.All(ctx, &result); err != nil {
+		log.Printf("Error: Failed to decode invitations - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve invitations",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitations retrieved successfully",
+		"data":    result,
+	})
+}
+
+// RevokeInvitation deletes a pending invitation
+func RevokeInvitation(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	caller, err := requireOrgRole(c, userClaims.UserID, models.OrgRoleAdmin)
+	if caller == nil {
+		return err
+	}
+
+	invitationID, err := primitive.ObjectIDFromHex(c.Params("invitationId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid invitation ID",
+		})
+	}
+
+	invitations := configs.GetCollection(configs.DB, "organization_invitations")
+	result, err := invitations.DeleteOne(context.TODO(), bson.M{
+		"_id":        invitationID,
+		"org_id":     caller.OrgID,
+		"acceptedAt": bson.M{"$exists": false},
+	})
+	if err != nil {
+		log.Println("Error revoking invitation:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to revoke invitation",
+		})
+	}
+	if result.DeletedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invitation not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation revoked",
+	})
+}
+
+// AcceptInvitation adds the authenticated user to the inviting organization.
+// The invitation must have been sent to the user's own email address.
+func AcceptInvitation(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	var input struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invitation token is required",
+		})
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userClaims.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID",
+		})
+	}
+
+	users := configs.GetCollection(configs.DB, "users")
+	var user models.User
+	if err := users.FindOne(context.TODO(), bson.M{"_id": userObjID}).Decode(&user); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	// Claim the invitation atomically so it can only be used once
+	invitations := configs.GetCollection(configs.DB, "organization_invitations")
+	var invitation models.OrganizationInvitation
+	err = invitations.FindOneAndUpdate(context.TODO(),
+		bson.M{
+			"token_hash": utils.HashToken(input.Token),
+			"email":      strings.ToLower(user.Email),
+			"acceptedAt": bson.M{"$exists": false},
+			"expiresAt":  bson.M{"$gt": time.Now()},
+		},
+		bson.M{"$set": bson.M{"acceptedAt": time.Now()}},
+	).Decode(&invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invitation is invalid, expired or was sent to a different email",
+			})
+		}
+		log.Println("Error accepting invitation:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to accept invitation",
+		})
+	}
+
+	existing, err := utils.GetOrgMembership(invitation.OrgID, userClaims.UserID)
+	if err != nil {
+		log.Println("Error checking organization membership:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to accept invitation",
+		})
+	}
+	if existing == nil {
+		members := configs.GetCollection(configs.DB, "organization_members")
+		_, err = members.InsertOne(context.TODO(), models.OrganizationMember{
+			ID:       primitive.NewObjectID(),
+			OrgID:    invitation.OrgID,
+			UserID:   userClaims.UserID,
+			Role:     invitation.Role,
+			JoinedAt: time.Now(),
+		})
+		if err != nil {
+			log.Println("Error inserting organization member:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to accept invitation",
+			})
+		}
+	}
+
+	if user.ActiveOrgID.IsZero() {
+		_, err = users.UpdateOne(context.TODO(), bson.M{"_id": userObjID}, bson.M{"$set": bson.M{"activeOrgId": invitation.OrgID}})
+		if err != nil {
+			log.Println("Error setting active organization:", err)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation accepted",
+		"data":    fiber.Map{"org_id": invitation.OrgID.Hex(), "role": invitation.Role},
+	})
+}
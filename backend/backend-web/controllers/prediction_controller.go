@@ -3,6 +3,7 @@ package controllers
 import (
 	"backend-web/configs"
 	"backend-web/models"
+	"backend-web/utils"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -15,7 +16,6 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -25,38 +25,40 @@ import (
 func PredictHandler(c *fiber.Ctx) error {
 	log.Println("Starting PredictHandler")
 
-	// Get and parse JWT token
-	tokenString := c.Get("Authorization")
+	// Signed-in callers are identified by OptionalAuth; anonymous predictions are not saved
 	var userID string
-	if tokenString != "" && len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-		token, err := jwt.Parse(tokenString[7:], func(token *jwt.Token) (interface{}, error) {
-			return []byte(configs.EnvSecret()), nil
-		})
-		if err != nil {
-			log.Printf("JWT Parse Error: %v", err)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid token: " + err.Error(),
-			})
-		}
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			if sub, exists := claims["sub"]; exists {
-				userID, _ = sub.(string)
-			} else if id, exists := claims["user_id"]; exists {
-				userID, _ = id.(string)
-			} else if id, exists := claims["id"]; exists {
-				userID, _ = id.(string)
-			}
-			log.Printf("Extracted userID: %s", userID)
-		} else {
-			log.Println("Invalid claims or token")
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid token claims",
+	claims, ok := c.Locals("user").(*models.Claims)
+	if ok {
+		if !claims.HasPermission(models.PermPredict) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Forbidden - missing permission " + models.PermPredict,
 			})
 		}
+		userID = claims.UserID
 	} else {
 		log.Println("No valid Bearer token found, proceeding without userID")
 	}
 
+	// Progress is pushed to the user's event stream; clients may pass their
+	// own job_id to correlate events with the upload they started
+	jobID := c.FormValue("job_id")
+	if jobID == "" {
+		jobID = primitive.NewObjectID().Hex()
+	}
+	reportProgress := func(stage string, extra fiber.Map) {
+		data := fiber.Map{"job_id": jobID, "stage": stage}
+		for k, v := range extra {
+			data[k] = v
+		}
+		utils.PublishEvent(userID, utils.StreamEventPredictionProgress, data)
+	}
+	completed := false
+	defer func() {
+		if !completed {
+			reportProgress("failed", nil)
+		}
+	}()
+
 	// Get uploaded file
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -67,6 +69,7 @@ func PredictHandler(c *fiber.Ctx) error {
 		})
 	}
 	log.Printf("Received file: %s", file.Filename)
+	reportProgress("received", fiber.Map{"file_name": file.Filename})
 
 	// Validate file type
 	allowedExtensions := map[string]bool{".jpg": true, ".jpeg": true, ".png": true}
@@ -189,6 +192,8 @@ func PredictHandler(c *fiber.Ctx) error {
 	}
 	writer.Close()
 
+	reportProgress("analyzing", fiber.Map{"imageUrl": imageUrl})
+
 	// Send request to prediction server
 	req, err := http.NewRequest("POST", "http://localhost:8083/predict", body)
 	if err != nil {
@@ -261,6 +266,14 @@ func PredictHandler(c *fiber.Ctx) error {
 			Timestamp:  time.Now(),
 		}
 
+		// Share the record with the active organization unless the user is only a viewer there
+		member, err := utils.ClaimsOrgMembership(claims)
+		if err != nil {
+			log.Printf("Error resolving active organization: %v", err)
+		} else if member != nil && models.OrgRoleAtLeast(member.Role, models.OrgRoleInspector) {
+			history.OrgID = member.OrgID.Hex()
+		}
+
 		// Insert with retry mechanism
 		var result *mongo.InsertOneResult
 		for attempt := 1; attempt <= 3; attempt++ {
@@ -278,13 +291,32 @@ func PredictHandler(c *fiber.Ctx) error {
 			}
 		}
 		log.Printf("Inserted history with ID: %v", result.InsertedID)
+
+		historyID, _ := result.InsertedID.(primitive.ObjectID)
+		createdEvent := fiber.Map{
+			"history_id":             historyID.Hex(),
+			"file_name":              history.FileName,
+			"percentage_weight_lose": history.Percentage,
+			"features":               history.Features,
+			"imageUrl":               history.ImageUrl,
+			"timestamp":              history.Timestamp,
+		}
+		if history.OrgID != "" {
+			createdEvent["org_id"] = history.OrgID
+		}
+		utils.PublishOrgEvent(history.OrgID, userID, utils.StreamEventHistoryCreated, createdEvent)
+		go utils.EmitWebhookEvent(userID, history.OrgID, models.WebhookEventPredictionCreated, createdEvent)
 	} else {
 		log.Println("No userID, skipping history save")
 	}
 
+	completed = true
+	reportProgress("completed", fiber.Map{"percentage_weight_lose": resultData.Data.PercentageWeightLose})
+
 	return c.JSON(fiber.Map{
 		"status": "success",
 		"data": fiber.Map{
+			"job_id":                 jobID,
 			"percentage_weight_lose": resultData.Data.PercentageWeightLose,
 			"features":               resultData.Data.Features,
 			"imageUrl":               imageUrl,
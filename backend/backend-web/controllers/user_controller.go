@@ -10,6 +10,7 @@ import (
 
 	"backend-web/configs"
 	"backend-web/models"
+	"backend-web/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -59,11 +60,12 @@ func GetUser(c *fiber.Ctx) error {
 			"avatar":        avatarURL,
 			"createdAt":     user.CreatedAt,
 			"emailVerified": user.EmailVerified,
+			"locale":        user.Locale,
 		},
 	})
 }
 
-// UpdateUser update user
+// UpdateUser updates the username and/or the language used for emails
 func UpdateUser(c *fiber.Ctx) error {
 	userClaims, ok := c.Locals("user").(*models.Claims)
 	if !ok {
@@ -83,6 +85,7 @@ func UpdateUser(c *fiber.Ctx) error {
 
 	var updateData struct {
 		Username string `json:"username"`
+		Locale   string `json:"locale"`
 	}
 	if err := c.BodyParser(&updateData); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -91,28 +94,39 @@ func UpdateUser(c *fiber.Ctx) error {
 		})
 	}
 
-	if updateData.Username == "" {
+	if updateData.Username == "" && updateData.Locale == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Username is required",
 		})
 	}
 
-	collection := configs.GetCollection(configs.DB, "users")
-	var existingUser models.User
-	err = collection.FindOne(c.Context(), bson.M{
-		"username": updateData.Username,
-		"_id":      bson.M{"$ne": objID},
-	}).Decode(&existingUser)
-	if err == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Username already taken",
-		})
+	updateFields := bson.M{}
+	if updateData.Locale != "" {
+		locale := utils.NormalizeLocale(updateData.Locale)
+		if locale == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Unsupported locale",
+			})
+		}
+		updateFields["locale"] = locale
 	}
 
-	updateFields := bson.M{
-		"username": updateData.Username,
+	collection := configs.GetCollection(configs.DB, "users")
+	if updateData.Username != "" {
+		var existingUser models.User
+		err = collection.FindOne(c.Context(), bson.M{
+			"username": updateData.Username,
+			"_id":      bson.M{"$ne": objID},
+		}).Decode(&existingUser)
+		if err == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Username already taken",
+			})
+		}
+		updateFields["username"] = updateData.Username
 	}
 	_, err = collection.UpdateOne(c.Context(), bson.M{"_id": objID}, bson.M{"$set": updateFields})
 	if err != nil {
@@ -140,7 +154,7 @@ func UpdateUser(c *fiber.Ctx) error {
 
 	response := fiber.Map{
 		"status":  "success",
-		"message": "Username updated",
+		"message": "Profile updated",
 		"data": bson.M{
 			"user_id":       updatedUser.Id.Hex(),
 			"username":      updatedUser.Username,
@@ -148,9 +162,15 @@ func UpdateUser(c *fiber.Ctx) error {
 			"avatar":        avatarURL,
 			"createdAt":     updatedUser.CreatedAt,
 			"emailVerified": updatedUser.EmailVerified,
+			"locale":        updatedUser.Locale,
 		},
 	}
 	fmt.Println("UpdateUser response:", response)
+	utils.PublishEvent(userClaims.UserID, utils.StreamEventProfileUpdated, response["data"])
+	utils.RecordAuditEvent(c, models.AuditProfileUpdated, userClaims.UserID, userClaims.UserID, models.AuditResultSuccess, map[string]string{
+		"username": updatedUser.Username,
+		"locale":   updatedUser.Locale,
+	})
 	return c.JSON(response)
 }
 
@@ -269,6 +289,8 @@ func UploadAvatar(c *fiber.Ctx) error {
 	}
 
 	avatarURL := fmt.Sprintf("http://localhost:8081/api/user/avatar/%s", fileID.Hex())
+	utils.PublishEvent(userClaims.UserID, utils.StreamEventAvatarUpdated, bson.M{"avatar": avatarURL})
+	utils.RecordAuditEvent(c, models.AuditAvatarChanged, userClaims.UserID, userClaims.UserID, models.AuditResultSuccess, map[string]string{"file_id": fileID.Hex()})
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "Avatar uploaded successfully",
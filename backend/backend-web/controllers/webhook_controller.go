@@ -0,0 +1,545 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+	"backend-web/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// isValidWebhookURL accepts absolute http(s) URLs whose host resolves to
+// public addresses only
+func isValidWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return false
+	}
+	return utils.IsPublicWebhookHost(u.Hostname())
+}
+
+// validWebhookEvents reports whether every requested event is a known event type
+func validWebhookEvents(events []string) bool {
+	if len(events) == 0 {
+		return false
+	}
+	for _, event := range events {
+		known := false
+		for _, t := range models.WebhookEventTypes {
+			if event == t {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return false
+		}
+	}
+	return true
+}
+
+// webhookAccessFilter matches the user's personal subscriptions and those of
+// organizations where the user is an owner or admin
+func webhookAccessFilter(userID string) (bson.M, error) {
+	orgIDs, err := utils.UserOrgIDs(userID, models.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$or": []bson.M{
+		{"user_id": userID, "org_id": bson.M{"$exists": false}},
+		{"org_id": bson.M{"$in": orgIDs}},
+	}}, nil
+}
+
+// canAccessWebhook reports whether the user may manage the subscription
+func canAccessWebhook(userID string, subID primitive.ObjectID) (bool, error) {
+	filter, err := webhookAccessFilter(userID)
+	if err != nil {
+		return false, err
+	}
+	filter["_id"] = subID
+
+	collection := configs.GetCollection(configs.DB, "webhook_subscriptions")
+	count, err := collection.CountDocuments(context.TODO(), filter)
+	return count > 0, err
+}
+
+// CreateWebhook registers a new webhook subscription for the authenticated
+// user, or for an organization the user administers when org_id is given
+func CreateWebhook(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	var input struct {
+		URL    string   `json:"url"`
+		Secret string   `json:"secret"`
+		Events []string `json:"events"`
+		OrgID  string   `json:"org_id"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+		})
+	}
+
+	if !isValidWebhookURL(input.URL) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A valid public http(s) URL is required",
+		})
+	}
+	if !validWebhookEvents(input.Events) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Events must be a non-empty list of supported event types",
+			"data":    models.WebhookEventTypes,
+		})
+	}
+
+	if input.OrgID != "" {
+		orgID, err := primitive.ObjectIDFromHex(input.OrgID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid organization ID",
+			})
+		}
+		member, err := utils.GetOrgMembership(orgID, userClaims.UserID)
+		if err != nil {
+			log.Println("Error checking organization membership:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to create webhook",
+			})
+		}
+		if member == nil || !models.OrgRoleAtLeast(member.Role, models.OrgRoleAdmin) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Only organization owners and admins can manage organization webhooks",
+			})
+		}
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		generated, err := utils.GenerateWebhookSecret()
+		if err != nil {
+			log.Println("Error generating webhook secret:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to create webhook",
+			})
+		}
+		secret = generated
+	}
+
+	now := time.Now()
+	subscription := models.WebhookSubscription{
+		ID:        primitive.NewObjectID(),
+		UserID:    userClaims.UserID,
+		OrgID:     input.OrgID,
+		URL:       input.URL,
+		Secret:    secret,
+		Events:    input.Events,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	collection := configs.GetCollection(configs.DB, "webhook_subscriptions")
+	if _, err := collection.InsertOne(context.TODO(), subscription); err != nil {
+		log.Println("Error inserting webhook subscription:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create webhook",
+		})
+	}
+
+	// The secret is only returned once, on creation
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Webhook created",
+		"data": fiber.Map{
+			"webhook": subscription,
+			"secret":  secret,
+		},
+	})
+}
+
+// GetWebhooks lists the authenticated user's webhook subscriptions
+func GetWebhooks(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	collection := configs.GetCollection(configs.DB, "webhook_subscriptions")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter, err := webhookAccessFilter(userClaims.UserID)
+	if err != nil {
+		log.Println("Error resolving webhook access:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve webhooks",
+		})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error: Failed to query webhooks - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve webhooks",
+		})
+	}
+	defer cursor.Close(ctx)
+
+	webhooks := []models.WebhookSubscription{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		log.Printf("Error: Failed to decode webhooks - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve webhooks",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Webhooks retrieved successfully",
+		"data":    webhooks,
+	})
+}
+
+// UpdateWebhook changes the URL, events or active flag of a subscription
+func UpdateWebhook(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid webhook ID",
+		})
+	}
+
+	var input struct {
+		URL    *string  `json:"url"`
+		Events []string `json:"events"`
+		Active *bool    `json:"active"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+		})
+	}
+
+	updateFields := bson.M{"updatedAt": time.Now()}
+	if input.URL != nil {
+		if !isValidWebhookURL(*input.URL) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "A valid public http(s) URL is required",
+			})
+		}
+		updateFields["url"] = *input.URL
+	}
+	if input.Events != nil {
+		if !validWebhookEvents(input.Events) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Events must be a non-empty list of supported event types",
+				"data":    models.WebhookEventTypes,
+			})
+		}
+		updateFields["events"] = input.Events
+	}
+	if input.Active != nil {
+		updateFields["active"] = *input.Active
+	}
+
+	filter, err := webhookAccessFilter(userClaims.UserID)
+	if err != nil {
+		log.Println("Error resolving webhook access:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update webhook",
+		})
+	}
+	filter["_id"] = objID
+
+	collection := configs.GetCollection(configs.DB, "webhook_subscriptions")
+	var updated models.WebhookSubscription
+	err = collection.FindOneAndUpdate(context.TODO(),
+		filter,
+		bson.M{"$set": updateFields},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Webhook not found",
+			})
+		}
+		log.Println("Error updating webhook:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update webhook",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Webhook updated",
+		"data":    updated,
+	})
+}
+
+// DeleteWebhook removes a subscription and its pending deliveries
+func DeleteWebhook(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid webhook ID",
+		})
+	}
+
+	filter, err := webhookAccessFilter(userClaims.UserID)
+	if err != nil {
+		log.Println("Error resolving webhook access:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to delete webhook",
+		})
+	}
+	filter["_id"] = objID
+
+	collection := configs.GetCollection(configs.DB, "webhook_subscriptions")
+	result, err := collection.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		log.Println("Error deleting webhook:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to delete webhook",
+		})
+	}
+	if result.DeletedCount == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Webhook not found",
+		})
+	}
+
+	deliveries := configs.GetCollection(configs.DB, "webhook_deliveries")
+	_, err = deliveries.DeleteMany(context.TODO(), bson.M{"subscription_id": objID})
+	if err != nil {
+		log.Println("Error deleting webhook deliveries:", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Webhook deleted",
+	})
+}
+
+// GetWebhookDeliveries returns the delivery log of a subscription, newest first
+func GetWebhookDeliveries(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid webhook ID",
+		})
+	}
+
+	allowed, err := canAccessWebhook(userClaims.UserID, objID)
+	if err != nil {
+		log.Println("Error resolving webhook access:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve deliveries",
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Webhook not found",
+		})
+	}
+
+	limit := int64(c.QueryInt("limit", 50))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	filter := bson.M{"subscription_id": objID}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	collection := configs.GetCollection(configs.DB, "webhook_deliveries")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("Error: Failed to query webhook deliveries - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve deliveries",
+		})
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []models.WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		log.Printf("Error: Failed to decode webhook deliveries - %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve deliveries",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Deliveries retrieved successfully",
+		"data":    deliveries,
+	})
+}
+
+// RedeliverWebhook queues a fresh copy of an earlier delivery
+func RedeliverWebhook(c *fiber.Ctx) error {
+	userClaims, ok := c.Locals("user").(*models.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unauthorized - invalid token",
+		})
+	}
+
+	subID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid webhook ID",
+		})
+	}
+	deliveryID, err := primitive.ObjectIDFromHex(c.Params("deliveryId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid delivery ID",
+		})
+	}
+
+	allowed, err := canAccessWebhook(userClaims.UserID, subID)
+	if err != nil {
+		log.Println("Error resolving webhook access:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to redeliver webhook",
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Webhook not found",
+		})
+	}
+
+	collection := configs.GetCollection(configs.DB, "webhook_deliveries")
+	var original models.WebhookDelivery
+	err = collection.FindOne(context.TODO(), bson.M{
+		"_id":             deliveryID,
+		"subscription_id": subID,
+	}).Decode(&original)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Delivery not found",
+			})
+		}
+		log.Println("Error fetching webhook delivery:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to redeliver webhook",
+		})
+	}
+
+	now := time.Now()
+	redelivery := models.WebhookDelivery{
+		ID:             primitive.NewObjectID(),
+		SubscriptionID: original.SubscriptionID,
+		UserID:         original.UserID,
+		Event:          original.Event,
+		Payload:        original.Payload,
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  now,
+		RedeliveryOf:   original.ID,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(configs.EnvWebhookDeliveryRetention()),
+	}
+	if _, err := collection.InsertOne(context.TODO(), redelivery); err != nil {
+		log.Println("Error queueing webhook redelivery:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to redeliver webhook",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Redelivery queued",
+		"data":    redelivery,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"backend-web/configs"
 	"backend-web/middleware"
 	"backend-web/routes"
+	"backend-web/utils"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,7 +17,7 @@ func main() {
 	
 
 	configs.InitOAuth() 
-    configs.ConnectDB()
+	configs.DB = configs.ConnectDB()
 	configs.InitIndexes()
 
 	routes.OAuthRoute(app)
@@ -24,6 +25,20 @@ func main() {
 	routes.PredictionRoute(app)
 	routes.AuthUserRoute(app)
 	routes.HistoryRoute(app)
+	routes.WebhookRoute(app)
+	routes.APIKeyRoute(app)
+	routes.EventRoute(app)
+	routes.OrganizationRoute(app)
+	routes.AdminRoute(app)
+	routes.WellKnownRoute(app)
+	routes.DevRoute(app)
+
+	utils.StartKeyRotation()
+	utils.StartRevocationSync()
+	utils.StartWebhookWorker()
+	utils.StartEmailWorker()
+	utils.StartDataExportWorker()
+	utils.StartAccountDeletionWorker()
 	
 	app.Static("/uploads", "./Uploads")
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -1,103 +1,65 @@
 package middleware
 
 import (
-	"backend-web/configs"
-	"backend-web/models"
 	"backend-web/utils"
 	"errors"
 	"log"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Protected rejects requests without a valid access token and stores the
+// token's claims in c.Locals("user")
 func Protected() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-        tokenStr := c.Cookies("token")
-        if tokenStr == "" {
-            authHeader := c.Get("Authorization")
-            if authHeader != "" && len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-                tokenStr = authHeader[7:]
-            }
-        }
-
-        if tokenStr == "" {
-            return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-                "status":  "error",
-                "message": "Token required",
-                "data":    nil,
-            })
-        }
-
-        // Parse token
-        token, err := jwt.ParseWithClaims(tokenStr, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
-            return []byte(configs.EnvSecret()), nil
-        })
-        if err != nil {
-            return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-                "status":  "error",
-                "message": "Invalid or expired JWT",
-                "data":    nil,
-            })
-        }
-
-        if !token.Valid {
-            return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-                "status":  "error",
-                "message": "Invalid or expired JWT",
-                "data":    nil,
-            })
-        }
-
-        if utils.IsTokenBlacklisted(tokenStr) {
-            return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-                "status":  "error",
-                "message": "Token is invalid or blacklisted",
-                "data":    nil,
-            })
-        }
-
-        claims, ok := token.Claims.(*models.Claims)
-        if !ok {
-            return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-                "status":  "error",
-                "message": "Invalid token claims",
-                "data":    nil,
-            })
-        }
-        c.Locals("user", claims)
-
-        return c.Next()
-    }
+	return JWTAuthMiddleware
 }
 
+// JWTAuthMiddleware is the handler form of Protected. Machine clients may
+// send an API key in the X-API-Key header instead of a token.
 func JWTAuthMiddleware(c *fiber.Ctx) error {
-	tokenStr := c.Cookies("token")
-	if tokenStr == "" {
-		authHeader := c.Get("Authorization")
-		if authHeader != "" && len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-			tokenStr = authHeader[7:]
-		}
+	if apiKey := c.Get("X-API-Key"); apiKey != "" {
+		return authenticateAPIKey(c, apiKey)
 	}
-	
+	tokenStr := utils.ExtractToken(c)
 	if tokenStr == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Unauthorized: No token found",
+			"status":  "error",
+			"message": "Token required",
+			"data":    nil,
 		})
 	}
+	return authenticate(c, tokenStr)
+}
 
-	if utils.IsTokenBlacklisted(tokenStr) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Unauthorized: Token is blacklisted",
-		})
+// OptionalAuth lets anonymous requests through but still verifies a token
+// when one is sent, so routes can tell signed-in callers apart
+func OptionalAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if apiKey := c.Get("X-API-Key"); apiKey != "" {
+			return authenticateAPIKey(c, apiKey)
+		}
+		tokenStr := utils.ExtractToken(c)
+		if tokenStr == "" {
+			return c.Next()
+		}
+		return authenticate(c, tokenStr)
 	}
+}
 
-	claims, err := ParseJWT(tokenStr)
+func authenticate(c *fiber.Ctx, tokenStr string) error {
+	claims, err := utils.VerifyToken(tokenStr)
 	if err != nil {
+		message := "Invalid or expired JWT"
+		switch {
+		case errors.Is(err, utils.ErrTokenRevoked):
+			message = "Token is invalid or blacklisted"
+		case errors.Is(err, utils.ErrSessionRevoked):
+			message = "Session has been signed out"
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Unauthorized: Invalid token",
+			"status":  "error",
+			"message": message,
+			"data":    nil,
 		})
 	}
 
@@ -105,37 +67,19 @@ func JWTAuthMiddleware(c *fiber.Ctx) error {
 	return c.Next()
 }
 
-func ParseJWT(tokenStr string) (*models.Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(configs.EnvSecret()), nil
-	})
-
+func authenticateAPIKey(c *fiber.Ctx, apiKey string) error {
+	claims, err := utils.VerifyAPIKey(apiKey, c.IP())
 	if err != nil {
-		return nil, err
-	}
-
-	claims, ok := token.Claims.(*models.Claims)
-	if !ok {
-		return nil, errors.New("invalid token claims")
-	}
-
-	if claims.ExpiresAt < time.Now().Unix() {
-		return nil, errors.New("token has expired")
-	}
-
-	rawClaims, ok := token.Claims.(jwt.MapClaims)
-	if ok {
-		if userID, exists := rawClaims["user_id"].(string); exists && userID != "" {
-			if _, err := primitive.ObjectIDFromHex(userID); err != nil {
-				log.Printf("Invalid user_id format in token claims: %s", userID)
-				return nil, errors.New("invalid user_id format in token")
-			}
-			claims.UserID = userID
-		} else {
-			log.Printf("No valid user_id found in token claims: %+v", rawClaims)
-			return nil, errors.New("missing or invalid user_id in token")
+		if !errors.Is(err, utils.ErrInvalidAPIKey) {
+			log.Println("Error verifying API key:", err)
 		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid, expired or revoked API key",
+			"data":    nil,
+		})
 	}
 
-	return claims, nil
+	c.Locals("user", claims)
+	return c.Next()
 }
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePermission allows the request only when the authenticated user's
+// token grants every listed permission. It must run after Protected or
+// JWTAuthMiddleware.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := c.Locals("user").(*models.Claims)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Unauthorized - invalid token",
+			})
+		}
+
+		for _, permission := range permissions {
+			if !claims.HasPermission(permission) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Forbidden - missing permission " + permission,
+				})
+			}
+		}
+
+		return c.Next()
+	}
+}
@@ -1,10 +1,40 @@
 package models
 
-import "github.com/golang-jwt/jwt"
+import "github.com/golang-jwt/jwt/v5"
 
 type Claims struct {
-	Email    string `json:"email"`
-	Username string `json:"username"`
-	UserID   string `json:"user_id"`
-	jwt.StandardClaims
+	Email       string   `json:"email"`
+	Username    string   `json:"username"`
+	UserID      string   `json:"user_id"`
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// SessionID links the token to the login session it was issued for
+	SessionID string `json:"sid,omitempty"`
+	// ImpersonatorID is set when an admin acts as this user for support
+	ImpersonatorID string `json:"impersonator_id,omitempty"`
+	// APIKeyID is set when the request authenticated with an API key
+	APIKeyID string `json:"-"`
+	// OrgID is the organization an API key is scoped to
+	OrgID string `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// HasPermission reports whether the token grants the permission. Tokens
+// issued before roles existed carry no permissions and fall back to the
+// defaults of their role. API keys only grant their scopes, and impersonation
+// tokens never grant more than ImpersonationPermissions allows.
+func (c *Claims) HasPermission(permission string) bool {
+	if c.ImpersonatorID != "" && !impersonationPermissions[permission] {
+		return false
+	}
+	perms := c.Permissions
+	if len(perms) == 0 && c.APIKeyID == "" {
+		perms = ResolvePermissions(c.Role, nil)
+	}
+	for _, p := range perms {
+		if p == permission {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyScopes lists the permissions an API key may be granted. Account and
+// key management stay with interactive logins.
+var APIKeyScopes = []string{
+	PermPredict,
+	PermHistoryRead,
+	PermHistoryDelete,
+	PermProfileRead,
+	PermEventsRead,
+}
+
+// APIKey lets scripts call the API through the X-API-Key header. Only a hash
+// of the key is stored; the key itself is shown once when it is created.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	KeyHash    string             `bson:"key_hash" json:"-"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	OrgID      string             `bson:"org_id,omitempty" json:"org_id,omitempty"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"expiresAt"`
+	LastUsedAt time.Time          `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	LastUsedIP string             `bson:"lastUsedIp,omitempty" json:"lastUsedIp,omitempty"`
+	RevokedAt  time.Time          `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
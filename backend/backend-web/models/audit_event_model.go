@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit event results
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+)
+
+// Audit event actions
+const (
+	AuditLogin                  = "auth.login"
+	AuditLogout                 = "auth.logout"
+	AuditPasswordResetRequested = "auth.password_reset_requested"
+	AuditPasswordReset          = "auth.password_reset"
+	AuditPasswordChanged        = "auth.password_changed"
+	AuditEmailChanged           = "auth.email_changed"
+	AuditTwoFactorEnabled       = "auth.2fa_enabled"
+	AuditTwoFactorDisabled      = "auth.2fa_disabled"
+	AuditRecoveryCodesRenewed   = "auth.recovery_codes_regenerated"
+	AuditSessionRevoked         = "auth.session_revoked"
+	AuditIdentityLinked         = "auth.identity_linked"
+	AuditIdentityUnlinked       = "auth.identity_unlinked"
+	AuditAPIKeyCreated          = "auth.api_key_created"
+	AuditAPIKeyRevoked          = "auth.api_key_revoked"
+	AuditProfileUpdated         = "user.profile_updated"
+	AuditAvatarChanged          = "user.avatar_changed"
+	AuditDataExportRequested    = "user.data_export_requested"
+	AuditDeletionScheduled      = "user.deletion_scheduled"
+	AuditDeletionCancelled      = "user.deletion_cancelled"
+	AuditHistoryDeleted         = "history.deleted"
+	AuditAdminPrefix            = "admin."
+)
+
+// AuditEvent is an entry in the append-only security audit log. ActorID is
+// who acted and TargetUserID whose account was affected; both are empty for
+// failed logins to unknown accounts.
+type AuditEvent struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Action         string             `bson:"action" json:"action"`
+	Result         string             `bson:"result" json:"result"`
+	ActorID        string             `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	ImpersonatorID string             `bson:"impersonator_id,omitempty" json:"impersonator_id,omitempty"`
+	APIKeyID       string             `bson:"api_key_id,omitempty" json:"api_key_id,omitempty"`
+	TargetUserID   string             `bson:"target_user_id,omitempty" json:"target_user_id,omitempty"`
+	Details        map[string]string  `bson:"details,omitempty" json:"details,omitempty"`
+	IP             string             `bson:"ip" json:"ip"`
+	UserAgent      string             `bson:"user_agent" json:"user_agent"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt      time.Time          `bson:"expiresAt" json:"-"`
+}
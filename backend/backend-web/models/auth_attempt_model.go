@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuthAttempt counts recent failed sign-in or code attempts for one key, such
+// as an account or a client IP
+type AuthAttempt struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Key           string             `bson:"key" json:"key"`
+	Failures      int                `bson:"failures" json:"failures"`
+	LastFailureAt time.Time          `bson:"lastFailureAt" json:"lastFailureAt"`
+	LockedUntil   time.Time          `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	// PendingUntil is set while an attempt reserved with ReserveAttempt runs
+	PendingUntil time.Time `bson:"pendingUntil,omitempty" json:"-"`
+	ExpiresAt    time.Time `bson:"expiresAt" json:"expiresAt"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Data export job states
+const (
+	DataExportPending    = "pending"
+	DataExportProcessing = "processing"
+	DataExportReady      = "ready"
+	DataExportFailed     = "failed"
+)
+
+// DataExport is a background job that packs a user's data into a zip file
+// stored in GridFS
+type DataExport struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	Status      string             `bson:"status" json:"status"`
+	FileID      primitive.ObjectID `bson:"file_id,omitempty" json:"-"`
+	Size        int64              `bson:"size,omitempty" json:"size,omitempty"`
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	LockedAt    time.Time          `bson:"locked_at,omitempty" json:"-"`
+	CompletedAt time.Time          `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+	ExpiresAt   time.Time          `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Outbox email states
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSending = "sending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed"
+)
+
+// OutboxEmail is a rendered email waiting in, or delivered from, the outbox.
+// The bodies can hold one-time codes, so they are never returned as JSON and
+// are cleared once the email is sent or has failed for good.
+type OutboxEmail struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	To            string             `bson:"to" json:"to"`
+	Template      string             `bson:"template,omitempty" json:"template,omitempty"`
+	Subject       string             `bson:"subject" json:"subject"`
+	Text          string             `bson:"text,omitempty" json:"-"`
+	HTML          string             `bson:"html,omitempty" json:"-"`
+	Status        string             `bson:"status" json:"status"`
+	Transport     string             `bson:"transport,omitempty" json:"transport,omitempty"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	LockedAt      time.Time          `bson:"locked_at,omitempty" json:"-"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	SentAt        time.Time          `bson:"sentAt,omitempty" json:"sentAt,omitempty"`
+	ExpiresAt     time.Time          `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+}
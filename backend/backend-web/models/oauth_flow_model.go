@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OAuthFlow holds the per-login secrets between the redirect to the provider
+// and its callback. It is looked up by the state parameter and used once.
+type OAuthFlow struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	StateHash    string             `bson:"state_hash" json:"-"`
+	Provider     string             `bson:"provider" json:"provider"`
+	Nonce        string             `bson:"nonce" json:"-"`
+	CodeVerifier string             `bson:"code_verifier" json:"-"`
+	ReturnTo     string             `bson:"return_to" json:"return_to"`
+	// LinkUserID is set when a logged-in user connects another login
+	// instead of signing in
+	LinkUserID string    `bson:"link_user_id,omitempty" json:"link_user_id,omitempty"`
+	CreatedAt  time.Time `bson:"createdAt" json:"createdAt"`
+	ExpiresAt  time.Time `bson:"expiresAt" json:"expiresAt"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Organization membership roles, from most to least privileged
+const (
+	OrgRoleOwner     = "owner"
+	OrgRoleAdmin     = "admin"
+	OrgRoleInspector = "inspector"
+	OrgRoleViewer    = "viewer"
+)
+
+var orgRoleRank = map[string]int{
+	OrgRoleViewer:    1,
+	OrgRoleInspector: 2,
+	OrgRoleAdmin:     3,
+	OrgRoleOwner:     4,
+}
+
+// IsValidOrgRole reports whether role is one of the organization roles
+func IsValidOrgRole(role string) bool {
+	_, ok := orgRoleRank[role]
+	return ok
+}
+
+// OrgRoleAtLeast reports whether role grants at least the privileges of min
+func OrgRoleAtLeast(role, min string) bool {
+	return orgRoleRank[role] >= orgRoleRank[min] && orgRoleRank[min] > 0
+}
+
+type Organization struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	CreatedBy string             `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	// Require2FA blocks members without two-factor authentication from the organization
+	Require2FA bool `bson:"require2FA,omitempty" json:"require2FA"`
+}
+
+type OrganizationMember struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID    primitive.ObjectID `bson:"org_id" json:"org_id"`
+	UserID   string             `bson:"user_id" json:"user_id"`
+	Role     string             `bson:"role" json:"role"`
+	JoinedAt time.Time          `bson:"joinedAt" json:"joinedAt"`
+}
+
+type OrganizationInvitation struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID      primitive.ObjectID `bson:"org_id" json:"org_id"`
+	Email      string             `bson:"email" json:"email"`
+	Role       string             `bson:"role" json:"role"`
+	TokenHash  string             `bson:"token_hash" json:"-"`
+	InvitedBy  string             `bson:"invited_by" json:"invited_by"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"expiresAt"`
+	AcceptedAt time.Time          `bson:"acceptedAt,omitempty" json:"acceptedAt,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OTPCode is an emailed one-time code. Only a keyed hash of the code is stored.
+type OTPCode struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Purpose    string             `bson:"purpose" json:"purpose"`
+	Email      string             `bson:"email" json:"email"`
+	CodeHash   string             `bson:"code_hash" json:"-"`
+	Attempts   int                `bson:"attempts" json:"attempts"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"expiresAt"`
+	VerifiedAt time.Time          `bson:"verifiedAt,omitempty" json:"verifiedAt,omitempty"`
+}
@@ -0,0 +1,82 @@
+package models
+
+// Account roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permissions checked by middleware.RequirePermission
+const (
+	PermPredict        = "predict"
+	PermHistoryRead    = "history:read"
+	PermHistoryDelete  = "history:delete"
+	PermProfileRead    = "profile:read"
+	PermProfileWrite   = "profile:write"
+	PermEventsRead     = "events:read"
+	PermWebhooksManage = "webhooks:manage"
+	PermOrgsAccess     = "orgs:access"
+	PermAdminUsers     = "admin:users"
+)
+
+var userPermissions = []string{
+	PermPredict,
+	PermHistoryRead,
+	PermHistoryDelete,
+	PermProfileRead,
+	PermProfileWrite,
+	PermEventsRead,
+	PermWebhooksManage,
+	PermOrgsAccess,
+}
+
+// impersonationPermissions are the most an admin acting as a user can do.
+// Support can look at the account and reproduce predictions, but cannot
+// change credentials, profile data, webhooks or organizations.
+var impersonationPermissions = map[string]bool{
+	PermPredict:     true,
+	PermHistoryRead: true,
+	PermProfileRead: true,
+	PermEventsRead:  true,
+}
+
+// RolePermissions maps each role to the permissions it grants
+var RolePermissions = map[string][]string{
+	RoleUser:  userPermissions,
+	RoleAdmin: append(append([]string{}, userPermissions...), PermAdminUsers),
+}
+
+// IsValidRole reports whether role is a known account role
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// ResolvePermissions returns the role's permissions plus any extra grants.
+// Accounts without a role are treated as regular users.
+func ResolvePermissions(role string, extra []string) []string {
+	if role == "" {
+		role = RoleUser
+	}
+	seen := map[string]bool{}
+	perms := []string{}
+	for _, p := range append(append([]string{}, RolePermissions[role]...), extra...) {
+		if !seen[p] {
+			seen[p] = true
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
+
+// ImpersonationPermissions returns the subset of perms an impersonation token
+// may carry
+func ImpersonationPermissions(perms []string) []string {
+	allowed := []string{}
+	for _, p := range perms {
+		if impersonationPermissions[p] {
+			allowed = append(allowed, p)
+		}
+	}
+	return allowed
+}
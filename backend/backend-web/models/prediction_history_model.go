@@ -9,6 +9,7 @@ import (
 type PredictionHistory struct {
 	ID         primitive.ObjectID     `bson:"_id,omitempty" json:"_id"`
 	UserID     string                 `bson:"user_id"`
+	OrgID      string                 `bson:"org_id,omitempty" json:"org_id,omitempty"`
 	FileName   string                 `bson:"file_name"`
 	Percentage float64                `bson:"percentage_weight_lose"`
 	ImageUrl   string                 `bson:"ImageUrl" json:"ImageUrl"`
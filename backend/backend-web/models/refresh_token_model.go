@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is an opaque, single-use refresh token. Tokens rotated from
+// the same login share a FamilyID so that reuse can revoke the whole chain.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"user_id"`
+	FamilyID  string             `bson:"family_id"`
+	TokenHash string             `bson:"token_hash"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	UsedAt    time.Time          `bson:"usedAt,omitempty"`
+	RevokedAt time.Time          `bson:"revokedAt,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is one signed-in device. Its ID is embedded in access tokens as
+// the "sid" claim and doubles as the refresh token family ID.
+type Session struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	Device     string             `bson:"device" json:"device"`
+	UserAgent  string             `bson:"user_agent" json:"user_agent"`
+	IP         string             `bson:"ip" json:"ip"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	LastSeenAt time.Time          `bson:"lastSeenAt" json:"lastSeenAt"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"expiresAt"`
+	RevokedAt  time.Time          `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	// ImpersonatorID is set on sessions an admin opened to act as the user
+	ImpersonatorID string `bson:"impersonator_id,omitempty" json:"impersonator_id,omitempty"`
+}
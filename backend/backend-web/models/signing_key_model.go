@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SigningKey is a key pair used to sign access tokens. Only the newest key of
+// the configured algorithm signs; retired keys keep verifying until every
+// token they signed has expired. The private key is only stored encrypted
+// with SIGNING_KEY_KEK.
+type SigningKey struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kid                 string             `bson:"kid" json:"kid"`
+	Algorithm           string             `bson:"algorithm" json:"algorithm"`
+	EncryptedPrivateKey string             `bson:"encrypted_private_key" json:"-"`
+	PublicKey           string             `bson:"public_key" json:"public_key"`
+	CreatedAt           time.Time          `bson:"createdAt" json:"createdAt"`
+	RetiredAt           time.Time          `bson:"retiredAt,omitempty" json:"retiredAt,omitempty"`
+	ExpiresAt           time.Time          `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+}
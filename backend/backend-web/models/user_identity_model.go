@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserIdentity links an external login (provider + subject) to a user. A user
+// can have several, one per provider.
+type UserIdentity struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	Provider   string             `bson:"provider" json:"provider"`
+	Subject    string             `bson:"subject" json:"-"`
+	Email      string             `bson:"email" json:"email"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	LastUsedAt time.Time          `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+}
+
+// IdentityLinkRequest holds an OAuth login whose email matches an existing
+// password account. The identity is only linked once the account owner
+// confirms with their password.
+type IdentityLinkRequest struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Provider  string             `bson:"provider" json:"provider"`
+	Subject   string             `bson:"subject" json:"-"`
+	Email     string             `bson:"email" json:"email"`
+	ReturnTo  string             `bson:"return_to" json:"return_to"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+}
@@ -12,9 +12,23 @@ type User struct {
 	Password             string             `json:"password" validate:"required"`
 	Email                string             `json:"email" validate:"email, required"`
 	EmailVerified        bool               `bson:"emailVerified" json:"emailVerified"`
-	VerificationCode     string             `bson:"verificationCode" json:"-"`
+	PendingEmail         string             `bson:"pendingEmail,omitempty" json:"pendingEmail,omitempty"`
 	Avatar               primitive.ObjectID `bson:"avatar,omitempty" json:"avatar,omitempty"`
 	CreatedAt            time.Time          `bson:"createdAt"`
 	LastVerificationSent time.Time          `bson:"lastVerificationSent,omitempty"`
 	ExpiresAt            time.Time          `bson:"expiresAt,omitempty"`
+	Role                 string             `bson:"role,omitempty" json:"role,omitempty"`
+	Permissions          []string           `bson:"permissions,omitempty" json:"permissions,omitempty"`
+	Disabled             bool               `bson:"disabled,omitempty" json:"disabled,omitempty"`
+	DisabledAt           time.Time          `bson:"disabledAt,omitempty" json:"disabledAt,omitempty"`
+	DisabledReason       string             `bson:"disabledReason,omitempty" json:"disabledReason,omitempty"`
+	MustResetPassword    bool               `bson:"mustResetPassword,omitempty" json:"mustResetPassword,omitempty"`
+	ActiveOrgID          primitive.ObjectID `bson:"activeOrgId,omitempty" json:"activeOrgId,omitempty"`
+	TwoFactorEnabled     bool               `bson:"twoFactorEnabled,omitempty" json:"twoFactorEnabled,omitempty"`
+	TwoFactorSecret      string             `bson:"twoFactorSecret,omitempty" json:"-"`
+	TwoFactorPending     string             `bson:"twoFactorPending,omitempty" json:"-"`
+	TwoFactorLastStep    int64              `bson:"twoFactorLastStep,omitempty" json:"-"`
+	RecoveryCodes        []string           `bson:"recoveryCodes,omitempty" json:"-"`
+	DeletionScheduledAt  time.Time          `bson:"deletionScheduledAt,omitempty" json:"deletionScheduledAt,omitempty"`
+	Locale               string             `bson:"locale,omitempty" json:"locale,omitempty"`
 }
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook event types that can be subscribed to. Only events the API
+// actually emits belong here.
+const (
+	WebhookEventPredictionCreated = "prediction.created"
+	WebhookEventHistoryDeleted    = "history.deleted"
+)
+
+// WebhookEventTypes lists every event a subscription may ask for
+var WebhookEventTypes = []string{
+	WebhookEventPredictionCreated,
+	WebhookEventHistoryDeleted,
+}
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySending   = "sending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+type WebhookSubscription struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	OrgID     string             `bson:"org_id,omitempty" json:"org_id,omitempty"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"-"`
+	Events    []string           `bson:"events" json:"events"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SubscriptionID primitive.ObjectID `bson:"subscription_id" json:"subscription_id"`
+	UserID         string             `bson:"user_id" json:"user_id"`
+	Event          string             `bson:"event" json:"event"`
+	Payload        string             `bson:"payload" json:"payload"`
+	Status         string             `bson:"status" json:"status"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LastStatusCode int                `bson:"last_status_code,omitempty" json:"last_status_code,omitempty"`
+	LastError      string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	LockedAt       time.Time          `bson:"locked_at,omitempty" json:"-"`
+	RedeliveryOf   primitive.ObjectID `bson:"redelivery_of,omitempty" json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	DeliveredAt    time.Time          `bson:"deliveredAt,omitempty" json:"deliveredAt,omitempty"`
+	ExpiresAt      time.Time          `bson:"expiresAt" json:"-"`
+}
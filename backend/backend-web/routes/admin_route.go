@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"backend-web/controllers"
+	"backend-web/middleware"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+)
+
+func AdminRoute(app *fiber.App) {
+	api := app.Group("/api", logger.New())
+
+	admin := api.Group("/admin")
+	admin.Use(middleware.Protected(), middleware.RequirePermission(models.PermAdminUsers))
+
+	admin.Get("/users", controllers.AdminListUsers)
+	admin.Get("/users/:id", controllers.AdminGetUser)
+	admin.Patch("/users/:id/status", controllers.AdminSetUserStatus)
+	admin.Post("/users/:id/force-password-reset", controllers.AdminForcePasswordReset)
+	admin.Post("/users/:id/resend-verification", controllers.AdminResendVerification)
+	admin.Post("/users/:id/impersonate", controllers.AdminImpersonateUser)
+	admin.Delete("/users/:id", controllers.AdminDeleteUser)
+	admin.Get("/audit-events", controllers.AdminListAuditEvents)
+	admin.Get("/emails", controllers.AdminListEmails)
+	admin.Post("/emails/:id/retry", controllers.AdminRetryEmail)
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"backend-web/controllers"
+	"backend-web/middleware"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+)
+
+func APIKeyRoute(app *fiber.App) {
+	api := app.Group("/api", logger.New())
+
+	// API keys never carry profile:write, so they cannot mint more keys
+	keys := api.Group("/api-keys")
+	keys.Use(middleware.Protected(), middleware.RequirePermission(models.PermProfileWrite))
+
+	keys.Post("/", controllers.CreateAPIKey)
+	keys.Get("/", controllers.GetAPIKeys)
+	keys.Delete("/:id", controllers.RevokeAPIKey)
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"backend-web/controllers/auth/auth-login"
 	"backend-web/middleware"
+	"backend-web/models"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,6 +16,18 @@ func AuthUserRoute(app *fiber.App) {
 
 	auth := api.Group("/auth")
 	auth.Post("/login", controllers.Login)
+	auth.Post("/login/2fa", limiter.New(limiter.Config{
+		Max:        10,
+		Expiration: 1 * time.Minute,
+	}), controllers.LoginTwoFactor)
+	auth.Post("/magic-link", limiter.New(limiter.Config{
+		Max:        5,
+		Expiration: 1 * time.Minute,
+	}), controllers.RequestMagicLink)
+	auth.Post("/magic-link/verify", limiter.New(limiter.Config{
+		Max:        10,
+		Expiration: 1 * time.Minute,
+	}), controllers.VerifyMagicLink)
 	auth.Post("/register", controllers.Register)
 	auth.Post("/verify-email", controllers.VerifyEmail)
 	auth.Post("/resend-otp", controllers.ResendVerification)
@@ -33,7 +46,42 @@ func AuthUserRoute(app *fiber.App) {
 		Expiration: 1 * time.Minute,
 	}), controllers.ResendResetOTP)
 	auth.Post("/reset-password", controllers.ResetPassword)
+	auth.Get("/password-policy", controllers.GetPasswordPolicy)
+	auth.Post("/change-password", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.ChangePassword)
+	auth.Post("/change-email", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.RequestEmailChange)
+	auth.Post("/change-email/confirm", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.ConfirmEmailChange)
+
+	auth.Post("/refresh", limiter.New(limiter.Config{
+		Max:        30,
+		Expiration: 1 * time.Minute,
+	}), controllers.RefreshToken)
 
 	auth.Post("/logout", middleware.JWTAuthMiddleware, controllers.Logout)
-	auth.Get("/user", middleware.JWTAuthMiddleware, controllers.GetUserInfo)
+	auth.Get("/sessions", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileRead), controllers.GetSessions)
+	auth.Delete("/sessions", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.RevokeAllSessions)
+	auth.Delete("/sessions/:id", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.RevokeSession)
+	auth.Post("/2fa/enroll", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.EnrollTwoFactor)
+	auth.Post("/2fa/confirm", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.ConfirmTwoFactor)
+	auth.Post("/2fa/disable", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.DisableTwoFactor)
+	auth.Post("/2fa/recovery-codes", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.RegenerateRecoveryCodes)
+	auth.Get("/identities", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileRead), controllers.GetIdentities)
+	auth.Post("/identities/confirm", limiter.New(limiter.Config{
+		Max:        10,
+		Expiration: 1 * time.Minute,
+	}), controllers.ConfirmIdentityLink)
+	auth.Post("/identities/:provider", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.StartIdentityLink)
+	auth.Delete("/identities/:provider", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.UnlinkIdentity)
+	auth.Get("/user", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileRead), controllers.GetUserInfo)
+
+	account := api.Group("/account")
+	account.Post("/export", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.RequestDataExport)
+	account.Get("/exports", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileRead), controllers.GetDataExports)
+	account.Get("/exports/:id/download", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileRead), controllers.DownloadDataExport)
+	account.Post("/delete/otp", limiter.New(limiter.Config{
+		Max:        5,
+		Expiration: 1 * time.Minute,
+	}), middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.RequestAccountDeletionOTP)
+	account.Post("/delete", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.DeleteAccount)
+	account.Get("/audit-events", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileRead), controllers.GetAuditEvents)
+	account.Post("/delete/cancel", middleware.JWTAuthMiddleware, middleware.RequirePermission(models.PermProfileWrite), controllers.CancelAccountDeletion)
 }
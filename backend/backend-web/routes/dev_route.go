@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"backend-web/configs"
+	"backend-web/controllers"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+)
+
+// DevRoute registers development helpers. They are only mounted when
+// EMAIL_PREVIEW is enabled.
+func DevRoute(app *fiber.App) {
+	if !configs.EnvEmailPreview() {
+		return
+	}
+	log.Println("⚠️ Email template preview enabled at /api/dev/emails")
+
+	dev := app.Group("/api/dev", logger.New())
+	dev.Get("/emails", controllers.ListEmailTemplates)
+	dev.Get("/emails/:name", controllers.PreviewEmailTemplate)
+}
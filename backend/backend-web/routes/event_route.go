@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"backend-web/controllers"
+	"backend-web/middleware"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+)
+
+func EventRoute(app *fiber.App) {
+	api := app.Group("/api", logger.New())
+	api.Get("/events", middleware.Protected(), middleware.RequirePermission(models.PermEventsRead), controllers.StreamEvents)
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"backend-web/controllers"
 	"backend-web/middleware"
+	"backend-web/models"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -13,9 +14,9 @@ func HistoryRoute(app *fiber.App) {
 	api := app.Group("/api", logger.New())
 
 	history := api.Group("/history")
-	history.Use(middleware.Protected())
+	history.Use(middleware.Protected(), middleware.RequirePermission(models.PermHistoryRead))
 
 	history.Get("/", controllers.GetPredictionHistory)
 	history.Get("/:id", controllers.GetPredictionHistoryByID)
-	history.Delete("/:id", controllers.DeleteHistory)
+	history.Delete("/:id", middleware.RequirePermission(models.PermHistoryDelete), controllers.DeleteHistory)
 }
@@ -11,6 +11,11 @@ func OAuthRoute(app *fiber.App) {
 	api := app.Group("/api", logger.New())
 
 	oauth := api.Group("/auth")
+	oauth.Get("/oauth/providers", controllers.GetOAuthProviders)
+	oauth.Get("/oauth/:provider", controllers.OAuthLoginHandler)
+	oauth.Get("/callback/:provider", controllers.OAuthCallbackHandler)
+
+	// Provider-less routes kept for the existing Google redirect URI
 	oauth.Get("/oauth", controllers.OAuthLoginHandler)
 	oauth.Get("/callback", controllers.OAuthCallbackHandler)
 }
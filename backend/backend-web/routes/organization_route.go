@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"backend-web/controllers"
+	"backend-web/middleware"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+)
+
+func OrganizationRoute(app *fiber.App) {
+	api := app.Group("/api", logger.New())
+
+	orgs := api.Group("/orgs")
+	orgs.Use(middleware.Protected(), middleware.RequirePermission(models.PermOrgsAccess))
+
+	orgs.Post("/", controllers.CreateOrganization)
+	orgs.Get("/", controllers.GetOrganizations)
+	orgs.Post("/active", controllers.SetActiveOrganization)
+	orgs.Post("/invitations/accept", controllers.AcceptInvitation)
+
+	orgs.Get("/:id", controllers.GetOrganization)
+	orgs.Patch("/:id", controllers.UpdateOrganization)
+	orgs.Patch("/:id/members/:userId", controllers.UpdateOrganizationMember)
+	orgs.Delete("/:id/members/:userId", controllers.RemoveOrganizationMember)
+	orgs.Post("/:id/invitations", controllers.CreateInvitation)
+	orgs.Get("/:id/invitations", controllers.GetInvitations)
+	orgs.Delete("/:id/invitations/:invitationId", controllers.RevokeInvitation)
+	orgs.Post("/:id/api-keys", controllers.CreateOrgAPIKey)
+	orgs.Get("/:id/api-keys", controllers.GetOrgAPIKeys)
+	orgs.Delete("/:id/api-keys/:keyId", controllers.RevokeOrgAPIKey)
+}
@@ -2,12 +2,14 @@ package routes
 
 import (
 	"backend-web/controllers"
+	"backend-web/middleware"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
 func PredictionRoute(app *fiber.App) {
 	api := app.Group("/api", logger.New())
-	api.Post("/predict", controllers.PredictHandler)
+	// Public: anonymous users may predict, PredictHandler checks the permission of signed-in callers
+	api.Post("/predict", middleware.OptionalAuth(), controllers.PredictHandler)
 	api.Get("/image/:fileId", controllers.GetImage)
 }
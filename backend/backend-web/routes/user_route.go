@@ -3,6 +3,7 @@ package routes
 import (
 	"backend-web/controllers"
 	"backend-web/middleware"
+	"backend-web/models"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -12,8 +13,8 @@ func UserRoute(app *fiber.App) {
 	api := app.Group("/api", logger.New())
 
 	user := api.Group("/user")
-	user.Get("/:id", middleware.Protected(), controllers.GetUser)
-	user.Patch("/:id", middleware.Protected(), controllers.UpdateUser)
-	user.Post("/avatar", middleware.Protected(), controllers.UploadAvatar)
+	user.Get("/:id", middleware.Protected(), middleware.RequirePermission(models.PermProfileRead), controllers.GetUser)
+	user.Patch("/:id", middleware.Protected(), middleware.RequirePermission(models.PermProfileWrite), controllers.UpdateUser)
+	user.Post("/avatar", middleware.Protected(), middleware.RequirePermission(models.PermProfileWrite), controllers.UploadAvatar)
 	user.Get("/avatar/:fileId", controllers.GetAvatar)
 }
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"backend-web/controllers"
+	"backend-web/middleware"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+)
+
+func WebhookRoute(app *fiber.App) {
+	api := app.Group("/api", logger.New())
+
+	webhooks := api.Group("/webhooks")
+	webhooks.Use(middleware.Protected(), middleware.RequirePermission(models.PermWebhooksManage))
+
+	webhooks.Post("/", controllers.CreateWebhook)
+	webhooks.Get("/", controllers.GetWebhooks)
+	webhooks.Patch("/:id", controllers.UpdateWebhook)
+	webhooks.Delete("/:id", controllers.DeleteWebhook)
+	webhooks.Get("/:id/deliveries", controllers.GetWebhookDeliveries)
+	webhooks.Post("/:id/deliveries/:deliveryId/redeliver", controllers.RedeliverWebhook)
+}
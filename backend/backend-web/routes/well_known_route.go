@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"backend-web/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func WellKnownRoute(app *fiber.App) {
+	app.Get("/.well-known/jwks.json", controllers.GetJWKS)
+}
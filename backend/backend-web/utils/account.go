@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const accountDeletionPollInterval = time.Hour
+
+// ScheduleAccountDeletion marks the account for deletion once the grace
+// period ends and returns when that will happen
+func ScheduleAccountDeletion(user *models.User) (time.Time, error) {
+	deleteAt := time.Now().Add(configs.EnvAccountDeletionGrace())
+	_, err := configs.GetCollection(configs.DB, "users").UpdateOne(context.TODO(),
+		bson.M{"_id": user.Id},
+		bson.M{"$set": bson.M{"deletionScheduledAt": deleteAt}},
+	)
+	return deleteAt, err
+}
+
+// CancelAccountDeletion keeps an account that was scheduled for deletion. It
+// reports whether a deletion was pending.
+func CancelAccountDeletion(userID primitive.ObjectID) (bool, error) {
+	result, err := configs.GetCollection(configs.DB, "users").UpdateOne(context.TODO(),
+		bson.M{"_id": userID, "deletionScheduledAt": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deletionScheduledAt": ""}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// StartAccountDeletionWorker purges accounts whose deletion grace period has ended
+func StartAccountDeletionWorker() {
+	go func() {
+		purgeScheduledAccounts()
+		ticker := time.NewTicker(accountDeletionPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purgeScheduledAccounts()
+		}
+	}()
+	log.Println("✅ Account deletion worker started")
+}
+
+func purgeScheduledAccounts() {
+	users := configs.GetCollection(configs.DB, "users")
+	cursor, err := users.Find(context.TODO(), bson.M{"deletionScheduledAt": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		log.Printf("Error: Failed to query accounts scheduled for deletion - %v", err)
+		return
+	}
+	var due []models.User
+	if err := cursor.All(context.TODO(), &due); err != nil {
+		log.Printf("Error: Failed to decode accounts scheduled for deletion - %v", err)
+		return
+	}
+
+	for i := range due {
+		user := &due[i]
+		if err := PurgeUserData(user); err != nil {
+			log.Printf("Error: Failed to delete account %s - %v", user.Id.Hex(), err)
+			continue
+		}
+		log.Printf("Deleted account %s after its grace period", user.Id.Hex())
+		if err := SendAccountDeletedEmail(user.Email, user.Locale); err != nil {
+			log.Println("Error sending account deleted email:", err)
+		}
+	}
+}
+
+// PurgeUserData permanently deletes a user together with everything they own:
+// prediction history, GridFS files (avatar and prediction images), one-time
+// codes, password reset tokens, login links, linked identities, sessions, refresh tokens,
+// API keys, data exports, queued emails, webhooks and organization memberships. Organizations left without members
+// are deleted as well. Security audit events are kept until their retention
+// period ends.
+func PurgeUserData(user *models.User) error {
+	ctx := context.TODO()
+	userID := user.Id.Hex()
+
+	// GridFS files store the owner either as ObjectID (avatars) or hex string (predictions)
+	fsFiles := configs.GetCollection(configs.DB, "fs.files")
+	cursor, err := fsFiles.Find(ctx, bson.M{"metadata.user_id": bson.M{"$in": bson.A{user.Id, userID}}})
+	if err != nil {
+		return err
+	}
+	var files []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &files); err != nil {
+		return err
+	}
+	bucket := configs.GetGridFSBucket(configs.DB)
+	for _, f := range files {
+		if err := bucket.Delete(f.ID); err != nil {
+			log.Printf("Error deleting GridFS file %s: %v", f.ID.Hex(), err)
+		}
+	}
+	if !user.Avatar.IsZero() {
+		bucket.Delete(user.Avatar)
+	}
+
+	if _, err := configs.GetCollection(configs.DB, "prediction_history").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "otp_codes").DeleteMany(ctx, bson.M{"email": bson.M{"$in": bson.A{user.Email, user.PendingEmail}}}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "password_reset_tokens").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "refresh_tokens").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "sessions").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	forgetSessions(userID, "")
+	if _, err := configs.GetCollection(configs.DB, "magic_links").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "user_identities").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "identity_link_requests").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "data_exports").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "api_keys").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "mfa_challenges").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "email_outbox").DeleteMany(ctx, bson.M{"to": bson.M{"$in": bson.A{user.Email, user.PendingEmail}}}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "webhook_deliveries").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if _, err := configs.GetCollection(configs.DB, "webhook_subscriptions").DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+
+	members := configs.GetCollection(configs.DB, "organization_members")
+	orgIDs, err := UserOrgIDs(userID, models.OrgRoleViewer)
+	if err != nil {
+		return err
+	}
+	if _, err := members.DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	for _, orgID := range orgIDs {
+		objID, _ := primitive.ObjectIDFromHex(orgID)
+		remaining, err := members.CountDocuments(ctx, bson.M{"org_id": objID})
+		if err == nil && remaining == 0 {
+			configs.GetCollection(configs.DB, "organizations").DeleteOne(ctx, bson.M{"_id": objID})
+			configs.GetCollection(configs.DB, "organization_invitations").DeleteMany(ctx, bson.M{"org_id": objID})
+		} else if err == nil {
+			// The remaining members must not be left without an owner
+			if err := EnsureOrgOwner(ctx, objID); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = configs.GetCollection(configs.DB, "users").DeleteOne(ctx, bson.M{"_id": user.Id})
+	return err
+}
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// APIKeyPrefix marks API keys so they are easy to spot in scripts and scanners
+	APIKeyPrefix      = "kale_"
+	APIKeyDefaultTTL  = 90 * 24 * time.Hour
+	APIKeyMaxTTL      = 365 * 24 * time.Hour
+	apiKeyTouchPeriod = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey      = errors.New("invalid, expired or revoked API key")
+	ErrInvalidAPIKeyScope = errors.New("unsupported API key scope")
+)
+
+// ValidAPIKeyScopes reports whether every scope may be granted to an API key
+// by a user holding the given permissions
+func ValidAPIKeyScopes(scopes, permissions []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		if !containsString(models.APIKeyScopes, scope) || !containsString(permissions, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateAPIKey stores a new API key for the user, scoped to the organization
+// when orgID is set, and returns the raw key. The raw key cannot be recovered
+// later.
+func CreateAPIKey(user *models.User, orgID, name string, scopes []string, ttl time.Duration) (string, *models.APIKey, error) {
+	if !ValidAPIKeyScopes(scopes, models.ResolvePermissions(user.Role, user.Permissions)) {
+		return "", nil, ErrInvalidAPIKeyScope
+	}
+
+	secret, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	raw := APIKeyPrefix + secret
+
+	now := time.Now()
+	key := models.APIKey{
+		ID:        primitive.NewObjectID(),
+		Name:      name,
+		Prefix:    raw[:len(APIKeyPrefix)+8],
+		KeyHash:   HashToken(raw),
+		UserID:    user.Id.Hex(),
+		OrgID:     orgID,
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if _, err := configs.GetCollection(configs.DB, "api_keys").InsertOne(context.TODO(), key); err != nil {
+		return "", nil, err
+	}
+	return raw, &key, nil
+}
+
+// VerifyAPIKey looks up an unexpired, unrevoked API key and returns claims
+// for its owner. The claims only carry the key's scopes that the owner still
+// holds, and organization keys stop working once the owner leaves the
+// organization.
+func VerifyAPIKey(raw, ip string) (*models.Claims, error) {
+	if !strings.HasPrefix(raw, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	keys := configs.GetCollection(configs.DB, "api_keys")
+	now := time.Now()
+
+	var key models.APIKey
+	err := keys.FindOne(context.TODO(), bson.M{
+		"key_hash":  HashToken(raw),
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": now},
+	}).Decode(&key)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	userID, err := primitive.ObjectIDFromHex(key.UserID)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	var user models.User
+	if err := configs.GetCollection(configs.DB, "users").FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.OrgID != "" {
+		orgID, err := primitive.ObjectIDFromHex(key.OrgID)
+		if err != nil {
+			return nil, ErrInvalidAPIKey
+		}
+		member, err := GetOrgMembership(orgID, key.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if member == nil {
+			return nil, ErrInvalidAPIKey
+		}
+	}
+
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	granted := models.ResolvePermissions(role, user.Permissions)
+	permissions := []string{}
+	for _, scope := range key.Scopes {
+		if containsString(granted, scope) {
+			permissions = append(permissions, scope)
+		}
+	}
+
+	// Only write last-used details once a minute so busy scripts do not hammer the database
+	if now.Sub(key.LastUsedAt) > apiKeyTouchPeriod {
+		keys.UpdateOne(context.TODO(), bson.M{"_id": key.ID}, bson.M{
+			"$set": bson.M{"lastUsedAt": now, "lastUsedIp": ip},
+		})
+	}
+
+	return &models.Claims{
+		Email:       user.Email,
+		Username:    user.Username,
+		UserID:      key.UserID,
+		Role:        role,
+		Permissions: permissions,
+		APIKeyID:    key.ID.Hex(),
+		OrgID:       key.OrgID,
+	}, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RecordAuditEvent appends an event to the security audit log. The client
+// IP, user agent, and any impersonating admin or API key are taken from the
+// request. Failures are logged and never block the request.
+func RecordAuditEvent(c *fiber.Ctx, action, actorID, targetUserID, result string, details map[string]string) {
+	now := time.Now()
+	event := models.AuditEvent{
+		ID:           primitive.NewObjectID(),
+		Action:       action,
+		Result:       result,
+		ActorID:      actorID,
+		TargetUserID: targetUserID,
+		Details:      details,
+		IP:           c.IP(),
+		UserAgent:    c.Get("User-Agent"),
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(configs.EnvAuditRetention()),
+	}
+	if claims, ok := c.Locals("user").(*models.Claims); ok && claims.UserID == actorID {
+		event.ImpersonatorID = claims.ImpersonatorID
+		event.APIKeyID = claims.APIKeyID
+	}
+
+	collection := configs.GetCollection(configs.DB, "audit_events")
+	if _, err := collection.InsertOne(context.TODO(), event); err != nil {
+		log.Printf("Error: Failed to record audit event %s for %s - %v", action, targetUserID, err)
+	}
+}
+
+// RecordAdminAction records an action an admin took on a user's account
+func RecordAdminAction(c *fiber.Ctx, actorID, action, targetUserID string, details map[string]string) {
+	RecordAuditEvent(c, models.AuditAdminPrefix+action, actorID, targetUserID, models.AuditResultSuccess, details)
+}
+
+// AuditQueryFilter builds a filter from the action, result, from and to
+// query parameters. Times are RFC 3339; invalid ones are ignored.
+func AuditQueryFilter(c *fiber.Ctx) bson.M {
+	filter := bson.M{}
+	if action := c.Query("action"); action != "" {
+		filter["action"] = action
+	}
+	if result := c.Query("result"); result != "" {
+		filter["result"] = result
+	}
+	createdAt := bson.M{}
+	if from, err := time.Parse(time.RFC3339, c.Query("from")); err == nil {
+		createdAt["$gte"] = from
+	}
+	if to, err := time.Parse(time.RFC3339, c.Query("to")); err == nil {
+		createdAt["$lt"] = to
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+	return filter
+}
+
+// QueryAuditEvents returns one page of matching events, newest first, and the total count
+func QueryAuditEvents(filter bson.M, page, limit int) ([]models.AuditEvent, int64, error) {
+	collection := configs.GetCollection(configs.DB, "audit_events")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	events := []models.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
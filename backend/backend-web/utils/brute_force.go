@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// attemptReservation bounds how long a reserved attempt blocks the key when
+// it never reports back, e.g. because the request failed for another reason
+const attemptReservation = 10 * time.Second
+
+// AttemptPolicy describes how failures for one kind of key are throttled.
+// After DelayAfter failures each attempt must wait an exponentially growing
+// delay, and after Limit failures the key is locked for LockFor.
+type AttemptPolicy struct {
+	DelayAfter int
+	MaxDelay   time.Duration
+	Limit      int
+	LockFor    time.Duration
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+}
+
+var (
+	// AccountAttemptPolicy protects a single account from password and code guessing
+	AccountAttemptPolicy = AttemptPolicy{DelayAfter: 3, MaxDelay: 30 * time.Second, Limit: 10, LockFor: 15 * time.Minute, Window: time.Hour}
+	// IPAttemptPolicy slows down a client trying many accounts
+	IPAttemptPolicy = AttemptPolicy{DelayAfter: 10, MaxDelay: time.Minute, Limit: 50, LockFor: 30 * time.Minute, Window: time.Hour}
+)
+
+// AttemptKey builds a counter key such as "login:account:<id>"
+func AttemptKey(scope, kind, value string) string {
+	return scope + ":" + kind + ":" + strings.ToLower(strings.TrimSpace(value))
+}
+
+// retryAfter returns how long the key must wait before another attempt
+func (p AttemptPolicy) retryAfter(attempt models.AuthAttempt, now time.Time) time.Duration {
+	if now.Before(attempt.LockedUntil) {
+		return attempt.LockedUntil.Sub(now)
+	}
+	if attempt.Failures < p.DelayAfter {
+		return 0
+	}
+	delay := time.Duration(math.Pow(2, float64(attempt.Failures-p.DelayAfter))) * time.Second
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	if wait := attempt.LastFailureAt.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// CheckAttempts returns how long the caller must wait before trying again,
+// or zero when the key may attempt now
+func CheckAttempts(key string, policy AttemptPolicy) (time.Duration, error) {
+	collection := configs.GetCollection(configs.DB, "auth_attempts")
+
+	var attempt models.AuthAttempt
+	err := collection.FindOne(context.TODO(), bson.M{"key": key}).Decode(&attempt)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return policy.retryAfter(attempt, time.Now()), nil
+}
+
+// ReserveAttempt atomically claims one attempt on the key, so concurrent
+// requests cannot all pass the check before any failure is counted. It
+// returns how long the caller must wait instead, because of the policy or
+// because another attempt on the key is still running. The reservation ends
+// with RecordFailure or ResetAttempts, or lapses after attemptReservation.
+func ReserveAttempt(key string, policy AttemptPolicy) (time.Duration, error) {
+	collection := configs.GetCollection(configs.DB, "auth_attempts")
+	now := time.Now()
+
+	var attempt models.AuthAttempt
+	err := collection.FindOneAndUpdate(context.TODO(),
+		bson.M{"key": key, "pendingUntil": bson.M{"$not": bson.M{"$gt": now}}},
+		bson.M{
+			"$set":         bson.M{"pendingUntil": now.Add(attemptReservation)},
+			"$setOnInsert": bson.M{"failures": 0, "expiresAt": now.Add(policy.Window)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		// The upsert collides with the existing key while it is reserved
+		if mongo.IsDuplicateKeyError(err) {
+			return time.Second, nil
+		}
+		return 0, err
+	}
+
+	if wait := policy.retryAfter(attempt, now); wait > 0 {
+		_, err := collection.UpdateOne(context.TODO(),
+			bson.M{"_id": attempt.ID},
+			bson.M{"$unset": bson.M{"pendingUntil": ""}},
+		)
+		return wait, err
+	}
+	return 0, nil
+}
+
+// RecordFailure counts a failed attempt, ends its reservation, and reports
+// whether it just locked the key
+func RecordFailure(key string, policy AttemptPolicy) (bool, error) {
+	collection := configs.GetCollection(configs.DB, "auth_attempts")
+	now := time.Now()
+
+	var attempt models.AuthAttempt
+	err := collection.FindOneAndUpdate(context.TODO(),
+		bson.M{"key": key},
+		bson.M{
+			"$inc":   bson.M{"failures": 1},
+			"$set":   bson.M{"lastFailureAt": now, "expiresAt": now.Add(policy.Window)},
+			"$unset": bson.M{"pendingUntil": ""},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		return false, err
+	}
+
+	if attempt.Failures < policy.Limit || now.Before(attempt.LockedUntil) {
+		return false, nil
+	}
+
+	// Start a fresh count once the lock ends so the next failure is not
+	// locked again straight away
+	lockedUntil := now.Add(policy.LockFor)
+	_, err = collection.UpdateOne(context.TODO(),
+		bson.M{"_id": attempt.ID},
+		bson.M{"$set": bson.M{
+			"failures":    0,
+			"lockedUntil": lockedUntil,
+			"expiresAt":   lockedUntil.Add(policy.Window),
+		}},
+	)
+	return err == nil, err
+}
+
+// ReleaseAttempt ends a reservation without counting a failure
+func ReleaseAttempt(key string) error {
+	collection := configs.GetCollection(configs.DB, "auth_attempts")
+	_, err := collection.UpdateOne(context.TODO(),
+		bson.M{"key": key},
+		bson.M{"$unset": bson.M{"pendingUntil": ""}},
+	)
+	return err
+}
+
+// ResetAttempts forgets the failures of a key after a successful attempt
+func ResetAttempts(key string) error {
+	collection := configs.GetCollection(configs.DB, "auth_attempts")
+	_, err := collection.DeleteOne(context.TODO(), bson.M{"key": key})
+	return err
+}
@@ -0,0 +1,305 @@
+package utils
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DataExportTTL is how long a finished export can be downloaded
+	DataExportTTL          = 7 * 24 * time.Hour
+	dataExportPollInterval = 10 * time.Second
+	dataExportLockTimeout  = 30 * time.Minute
+)
+
+var ErrDataExportInProgress = errors.New("a data export is already in progress")
+
+// DataExportDownloadURL is the frontend page that downloads the finished
+// export for its signed-in owner. The API route itself needs a bearer token,
+// which a link opened from a mail client does not carry.
+func DataExportDownloadURL(exportID primitive.ObjectID) string {
+	return strings.TrimSuffix(configs.EnvFrontendURL(), "/") + "/account/exports/download?id=" + exportID.Hex()
+}
+
+// CreateDataExport queues a new export of the user's data. It returns
+// ErrDataExportInProgress while an earlier export is still being built.
+func CreateDataExport(userID string) (*models.DataExport, error) {
+	collection := configs.GetCollection(configs.DB, "data_exports")
+
+	running, err := collection.CountDocuments(context.TODO(), bson.M{
+		"user_id": userID,
+		"status":  bson.M{"$in": bson.A{models.DataExportPending, models.DataExportProcessing}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if running > 0 {
+		return nil, ErrDataExportInProgress
+	}
+
+	export := models.DataExport{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Status:    models.DataExportPending,
+		CreatedAt: time.Now(),
+	}
+	if _, err := collection.InsertOne(context.TODO(), export); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// StartDataExportWorker builds queued exports in the background and removes
+// exports once they expire
+func StartDataExportWorker() {
+	go func() {
+		ticker := time.NewTicker(dataExportPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			releaseStaleDataExports()
+			deleteExpiredDataExports()
+			for processNextDataExport() {
+			}
+		}
+	}()
+	log.Println("✅ Data export worker started")
+}
+
+// releaseStaleDataExports requeues exports whose worker never finished them
+func releaseStaleDataExports() {
+	collection := configs.GetCollection(configs.DB, "data_exports")
+	_, err := collection.UpdateMany(context.TODO(),
+		bson.M{
+			"status":    models.DataExportProcessing,
+			"locked_at": bson.M{"$lt": time.Now().Add(-dataExportLockTimeout)},
+		},
+		bson.M{"$set": bson.M{"status": models.DataExportPending}},
+	)
+	if err != nil {
+		log.Printf("Error: Failed to release stale data exports - %v", err)
+	}
+}
+
+// deleteExpiredDataExports removes expired exports together with their zip files
+func deleteExpiredDataExports() {
+	collection := configs.GetCollection(configs.DB, "data_exports")
+	cursor, err := collection.Find(context.TODO(), bson.M{"expiresAt": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		log.Printf("Error: Failed to query expired data exports - %v", err)
+		return
+	}
+	var expired []models.DataExport
+	if err := cursor.All(context.TODO(), &expired); err != nil {
+		log.Printf("Error: Failed to decode expired data exports - %v", err)
+		return
+	}
+
+	bucket := configs.GetGridFSBucket(configs.DB)
+	for _, export := range expired {
+		if !export.FileID.IsZero() {
+			if err := bucket.Delete(export.FileID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+				log.Printf("Error deleting data export file %s: %v", export.FileID.Hex(), err)
+				continue
+			}
+		}
+		collection.DeleteOne(context.TODO(), bson.M{"_id": export.ID})
+	}
+}
+
+// processNextDataExport claims and builds one queued export. It reports
+// whether an export was found so the caller can keep draining the queue.
+func processNextDataExport() bool {
+	collection := configs.GetCollection(configs.DB, "data_exports")
+	now := time.Now()
+
+	var export models.DataExport
+	err := collection.FindOneAndUpdate(context.TODO(),
+		bson.M{"status": models.DataExportPending},
+		bson.M{"$set": bson.M{"status": models.DataExportProcessing, "locked_at": now}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&export)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Error: Failed to claim data export - %v", err)
+		}
+		return false
+	}
+
+	user, fileID, size, buildErr := buildDataExport(export)
+	set := bson.M{"completedAt": time.Now()}
+	if buildErr != nil {
+		log.Printf("Error: Failed to build data export %s - %v", export.ID.Hex(), buildErr)
+		set["status"] = models.DataExportFailed
+		set["error"] = "Failed to build export"
+		// Failed jobs are cleaned up like finished ones
+		set["expiresAt"] = time.Now().Add(DataExportTTL)
+	} else {
+		set["status"] = models.DataExportReady
+		set["file_id"] = fileID
+		set["size"] = size
+		set["expiresAt"] = time.Now().Add(DataExportTTL)
+	}
+	if _, err := collection.UpdateOne(context.TODO(), bson.M{"_id": export.ID}, bson.M{"$set": set}); err != nil {
+		log.Printf("Error: Failed to update data export %s - %v", export.ID.Hex(), err)
+		return true
+	}
+
+	if buildErr == nil {
+		if err := SendDataExportReadyEmail(user.Email, user.Locale, DataExportDownloadURL(export.ID), DataExportTTL); err != nil {
+			log.Println("Error sending data export email:", err)
+		}
+	}
+	return true
+}
+
+// buildDataExport writes the user's profile, linked identities, prediction
+// history and stored images into a zip file in GridFS
+func buildDataExport(export models.DataExport) (*models.User, primitive.ObjectID, int64, error) {
+	ctx := context.TODO()
+	userID, err := primitive.ObjectIDFromHex(export.UserID)
+	if err != nil {
+		return nil, primitive.NilObjectID, 0, err
+	}
+	var user models.User
+	if err := configs.GetCollection(configs.DB, "users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return nil, primitive.NilObjectID, 0, err
+	}
+
+	identities, err := UserIdentities(export.UserID)
+	if err != nil {
+		return nil, primitive.NilObjectID, 0, err
+	}
+
+	cursor, err := configs.GetCollection(configs.DB, "prediction_history").Find(ctx,
+		bson.M{"user_id": export.UserID},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+	if err != nil {
+		return nil, primitive.NilObjectID, 0, err
+	}
+	history := []models.PredictionHistory{}
+	if err := cursor.All(ctx, &history); err != nil {
+		return nil, primitive.NilObjectID, 0, err
+	}
+
+	// Prediction images store the owner as a hex string, avatars as an ObjectID
+	cursor, err = configs.GetCollection(configs.DB, "fs.files").Find(ctx, bson.M{"$or": []bson.M{
+		{"metadata.user_id": export.UserID, "metadata.type": "prediction_image"},
+		{"metadata.user_id": userID, "metadata.type": "avatar"},
+	}})
+	if err != nil {
+		return nil, primitive.NilObjectID, 0, err
+	}
+	var files []struct {
+		ID       primitive.ObjectID `bson:"_id"`
+		Filename string             `bson:"filename"`
+		Metadata struct {
+			Type string `bson:"type"`
+		} `bson:"metadata"`
+	}
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, primitive.NilObjectID, 0, err
+	}
+
+	profile := map[string]interface{}{
+		"id":               user.Id.Hex(),
+		"username":         user.Username,
+		"email":            user.Email,
+		"emailVerified":    user.EmailVerified,
+		"createdAt":        user.CreatedAt,
+		"role":             user.Role,
+		"twoFactorEnabled": user.TwoFactorEnabled,
+		"identities":       identities,
+		"exportedAt":       time.Now(),
+	}
+
+	bucket := configs.GetGridFSBucket(configs.DB)
+	filename := fmt.Sprintf("kale-export-%s-%s.zip", user.Username, time.Now().Format("20060102"))
+	upload, err := bucket.OpenUploadStream(filename, options.GridFSUpload().SetMetadata(bson.M{
+		"user_id": export.UserID,
+		"type":    "data_export",
+	}))
+	if err != nil {
+		return nil, primitive.NilObjectID, 0, err
+	}
+
+	archive := zip.NewWriter(upload)
+	err = writeExportJSON(archive, "profile.json", profile)
+	if err == nil {
+		err = writeExportJSON(archive, "history.json", history)
+	}
+	for _, f := range files {
+		if err != nil {
+			break
+		}
+		name := "images/" + f.ID.Hex() + filepath.Ext(f.Filename)
+		if f.Metadata.Type == "avatar" {
+			name = "avatar" + filepath.Ext(f.Filename)
+		}
+		err = copyExportFile(archive, bucket, f.ID, name)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		upload.Abort()
+		return nil, primitive.NilObjectID, 0, err
+	}
+	if err := upload.Close(); err != nil {
+		return nil, primitive.NilObjectID, 0, err
+	}
+
+	fileID := upload.FileID.(primitive.ObjectID)
+	var stored struct {
+		Length int64 `bson:"length"`
+	}
+	configs.GetCollection(configs.DB, "fs.files").FindOne(ctx, bson.M{"_id": fileID}).Decode(&stored)
+	return &user, fileID, stored.Length, nil
+}
+
+func writeExportJSON(archive *zip.Writer, name string, v interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func copyExportFile(archive *zip.Writer, bucket *gridfs.Bucket, fileID primitive.ObjectID, name string) error {
+	download, err := bucket.OpenDownloadStream(fileID)
+	if err != nil {
+		// A missing blob should not fail the whole export
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			return nil
+		}
+		return err
+	}
+	defer download.Close()
+
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, download)
+	return err
+}
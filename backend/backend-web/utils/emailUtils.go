@@ -1,51 +1,96 @@
 package utils
 
 import (
-	"fmt"
-	"strconv"
-	"math/rand"
+	"time"
 
 	"backend-web/configs"
-	"github.com/sendgrid/sendgrid-go"
-	"github.com/sendgrid/sendgrid-go/helpers/mail"
 )
 
-func SendEmailVerification(email, verificationCode string, isReset bool) error {
-
-	senderEmail := configs.EnvSendgridEmail()
-	if senderEmail == "" {
-		return fmt.Errorf("sender email not configured")
-	}
-
-	apiKey := configs.EnvSendgridAPIKey()
-	if apiKey == "" {
-		return fmt.Errorf("SendGrid API key not configured")
-	}
-
-	from := mail.NewEmail("Kale Project", senderEmail)
-	subject := "Please verify your email address"
-	if isReset {
-		subject = "Password Reset OTP"
-	}
-	to := mail.NewEmail("", email)
-	plainTextContent := "Your verification code is: " + verificationCode
-	htmlContent := "<strong>Your verification code is: " + verificationCode + "</strong>"
-	if isReset {
-		plainTextContent = "Your password reset OTP is: " + verificationCode
-		htmlContent = "<strong>Your password reset OTP is: " + verificationCode + "</strong>"
-	}
-	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
-
-	client := sendgrid.NewSendClient(apiKey)
-	response, err := client.Send(message)
-	if err != nil {
-		return err
-	}
-
-	fmt.Println("SendGrid Response:", response.StatusCode)
-	return nil
-}
-
-func GenerateVerificationCode() string {
-	return strconv.Itoa(100000 + rand.Intn(900000))
+// Every Send*Email function renders its template from templates/email in the
+// recipient's locale (see ResolveLocale) and queues it in the email outbox;
+// pass the user's saved locale or "" for the default.
+
+// SendVerificationEmail emails the code that verifies an email address
+func SendVerificationEmail(email, locale, verificationCode string) error {
+	return sendTemplateEmail(email, locale, EmailTemplateVerifyEmail, map[string]interface{}{"Code": verificationCode})
+}
+
+// SendPasswordResetOTPEmail emails the code that allows a password reset
+func SendPasswordResetOTPEmail(email, locale, otp string) error {
+	return sendTemplateEmail(email, locale, EmailTemplateResetOTP, map[string]interface{}{"Code": otp})
+}
+
+// SendOrganizationInvitation emails an invitation link to join an organization
+func SendOrganizationInvitation(email, locale, orgName, inviteLink string) error {
+	return sendTemplateEmail(email, locale, EmailTemplateInvitation, map[string]interface{}{
+		"OrgName": orgName,
+		"Link":    inviteLink,
+	})
+}
+
+// SendAlertNotificationEmail emails an alert raised for the user's data
+func SendAlertNotificationEmail(email, locale, title, message, link string) error {
+	return sendTemplateEmail(email, locale, EmailTemplateAlertNotification, map[string]interface{}{
+		"Title":   title,
+		"Message": message,
+		"Link":    link,
+	})
+}
+
+// SendAccountLockedEmail tells the owner that repeated failed attempts locked their account
+func SendAccountLockedEmail(email, locale string, lockedFor time.Duration) error {
+	return sendTemplateEmail(email, locale, EmailTemplateAccountLocked, map[string]interface{}{"Minutes": int(lockedFor.Minutes())})
+}
+
+// SendPasswordChangedEmail tells the owner that their password was changed
+func SendPasswordChangedEmail(email, locale string) error {
+	return sendTemplateEmail(email, locale, EmailTemplatePasswordChanged, nil)
+}
+
+// SendEmailChangedEmail warns the old address that the account email was changed
+func SendEmailChangedEmail(oldEmail, locale, newEmail string) error {
+	return sendTemplateEmail(oldEmail, locale, EmailTemplateEmailChanged, map[string]interface{}{"NewEmail": newEmail})
+}
+
+// SendMagicLinkEmail emails a single-use login link
+func SendMagicLinkEmail(email, locale, link string, validFor time.Duration) error {
+	return sendTemplateEmail(email, locale, EmailTemplateMagicLink, map[string]interface{}{
+		"Link":    link,
+		"Minutes": int(validFor.Minutes()),
+	})
+}
+
+// SendAccountExistsEmail is sent instead of an error when someone registers
+// with, or tries to change their email to, an address that already has an
+// account
+func SendAccountExistsEmail(email, locale string) error {
+	return sendTemplateEmail(email, locale, EmailTemplateAccountExists, map[string]interface{}{
+		"LoginURL": configs.EnvFrontendURL() + "/auth/login",
+		"ResetURL": configs.EnvFrontendURL() + "/auth/forgot-password",
+	})
+}
+
+// SendDataExportReadyEmail tells the user their data export can be downloaded
+func SendDataExportReadyEmail(email, locale, link string, validFor time.Duration) error {
+	return sendTemplateEmail(email, locale, EmailTemplateDataExportReady, map[string]interface{}{
+		"Link": link,
+		"Days": int(validFor.Hours() / 24),
+	})
+}
+
+// SendAccountDeletionCodeEmail emails the OTP that confirms an account deletion
+func SendAccountDeletionCodeEmail(email, locale, code string) error {
+	return sendTemplateEmail(email, locale, EmailTemplateAccountDeletionCode, map[string]interface{}{"Code": code})
+}
+
+// SendAccountDeletionScheduledEmail confirms a deletion request and says how to undo it
+func SendAccountDeletionScheduledEmail(email, locale string, deleteAt time.Time) error {
+	return sendTemplateEmail(email, locale, EmailTemplateAccountDeletionScheduled, map[string]interface{}{
+		"Date": formatEmailDate(deleteAt, locale),
+	})
+}
+
+// SendAccountDeletedEmail confirms that an account was permanently deleted
+func SendAccountDeletedEmail(email, locale string) error {
+	return sendTemplateEmail(email, locale, EmailTemplateAccountDeleted, nil)
 }
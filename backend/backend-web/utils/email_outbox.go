@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	emailBaseBackoff  = 30 * time.Second
+	emailMaxBackoff   = time.Hour
+	emailPollInterval = 5 * time.Second
+	emailLockTimeout  = 2 * time.Minute
+	// Queued emails carry one-time codes that expire long before this, so an
+	// email still undelivered after a day is dropped
+	emailPendingTTL = 24 * time.Hour
+	// Sent and failed emails are kept this long for troubleshooting
+	emailOutboxRetention = 30 * 24 * time.Hour
+)
+
+var ErrOutboxEmailNotFound = errors.New("email not found")
+
+// emailWake lets QueueEmail start a delivery right away instead of waiting
+// for the next poll
+var emailWake = make(chan struct{}, 1)
+
+// QueueEmail stores a rendered email in the outbox. Delivery happens in the
+// background with retries, so an error here only means the email could not
+// be stored.
+func QueueEmail(to, template string, rendered *RenderedEmail) error {
+	now := time.Now()
+	email := models.OutboxEmail{
+		ID:            primitive.NewObjectID(),
+		To:            to,
+		Template:      template,
+		Subject:       rendered.Subject,
+		Text:          rendered.Text,
+		HTML:          rendered.HTML,
+		Status:        models.EmailStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(emailPendingTTL),
+	}
+	collection := configs.GetCollection(configs.DB, "email_outbox")
+	if _, err := collection.InsertOne(context.TODO(), email); err != nil {
+		return err
+	}
+
+	select {
+	case emailWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// StartEmailWorker delivers queued emails with the transport chosen by
+// MAIL_TRANSPORT. If the transport is missing or misconfigured the worker does
+// not start, and queued emails expire undelivered unless the server is
+// restarted with a working one.
+func StartEmailWorker() {
+	mailer, err := NewMailer()
+	if err != nil {
+		log.Println("🚨 EMAIL DELIVERY IS DISABLED: no verification, reset or notification email will be sent:", err)
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(emailPollInterval)
+		defer ticker.Stop()
+		for {
+			releaseStaleEmailLocks()
+			for processNextEmail(mailer) {
+			}
+			select {
+			case <-ticker.C:
+			case <-emailWake:
+			}
+		}
+	}()
+	log.Println("✅ Email outbox worker started using", mailer.Name())
+}
+
+// releaseStaleEmailLocks puts back emails claimed by a worker that never
+// finished them (e.g. the process was restarted mid-send)
+func releaseStaleEmailLocks() {
+	collection := configs.GetCollection(configs.DB, "email_outbox")
+	_, err := collection.UpdateMany(context.TODO(),
+		bson.M{
+			"status":    models.EmailStatusSending,
+			"locked_at": bson.M{"$lt": time.Now().Add(-emailLockTimeout)},
+		},
+		bson.M{"$set": bson.M{"status": models.EmailStatusPending}},
+	)
+	if err != nil {
+		log.Printf("Error: Failed to release stale emails - %v", err)
+	}
+}
+
+// processNextEmail claims and sends one due email. It reports whether an
+// email was found so the caller can keep draining the queue.
+func processNextEmail(mailer Mailer) bool {
+	collection := configs.GetCollection(configs.DB, "email_outbox")
+	now := time.Now()
+
+	var email models.OutboxEmail
+	err := collection.FindOneAndUpdate(context.TODO(),
+		bson.M{
+			"status":          models.EmailStatusPending,
+			"next_attempt_at": bson.M{"$lte": now},
+		},
+		bson.M{"$set": bson.M{"status": models.EmailStatusSending, "locked_at": now}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&email)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Error: Failed to claim email - %v", err)
+		}
+		return false
+	}
+
+	sendErr := mailer.Send(MailMessage{
+		ID:      email.ID.Hex(),
+		To:      email.To,
+		Subject: email.Subject,
+		Text:    email.Text,
+		HTML:    email.HTML,
+	})
+	finishEmail(email, mailer.Name(), sendErr)
+	return true
+}
+
+func finishEmail(email models.OutboxEmail, transport string, sendErr error) {
+	collection := configs.GetCollection(configs.DB, "email_outbox")
+	attempts := email.Attempts + 1
+	now := time.Now()
+
+	set := bson.M{
+		"attempts":  attempts,
+		"transport": transport,
+	}
+	unset := bson.M{"locked_at": ""}
+	// Once an email is finished its bodies, which may contain one-time codes,
+	// are not needed anymore
+	finished := bson.M{"locked_at": "", "text": "", "html": ""}
+	if sendErr == nil {
+		set["status"] = models.EmailStatusSent
+		set["sentAt"] = now
+		set["expiresAt"] = now.Add(emailOutboxRetention)
+		set["last_error"] = ""
+		unset = finished
+	} else {
+		set["last_error"] = sendErr.Error()
+		if errors.Is(sendErr, ErrMailRejected) || attempts >= configs.EnvMailMaxAttempts() {
+			set["status"] = models.EmailStatusFailed
+			set["expiresAt"] = now.Add(emailOutboxRetention)
+			unset = finished
+		} else {
+			set["status"] = models.EmailStatusPending
+			set["next_attempt_at"] = now.Add(emailBackoff(attempts))
+		}
+		log.Printf("Email %s attempt %d failed: %v", email.ID.Hex(), attempts, sendErr)
+	}
+
+	_, err := collection.UpdateOne(context.TODO(),
+		bson.M{"_id": email.ID},
+		bson.M{"$set": set, "$unset": unset},
+	)
+	if err != nil {
+		log.Printf("Error: Failed to update email %s - %v", email.ID.Hex(), err)
+	}
+}
+
+// emailBackoff returns the delay before the next attempt: 30s, 1m, 2m, 4m ... capped at 1h
+func emailBackoff(attempts int) time.Duration {
+	delay := emailBaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= emailMaxBackoff {
+			return emailMaxBackoff
+		}
+	}
+	return delay
+}
+
+// QueryOutboxEmails returns a page of outbox emails matching the filter, newest first
+func QueryOutboxEmails(filter bson.M, page, limit int) ([]models.OutboxEmail, int64, error) {
+	collection := configs.GetCollection(configs.DB, "email_outbox")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	emails := []models.OutboxEmail{}
+	if err := cursor.All(ctx, &emails); err != nil {
+		return nil, 0, err
+	}
+	return emails, total, nil
+}
+
+// RetryOutboxEmail skips the backoff of a pending email so it is attempted
+// right away. Failed emails cannot be retried because their bodies are
+// dropped; the user requests a new email instead.
+func RetryOutboxEmail(id primitive.ObjectID) error {
+	collection := configs.GetCollection(configs.DB, "email_outbox")
+	res, err := collection.UpdateOne(context.TODO(),
+		bson.M{"_id": id, "status": models.EmailStatusPending},
+		bson.M{"$set": bson.M{"next_attempt_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrOutboxEmailNotFound
+	}
+
+	select {
+	case emailWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
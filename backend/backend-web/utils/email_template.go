@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"backend-web/configs"
+)
+
+// Email templates live in templates/email/<locale>/<name>.tmpl. Each file
+// defines "subject", "text" and "html"; layout.tmpl in the same directory
+// wraps the bodies in "text_layout" and "html_layout" with the shared header
+// and footer.
+//
+//go:embed templates/email
+var emailTemplateFS embed.FS
+
+// Names of the transactional email templates
+const (
+	EmailTemplateVerifyEmail              = "verify_email"
+	EmailTemplateResetOTP                 = "reset_otp"
+	EmailTemplatePasswordChanged          = "password_changed"
+	EmailTemplateAlertNotification        = "alert_notification"
+	EmailTemplateInvitation               = "invitation"
+	EmailTemplateAccountLocked            = "account_locked"
+	EmailTemplateEmailChanged             = "email_changed"
+	EmailTemplateMagicLink                = "magic_link"
+	EmailTemplateAccountExists            = "account_exists"
+	EmailTemplateDataExportReady          = "data_export_ready"
+	EmailTemplateAccountDeletionCode      = "account_deletion_code"
+	EmailTemplateAccountDeletionScheduled = "account_deletion_scheduled"
+	EmailTemplateAccountDeleted           = "account_deleted"
+)
+
+// Supported email locales
+const (
+	LocaleEnglish = "en"
+	LocaleThai    = "th"
+)
+
+var ErrUnknownEmailTemplate = errors.New("unknown email template")
+
+// EmailBranding holds the branding variables every template can use as .Brand
+type EmailBranding struct {
+	Name         string
+	LogoURL      string
+	Color        string
+	SupportEmail string
+	FrontendURL  string
+}
+
+// RenderedEmail is a template executed for one recipient
+type RenderedEmail struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var (
+	emailTemplatesOnce sync.Once
+	emailTemplates     map[string]map[string]*emailTemplate
+	emailTemplatesErr  error
+)
+
+// loadEmailTemplates parses every embedded template once
+func loadEmailTemplates() (map[string]map[string]*emailTemplate, error) {
+	emailTemplatesOnce.Do(func() {
+		emailTemplates = map[string]map[string]*emailTemplate{}
+		locales, err := emailTemplateFS.ReadDir("templates/email")
+		if err != nil {
+			emailTemplatesErr = err
+			return
+		}
+		for _, locale := range locales {
+			if !locale.IsDir() {
+				continue
+			}
+			dir := "templates/email/" + locale.Name()
+			files, err := emailTemplateFS.ReadDir(dir)
+			if err != nil {
+				emailTemplatesErr = err
+				return
+			}
+			emailTemplates[locale.Name()] = map[string]*emailTemplate{}
+			for _, file := range files {
+				name := strings.TrimSuffix(file.Name(), ".tmpl")
+				if name == "layout" || name == file.Name() {
+					continue
+				}
+				paths := []string{dir + "/layout.tmpl", dir + "/" + file.Name()}
+				text, err := texttemplate.ParseFS(emailTemplateFS, paths...)
+				if err != nil {
+					emailTemplatesErr = fmt.Errorf("parse %s/%s: %w", locale.Name(), name, err)
+					return
+				}
+				html, err := htmltemplate.ParseFS(emailTemplateFS, paths...)
+				if err != nil {
+					emailTemplatesErr = fmt.Errorf("parse %s/%s: %w", locale.Name(), name, err)
+					return
+				}
+				emailTemplates[locale.Name()][name] = &emailTemplate{text: text, html: html}
+			}
+		}
+	})
+	return emailTemplates, emailTemplatesErr
+}
+
+// EmailTemplateNames lists the available templates in the default locale
+func EmailTemplateNames() ([]string, error) {
+	templates, err := loadEmailTemplates()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range templates[LocaleEnglish] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// NormalizeLocale maps a user setting or an Accept-Language header to a
+// supported locale, or "" if none matches
+func NormalizeLocale(value string) string {
+	for _, part := range strings.Split(value, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		switch lang {
+		case LocaleEnglish, LocaleThai:
+			return lang
+		}
+	}
+	return ""
+}
+
+// ResolveLocale picks the first supported locale among the candidates, in
+// order, and falls back to EMAIL_DEFAULT_LOCALE
+func ResolveLocale(candidates ...string) string {
+	for _, candidate := range candidates {
+		if locale := NormalizeLocale(candidate); locale != "" {
+			return locale
+		}
+	}
+	if locale := NormalizeLocale(configs.EnvEmailDefaultLocale()); locale != "" {
+		return locale
+	}
+	return LocaleEnglish
+}
+
+// CurrentEmailBranding reads the branding variables from the environment
+func CurrentEmailBranding() EmailBranding {
+	return EmailBranding{
+		Name:         configs.EnvEmailBrandName(),
+		LogoURL:      configs.EnvEmailBrandLogoURL(),
+		Color:        configs.EnvEmailBrandColor(),
+		SupportEmail: configs.EnvEmailSupportAddress(),
+		FrontendURL:  configs.EnvFrontendURL(),
+	}
+}
+
+// RenderEmail executes a named template in the given locale. Unknown locales
+// use the default one. data is available to the template as top-level keys
+// next to .Brand and .Locale.
+func RenderEmail(name, locale string, data map[string]interface{}) (*RenderedEmail, error) {
+	templates, err := loadEmailTemplates()
+	if err != nil {
+		return nil, err
+	}
+	locale = ResolveLocale(locale)
+	tmpl, ok := templates[locale][name]
+	if !ok {
+		if tmpl, ok = templates[LocaleEnglish][name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownEmailTemplate, name)
+		}
+	}
+
+	values := map[string]interface{}{}
+	for k, v := range data {
+		values[k] = v
+	}
+	values["Brand"] = CurrentEmailBranding()
+	values["Locale"] = locale
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return nil, err
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text_layout", values); err != nil {
+		return nil, err
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "html_layout", values); err != nil {
+		return nil, err
+	}
+	return &RenderedEmail{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// sendTemplateEmail renders a template for the recipient's locale and queues it
+func sendTemplateEmail(email, locale, name string, data map[string]interface{}) error {
+	rendered, err := RenderEmail(name, locale, data)
+	if err != nil {
+		return err
+	}
+	return QueueEmail(email, name, rendered)
+}
+
+var thaiMonths = [...]string{
+	"มกราคม", "กุมภาพันธ์", "มีนาคม", "เมษายน", "พฤษภาคม", "มิถุนายน",
+	"กรกฎาคม", "สิงหาคม", "กันยายน", "ตุลาคม", "พฤศจิกายน", "ธันวาคม",
+}
+
+// formatEmailDate writes a date the way the locale reads it; Thai dates use
+// the Buddhist calendar year
+func formatEmailDate(t time.Time, locale string) string {
+	if ResolveLocale(locale) == LocaleThai {
+		return fmt.Sprintf("%d %s %d", t.Day(), thaiMonths[t.Month()-1], t.Year()+543)
+	}
+	return t.Format("2 January 2006")
+}
+
+// EmailPreviewData is sample data for previewing each template
+func EmailPreviewData(name, locale string) map[string]interface{} {
+	frontend := configs.EnvFrontendURL()
+	switch name {
+	case EmailTemplateVerifyEmail, EmailTemplateResetOTP, EmailTemplateAccountDeletionCode:
+		return map[string]interface{}{"Code": "123456"}
+	case EmailTemplateAlertNotification:
+		return map[string]interface{}{
+			"Title":   "Weight loss above threshold",
+			"Message": "A new prediction reported 12.5% weight loss, above your alert threshold of 10%.",
+			"Link":    frontend + "/history",
+		}
+	case EmailTemplateInvitation:
+		return map[string]interface{}{"OrgName": "Kale Farm Co.", "Link": frontend + "/orgs/invitations/accept?token=preview"}
+	case EmailTemplateAccountLocked:
+		return map[string]interface{}{"Minutes": 15}
+	case EmailTemplateEmailChanged:
+		return map[string]interface{}{"NewEmail": "new.address@example.com"}
+	case EmailTemplateMagicLink:
+		return map[string]interface{}{"Link": frontend + "/auth/magic-link?token=preview", "Minutes": 15}
+	case EmailTemplateAccountExists:
+		return map[string]interface{}{"LoginURL": frontend + "/auth/login", "ResetURL": frontend + "/auth/forgot-password"}
+	case EmailTemplateDataExportReady:
+		return map[string]interface{}{"Link": frontend + "/account/exports/download?id=preview", "Days": 7}
+	case EmailTemplateAccountDeletionScheduled:
+		return map[string]interface{}{"Date": formatEmailDate(time.Now().Add(14*24*time.Hour), locale)}
+	}
+	return map[string]interface{}{}
+}
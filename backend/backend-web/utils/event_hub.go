@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types pushed to connected clients over the event stream
+const (
+	StreamEventPredictionProgress = "prediction.progress"
+	StreamEventHistoryCreated     = "history.created"
+	StreamEventHistoryDeleted     = "history.deleted"
+	StreamEventProfileUpdated     = "profile.updated"
+	StreamEventAvatarUpdated      = "avatar.updated"
+)
+
+const (
+	eventReplayBufferSize = 100
+	eventReplayMaxAge     = 10 * time.Minute
+	eventSubscriberBuffer = 32
+)
+
+type StreamEvent struct {
+	ID        int64       `json:"id"`
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// eventHub fans events out to every open stream of a user and keeps a short
+// per-user history so reconnecting clients can catch up via Last-Event-ID
+type eventHub struct {
+	mu          sync.Mutex
+	lastID      int64
+	lastSweep   time.Time
+	buffers     map[string][]StreamEvent
+	subscribers map[string]map[chan StreamEvent]struct{}
+}
+
+var hub = &eventHub{
+	// Seed IDs from the clock so they keep increasing across restarts
+	lastID:      time.Now().UnixMilli() * 1000,
+	lastSweep:   time.Now(),
+	buffers:     make(map[string][]StreamEvent),
+	subscribers: make(map[string]map[chan StreamEvent]struct{}),
+}
+
+// PublishEvent sends an event to all of the user's connected streams
+func PublishEvent(userID, eventType string, data interface{}) {
+	if userID == "" {
+		return
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.lastID++
+	event := StreamEvent{
+		ID:        hub.lastID,
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+
+	buffer := append(hub.buffers[userID], event)
+	if len(buffer) > eventReplayBufferSize {
+		buffer = buffer[len(buffer)-eventReplayBufferSize:]
+	}
+	cutoff := event.CreatedAt.Add(-eventReplayMaxAge)
+	for len(buffer) > 0 && buffer[0].CreatedAt.Before(cutoff) {
+		buffer = buffer[1:]
+	}
+	hub.buffers[userID] = buffer
+	hub.sweep(event.CreatedAt)
+
+	for ch := range hub.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+			// Slow consumer; it can catch up with Last-Event-ID after reconnecting
+		}
+	}
+}
+
+// SubscribeEvents registers a stream for the user. Events newer than
+// lastEventID that are still buffered are returned for replay. The returned
+// function must be called when the stream is closed.
+func SubscribeEvents(userID string, lastEventID int64) ([]StreamEvent, <-chan StreamEvent, func()) {
+	ch := make(chan StreamEvent, eventSubscriberBuffer)
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.subscribers[userID] == nil {
+		hub.subscribers[userID] = make(map[chan StreamEvent]struct{})
+	}
+	hub.subscribers[userID][ch] = struct{}{}
+
+	var replay []StreamEvent
+	if lastEventID > 0 {
+		cutoff := time.Now().Add(-eventReplayMaxAge)
+		for _, event := range hub.buffers[userID] {
+			if event.ID > lastEventID && event.CreatedAt.After(cutoff) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	unsubscribe := func() {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		delete(hub.subscribers[userID], ch)
+		if len(hub.subscribers[userID]) == 0 {
+			delete(hub.subscribers, userID)
+			hub.evict(userID, time.Now())
+		}
+	}
+
+	return replay, ch, unsubscribe
+}
+
+// evict drops the user's buffer once nobody is subscribed and even its newest
+// event is too old to be replayed. Callers must hold hub.mu.
+func (h *eventHub) evict(userID string, now time.Time) {
+	if len(h.subscribers[userID]) > 0 {
+		return
+	}
+	buffer := h.buffers[userID]
+	if len(buffer) == 0 || buffer[len(buffer)-1].CreatedAt.Before(now.Add(-eventReplayMaxAge)) {
+		delete(h.buffers, userID)
+	}
+}
+
+// sweep evicts stale buffers at most once per replay window, which catches
+// users whose streams closed while their events were still replayable and
+// users who never connected. Callers must hold hub.mu.
+func (h *eventHub) sweep(now time.Time) {
+	if now.Sub(h.lastSweep) < eventReplayMaxAge {
+		return
+	}
+	h.lastSweep = now
+	for userID := range h.buffers {
+		h.evict(userID, now)
+	}
+}
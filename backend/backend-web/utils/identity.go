@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IdentityLinkTTL is how long the user has to confirm linking a login to an
+// existing account
+const IdentityLinkTTL = 10 * time.Minute
+
+const maxUsernameLength = 24
+
+var (
+	ErrIdentityLinked      = errors.New("identity is linked to another account")
+	ErrIdentityNotFound    = errors.New("identity not found")
+	ErrInvalidLinkRequest  = errors.New("invalid or expired link request")
+	ErrLastLoginMethod     = errors.New("cannot remove the last login method")
+	ErrUsernameUnavailable = errors.New("could not find a free username")
+)
+
+// FindIdentity returns the identity for a provider subject, or nil if none,
+// and records that it was used
+func FindIdentity(provider, subject string) (*models.UserIdentity, error) {
+	collection := configs.GetCollection(configs.DB, "user_identities")
+
+	var identity models.UserIdentity
+	err := collection.FindOneAndUpdate(context.TODO(),
+		bson.M{"provider": provider, "subject": subject},
+		bson.M{"$set": bson.M{"lastUsedAt": time.Now()}},
+	).Decode(&identity)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// UserIdentities lists the logins linked to a user
+func UserIdentities(userID string) ([]models.UserIdentity, error) {
+	collection := configs.GetCollection(configs.DB, "user_identities")
+	cursor, err := collection.Find(context.TODO(), bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	identities := []models.UserIdentity{}
+	if err := cursor.All(context.TODO(), &identities); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// LinkIdentity links the provider profile to the user. It returns
+// ErrIdentityLinked when the subject already belongs to another user, and
+// replaces a different account from the same provider.
+func LinkIdentity(userID string, profile *OAuthProfile) error {
+	collection := configs.GetCollection(configs.DB, "user_identities")
+
+	existing, err := FindIdentity(profile.Provider, profile.Subject)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if existing.UserID != userID {
+			return ErrIdentityLinked
+		}
+		return nil
+	}
+
+	if _, err := collection.DeleteMany(context.TODO(), bson.M{"user_id": userID, "provider": profile.Provider}); err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = collection.InsertOne(context.TODO(), models.UserIdentity{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		Provider:   profile.Provider,
+		Subject:    profile.Subject,
+		Email:      profile.Email,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrIdentityLinked
+	}
+	return err
+}
+
+// UnlinkIdentity removes the user's login for a provider. It refuses to
+// remove the only way left to sign in.
+func UnlinkIdentity(user *models.User, provider string) error {
+	collection := configs.GetCollection(configs.DB, "user_identities")
+	userID := user.Id.Hex()
+
+	if user.Password == "" {
+		count, err := collection.CountDocuments(context.TODO(), bson.M{"user_id": userID})
+		if err != nil {
+			return err
+		}
+		if count <= 1 {
+			return ErrLastLoginMethod
+		}
+	}
+
+	result, err := collection.DeleteOne(context.TODO(), bson.M{"user_id": userID, "provider": provider})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
+
+// CreateIdentityLinkRequest parks an OAuth login that matched an existing
+// account by email and returns the raw token used to confirm it
+func CreateIdentityLinkRequest(userID string, profile *OAuthProfile, returnTo string) (string, error) {
+	raw, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	collection := configs.GetCollection(configs.DB, "identity_link_requests")
+	_, err = collection.InsertOne(context.TODO(), models.IdentityLinkRequest{
+		ID:        primitive.NewObjectID(),
+		TokenHash: HashToken(raw),
+		UserID:    userID,
+		Provider:  profile.Provider,
+		Subject:   profile.Subject,
+		Email:     profile.Email,
+		ReturnTo:  returnTo,
+		CreatedAt: now,
+		ExpiresAt: now.Add(IdentityLinkTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// GetIdentityLinkRequest loads an unexpired link request without using it up
+func GetIdentityLinkRequest(raw string) (*models.IdentityLinkRequest, error) {
+	collection := configs.GetCollection(configs.DB, "identity_link_requests")
+
+	var request models.IdentityLinkRequest
+	err := collection.FindOne(context.TODO(), bson.M{
+		"token_hash": HashToken(raw),
+		"expiresAt":  bson.M{"$gt": time.Now()},
+	}).Decode(&request)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidLinkRequest
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// CompleteIdentityLinkRequest deletes the request, failing if another
+// request already used it
+func CompleteIdentityLinkRequest(requestID primitive.ObjectID) error {
+	collection := configs.GetCollection(configs.DB, "identity_link_requests")
+	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": requestID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrInvalidLinkRequest
+	}
+	return nil
+}
+
+// sanitizeUsername turns a display name or login into a username base:
+// letters, digits, dots, dashes and underscores, with spaces as underscores
+func sanitizeUsername(value string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(value) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('_')
+		}
+	}
+	name := []rune(strings.Trim(b.String(), "._-"))
+	if len(name) > maxUsernameLength-5 {
+		name = name[:maxUsernameLength-5]
+	}
+	return string(name)
+}
+
+// GenerateUniqueUsername picks a free username from the candidates in order,
+// falling back to adding a random number to the first usable one
+func GenerateUniqueUsername(candidates ...string) (string, error) {
+	collection := configs.GetCollection(configs.DB, "users")
+	taken := func(name string) (bool, error) {
+		count, err := collection.CountDocuments(context.TODO(), bson.M{"username": name})
+		return count > 0, err
+	}
+
+	base := ""
+	for _, candidate := range candidates {
+		name := sanitizeUsername(candidate)
+		if len([]rune(name)) < 3 {
+			continue
+		}
+		if base == "" {
+			base = name
+		}
+		exists, err := taken(name)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return name, nil
+		}
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	for i := 0; i < 10; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		name := fmt.Sprintf("%s%04d", base, n.Int64())
+		exists, err := taken(name)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return name, nil
+		}
+	}
+	return "", ErrUsernameUnavailable
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const impersonationTokenTTL = time.Hour
+
+// GenerateJWT signs a short-lived access token carrying the user's identity,
+// role, permissions and login session
+func GenerateJWT(user *models.User, sessionID string) (string, error) {
+	return signUserToken(user, configs.EnvAccessTokenTTL(), sessionID, "")
+}
+
+// GenerateImpersonationJWT signs a short-lived token that lets an admin act as
+// the user within a session from CreateImpersonationSession. The admin's ID is
+// kept in the impersonator_id claim and the token only carries the read-mostly
+// models.ImpersonationPermissions.
+func GenerateImpersonationJWT(user *models.User, adminID, sessionID string) (string, error) {
+	return signUserToken(user, impersonationTokenTTL, sessionID, adminID)
+}
+
+func signUserToken(user *models.User, ttl time.Duration, sessionID, impersonatorID string) (string, error) {
+	jti, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+
+	permissions := models.ResolvePermissions(role, user.Permissions)
+	if impersonatorID != "" {
+		permissions = models.ImpersonationPermissions(permissions)
+	}
+
+	now := time.Now()
+	claims := models.Claims{
+		Email:          user.Email,
+		Username:       user.Username,
+		UserID:         user.Id.Hex(),
+		Role:           role,
+		Permissions:    permissions,
+		SessionID:      sessionID,
+		ImpersonatorID: impersonatorID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	if configs.EnvJWTAlgorithm() == jwt.SigningMethodHS256.Alg() {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(configs.EnvSecret()))
+	}
+
+	key, err := currentSigningKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.alg), claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	MagicLinkTTL      = 15 * time.Minute
+	MagicLinkCooldown = time.Minute
+)
+
+var (
+	ErrMagicLinkCooldown = errors.New("a login link was sent recently")
+	ErrInvalidMagicLink  = errors.New("invalid or expired login link")
+)
+
+// CreateMagicLink replaces the user's outstanding login link with a new one
+// and returns the frontend URL to email. It returns ErrMagicLinkCooldown if a
+// link was sent less than MagicLinkCooldown ago.
+func CreateMagicLink(user *models.User) (string, error) {
+	collection := configs.GetCollection(configs.DB, "magic_links")
+	userID := user.Id.Hex()
+	now := time.Now()
+
+	recent, err := collection.CountDocuments(context.TODO(), bson.M{
+		"user_id":   userID,
+		"createdAt": bson.M{"$gt": now.Add(-MagicLinkCooldown)},
+	})
+	if err != nil {
+		return "", err
+	}
+	if recent > 0 {
+		return "", ErrMagicLinkCooldown
+	}
+
+	raw, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	if _, err := collection.DeleteMany(context.TODO(), bson.M{"user_id": userID}); err != nil {
+		return "", err
+	}
+	_, err = collection.InsertOne(context.TODO(), models.MagicLink{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Email:     user.Email,
+		TokenHash: HashToken(raw),
+		CreatedAt: now,
+		ExpiresAt: now.Add(MagicLinkTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(configs.EnvFrontendURL(), "/") + "/auth/magic-link?token=" + url.QueryEscape(raw), nil
+}
+
+// ConsumeMagicLink deletes and returns an unexpired login link. The delete is
+// atomic, so a link can only be used once.
+func ConsumeMagicLink(raw string) (*models.MagicLink, error) {
+	if raw == "" {
+		return nil, ErrInvalidMagicLink
+	}
+	collection := configs.GetCollection(configs.DB, "magic_links")
+
+	var link models.MagicLink
+	err := collection.FindOneAndDelete(context.TODO(), bson.M{
+		"token_hash": HashToken(raw),
+		"expiresAt":  bson.M{"$gt": time.Now()},
+	}).Decode(&link)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, err
+	}
+	return &link, nil
+}
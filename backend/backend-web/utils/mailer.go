@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+
+	"github.com/sendgrid/sendgrid-go"
+	sgmail "github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+const (
+	smtpDialTimeout = 10 * time.Second
+	// smtpSendTimeout bounds a whole SMTP delivery so a stalled server cannot
+	// hold up the outbox worker
+	smtpSendTimeout = time.Minute
+)
+
+// ErrMailRejected marks a delivery the provider refused for good (e.g. an
+// invalid address); such emails are not retried
+var ErrMailRejected = errors.New("email rejected by provider")
+
+// MailMessage is a rendered email ready for delivery
+type MailMessage struct {
+	ID      string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers one email. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Name() string
+	Send(msg MailMessage) error
+}
+
+// NewMailer builds the transport selected by MAIL_TRANSPORT
+func NewMailer() (Mailer, error) {
+	from := configs.EnvMailFrom()
+	fromName := configs.EnvEmailBrandName()
+
+	switch transport := configs.EnvMailTransport(); transport {
+	case "sendgrid":
+		apiKey := configs.EnvSendgridAPIKey()
+		if apiKey == "" {
+			return nil, errors.New("SENDGRID_API_KEY is not set")
+		}
+		if from == "" {
+			return nil, errors.New("MAIL_FROM is not set")
+		}
+		return &sendgridMailer{apiKey: apiKey, from: from, fromName: fromName}, nil
+	case "smtp":
+		host := configs.EnvSMTPHost()
+		if host == "" {
+			return nil, errors.New("SMTP_HOST is not set")
+		}
+		if from == "" {
+			return nil, errors.New("MAIL_FROM is not set")
+		}
+		return &smtpMailer{
+			host:     host,
+			port:     configs.EnvSMTPPort(),
+			username: configs.EnvSMTPUsername(),
+			password: configs.EnvSMTPPassword(),
+			from:     from,
+			fromName: fromName,
+		}, nil
+	case "file":
+		dir := configs.EnvMailFileDir()
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		return &fileMailer{dir: dir, from: from, fromName: fromName}, nil
+	case "console":
+		return consoleMailer{}, nil
+	case "":
+		return nil, errors.New("MAIL_TRANSPORT is not set (use sendgrid or smtp, or file/console for local development)")
+	default:
+		return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q", transport)
+	}
+}
+
+type sendgridMailer struct {
+	apiKey   string
+	from     string
+	fromName string
+}
+
+func (m *sendgridMailer) Name() string { return "sendgrid" }
+
+func (m *sendgridMailer) Send(msg MailMessage) error {
+	from := sgmail.NewEmail(m.fromName, m.from)
+	to := sgmail.NewEmail("", msg.To)
+	message := sgmail.NewSingleEmail(from, msg.Subject, to, msg.Text, msg.HTML)
+
+	response, err := sendgrid.NewSendClient(m.apiKey).Send(message)
+	if err != nil {
+		return err
+	}
+	// 429 and 5xx are worth retrying; other 4xx mean the request itself is bad
+	if response.StatusCode >= 400 {
+		err := fmt.Errorf("sendgrid returned %d: %s", response.StatusCode, response.Body)
+		if response.StatusCode < 500 && response.StatusCode != 429 {
+			return fmt.Errorf("%w: %v", ErrMailRejected, err)
+		}
+		return err
+	}
+	return nil
+}
+
+type smtpMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	fromName string
+}
+
+func (m *smtpMailer) Name() string { return "smtp" }
+
+func (m *smtpMailer) Send(msg MailMessage) error {
+	body, err := buildMIMEMessage(m.from, m.fromName, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	conn, err := net.DialTimeout("tcp", addr, smtpDialTimeout)
+	if err != nil {
+		return err
+	}
+	// The deadline also covers the TLS layers wrapped around conn
+	if err := conn.SetDeadline(time.Now().Add(smtpSendTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	if m.port == 465 {
+		// Implicit TLS; other ports upgrade with STARTTLS below when offered
+		conn = tls.Client(conn, &tls.Config{ServerName: m.host})
+	}
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.from); err != nil {
+		return smtpError(err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return smtpError(err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return smtpError(err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return smtpError(err)
+	}
+	return client.Quit()
+}
+
+// smtpError marks permanent (5xx) SMTP replies as rejected
+func smtpError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return fmt.Errorf("%w: %v", ErrMailRejected, err)
+	}
+	return err
+}
+
+// fileMailer writes each email to an .eml file for local development
+type fileMailer struct {
+	dir      string
+	from     string
+	fromName string
+}
+
+func (m *fileMailer) Name() string { return "file" }
+
+func (m *fileMailer) Send(msg MailMessage) error {
+	from := m.from
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	body, err := buildMIMEMessage(from, m.fromName, msg)
+	if err != nil {
+		return err
+	}
+	name := time.Now().Format("20060102-150405") + "-" + msg.ID + ".eml"
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return err
+	}
+	log.Printf("📧 Email to %s written to %s", msg.To, path)
+	return nil
+}
+
+// consoleMailer prints the plain-text version of each email to the log
+type consoleMailer struct{}
+
+func (consoleMailer) Name() string { return "console" }
+
+func (consoleMailer) Send(msg MailMessage) error {
+	log.Printf("📧 Email to %s\nSubject: %s\n\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+// buildMIMEMessage encodes the email as multipart/alternative with text and
+// HTML parts
+func buildMIMEMessage(from, fromName string, msg MailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	headers := []string{
+		"From: " + (&mail.Address{Name: fromName, Address: from}).String(),
+		"To: " + (&mail.Address{Address: msg.To}).String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + writer.Boundary(),
+	}
+	if msg.ID != "" {
+		headers = append(headers, "Message-ID: <"+msg.ID+"@"+mailDomain(from)+">")
+	}
+	buf.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	parts := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	}
+	for _, p := range parts {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(p.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func mailDomain(address string) string {
+	if _, domain, ok := strings.Cut(address, "@"); ok && domain != "" {
+		return domain
+	}
+	return "localhost"
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/oauth2"
+)
+
+// OAuthFlowTTL is how long the user has to finish logging in at the provider
+const OAuthFlowTTL = 10 * time.Minute
+
+// oauthStateCookie binds a login attempt to the browser that started it
+const oauthStateCookie = "oauth_state"
+
+// defaultReturnPath is where the frontend finishes an OAuth login
+const defaultReturnPath = "/auth/oauth-callback"
+
+var (
+	ErrInvalidOAuthState = errors.New("invalid or expired OAuth state")
+	ErrInvalidReturnTo   = errors.New("return_to is not an allowed URL")
+)
+
+// CreateOAuthFlow stores a new login attempt with a fresh PKCE verifier and
+// nonce, and returns it with the raw state to send to the provider. A
+// non-empty linkUserID links the login to that user instead of signing in.
+func CreateOAuthFlow(provider, returnTo, linkUserID string) (string, *models.OAuthFlow, error) {
+	state, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	nonce, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	flow := &models.OAuthFlow{
+		ID:           primitive.NewObjectID(),
+		StateHash:    HashToken(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		ReturnTo:     returnTo,
+		LinkUserID:   linkUserID,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(OAuthFlowTTL),
+	}
+	collection := configs.GetCollection(configs.DB, "oauth_flows")
+	if _, err := collection.InsertOne(context.TODO(), flow); err != nil {
+		return "", nil, err
+	}
+	return state, flow, nil
+}
+
+// ConsumeOAuthFlow deletes and returns the unexpired flow for the state, so a
+// callback URL cannot be replayed
+func ConsumeOAuthFlow(state, provider string) (*models.OAuthFlow, error) {
+	if state == "" {
+		return nil, ErrInvalidOAuthState
+	}
+	collection := configs.GetCollection(configs.DB, "oauth_flows")
+
+	var flow models.OAuthFlow
+	err := collection.FindOneAndDelete(context.TODO(), bson.M{
+		"state_hash": HashToken(state),
+		"provider":   provider,
+		"expiresAt":  bson.M{"$gt": time.Now()},
+	}).Decode(&flow)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidOAuthState
+		}
+		return nil, err
+	}
+	return &flow, nil
+}
+
+// signOAuthState MACs the cookie payload with the server secret
+func signOAuthState(payload string) string {
+	mac := hmac.New(sha256.New, []byte(configs.EnvSecret()))
+	mac.Write([]byte("oauth_state\x00" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SetOAuthStateCookie stores the state in a signed, short-lived cookie. The
+// callback only accepts a state that matches the cookie, so an attacker
+// cannot finish a login they started in someone else's browser.
+func SetOAuthStateCookie(c *fiber.Ctx, state, provider string) {
+	expires := time.Now().Add(OAuthFlowTTL)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(state + "\x00" + provider + "\x00" + strconv.FormatInt(expires.Unix(), 10)))
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    payload + "." + signOAuthState(payload),
+		Path:     "/api/auth",
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		// Lax still sends the cookie on the provider's top-level redirect back
+		SameSite: "Lax",
+	})
+}
+
+// VerifyOAuthStateCookie checks the callback state against the signed cookie
+// and clears the cookie, which is only good for one callback
+func VerifyOAuthStateCookie(c *fiber.Ctx, state, provider string) error {
+	value := c.Cookies(oauthStateCookie)
+	c.Cookie(&fiber.Cookie{
+		Name:    oauthStateCookie,
+		Value:   "",
+		Path:    "/api/auth",
+		Expires: time.Unix(0, 0),
+	})
+
+	payload, signature, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signOAuthState(payload))) {
+		return ErrInvalidOAuthState
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return ErrInvalidOAuthState
+	}
+	parts := strings.Split(string(raw), "\x00")
+	if len(parts) != 3 {
+		return ErrInvalidOAuthState
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return ErrInvalidOAuthState
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(state)) != 1 || parts[1] != provider {
+		return ErrInvalidOAuthState
+	}
+	return nil
+}
+
+// ResolveReturnTo validates where to send the browser after an OAuth login.
+// Paths are resolved against FRONTEND_URL; absolute URLs must use FRONTEND_URL's
+// origin or one listed in OAUTH_RETURN_TO_ORIGINS. An empty value returns the
+// frontend's OAuth callback page.
+func ResolveReturnTo(raw string) (string, error) {
+	frontendURL := strings.TrimSuffix(configs.EnvFrontendURL(), "/")
+	if raw == "" {
+		return frontendURL + defaultReturnPath, nil
+	}
+	if strings.ContainsAny(raw, "\\\r\n\t") {
+		return "", ErrInvalidReturnTo
+	}
+
+	target, err := url.Parse(raw)
+	if err != nil || target.User != nil {
+		return "", ErrInvalidReturnTo
+	}
+
+	// A plain path such as /dashboard; "//host" would be protocol-relative
+	if target.Scheme == "" && target.Host == "" {
+		if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") {
+			return "", ErrInvalidReturnTo
+		}
+		return frontendURL + target.RequestURI(), nil
+	}
+
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return "", ErrInvalidReturnTo
+	}
+	origin := target.Scheme + "://" + target.Host
+	for _, allowed := range append([]string{frontendURL}, configs.EnvOAuthReturnOrigins()...) {
+		if allowedURL, err := url.Parse(allowed); err == nil && strings.EqualFold(origin, allowedURL.Scheme+"://"+allowedURL.Host) {
+			return target.String(), nil
+		}
+	}
+	return "", ErrInvalidReturnTo
+}
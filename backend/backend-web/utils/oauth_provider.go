@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"backend-web/configs"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+const githubAPIURL = "https://api.github.com"
+
+var (
+	ErrUnknownOAuthProvider = errors.New("unknown OAuth provider")
+	ErrOAuthEmailUnverified = errors.New("provider did not return a verified email")
+)
+
+var oauthHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// OAuthProfile is the signed-in user as reported by a provider
+type OAuthProfile struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+	Picture       string
+}
+
+// OAuthProvider is a configured login provider with its endpoints resolved
+type OAuthProvider struct {
+	Name      string
+	settings  configs.OAuthProviderSettings
+	config    *oauth2.Config
+	discovery *oidcDiscovery
+	keys      *oidcKeySet
+}
+
+var oauthRegistry = struct {
+	sync.Mutex
+	providers map[string]*OAuthProvider
+}{providers: map[string]*OAuthProvider{}}
+
+// OAuthProviderNames lists the configured providers
+func OAuthProviderNames() []string {
+	names := make([]string, 0, len(configs.OAuthProviders))
+	for name := range configs.OAuthProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetOAuthProvider returns a configured provider. OIDC providers are
+// discovered on first use; a failed discovery is retried on the next call.
+func GetOAuthProvider(name string) (*OAuthProvider, error) {
+	oauthRegistry.Lock()
+	defer oauthRegistry.Unlock()
+
+	if provider, ok := oauthRegistry.providers[name]; ok {
+		return provider, nil
+	}
+	settings, ok := configs.OAuthProviders[name]
+	if !ok {
+		return nil, ErrUnknownOAuthProvider
+	}
+
+	provider := &OAuthProvider{
+		Name:     name,
+		settings: settings,
+		config: &oauth2.Config{
+			ClientID:     settings.ClientID,
+			ClientSecret: settings.ClientSecret,
+			RedirectURL:  settings.RedirectURL,
+			Scopes:       settings.Scopes,
+		},
+	}
+	if settings.Type == configs.OAuthTypeGitHub {
+		provider.config.Endpoint = github.Endpoint
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		discovery, err := discoverOIDC(ctx, settings.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("discovering %s: %w", name, err)
+		}
+		provider.discovery = discovery
+		provider.keys = &oidcKeySet{uri: discovery.JWKSURI}
+		provider.config.Endpoint = oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		}
+	}
+
+	oauthRegistry.providers[name] = provider
+	return provider, nil
+}
+
+// AuthCodeURL builds the provider login URL with a PKCE challenge and, for
+// OIDC providers, the nonce the ID token must echo back
+func (p *OAuthProvider) AuthCodeURL(state, nonce, verifier string) string {
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier)}
+	if p.discovery != nil {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce))
+	}
+	return p.config.AuthCodeURL(state, opts...)
+}
+
+// Exchange redeems the authorization code and returns the user's profile.
+// It returns ErrOAuthEmailUnverified when the provider cannot vouch for the email.
+func (p *OAuthProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*OAuthProfile, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, oauthHTTPClient)
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	var profile *OAuthProfile
+	if p.discovery != nil {
+		profile, err = p.oidcProfile(ctx, token, nonce)
+	} else {
+		profile, err = githubProfile(ctx, token.AccessToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+	profile.Provider = p.Name
+	if profile.Email == "" || !profile.EmailVerified {
+		return profile, ErrOAuthEmailUnverified
+	}
+	return profile, nil
+}
+
+func (p *OAuthProvider) oidcProfile(ctx context.Context, token *oauth2.Token, nonce string) (*OAuthProfile, error) {
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+	claims, err := verifyIDToken(ctx, rawIDToken, p.discovery, p.keys, p.settings.ClientID, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some providers keep the email out of the ID token; ask userinfo for it
+	if claims.Email == "" && p.discovery.UserinfoEndpoint != "" {
+		var info oidcClaims
+		if err := fetchJSON(ctx, p.discovery.UserinfoEndpoint, token.AccessToken, &info); err != nil {
+			return nil, err
+		}
+		if info.Subject != claims.Subject {
+			return nil, fmt.Errorf("%w: userinfo subject does not match", ErrInvalidIDToken)
+		}
+		claims.Email, claims.EmailVerified = info.Email, info.EmailVerified
+		if claims.Name == "" {
+			claims.Name = info.Name
+		}
+		if claims.Picture == "" {
+			claims.Picture = info.Picture
+		}
+	}
+
+	verified := p.settings.TrustEmail
+	if claims.EmailVerified != nil {
+		verified = bool(*claims.EmailVerified)
+	}
+	return &OAuthProfile{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified,
+		Name:          claims.Name,
+		Username:      claims.PreferredUsername,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+// githubProfile reads the user and their primary verified email from the
+// GitHub API, since GitHub has no ID token
+func githubProfile(ctx context.Context, accessToken string) (*OAuthProfile, error) {
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := fetchJSON(ctx, githubAPIURL+"/user", accessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("GitHub returned no user id")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := fetchJSON(ctx, githubAPIURL+"/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	profile := &OAuthProfile{
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+		Username: user.Login,
+		Picture:  user.AvatarURL,
+	}
+	for _, email := range emails {
+		if email.Primary {
+			profile.Email, profile.EmailVerified = email.Email, email.Verified
+			break
+		}
+	}
+	return profile, nil
+}
@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksRefreshInterval limits how often an unknown kid can trigger a refetch
+const jwksRefreshInterval = time.Minute
+
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// oidcDiscovery is the part of /.well-known/openid-configuration we use
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// flexBool accepts both true and "true"; some providers send email_verified as a string
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	return nil
+}
+
+// oidcClaims are the ID token and userinfo claims we read
+type oidcClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string    `json:"nonce"`
+	AuthorizedParty   string    `json:"azp"`
+	TenantID          string    `json:"tid"`
+	Email             string    `json:"email"`
+	EmailVerified     *flexBool `json:"email_verified"`
+	Name              string    `json:"name"`
+	PreferredUsername string    `json:"preferred_username"`
+	Picture           string    `json:"picture"`
+}
+
+// fetchJSON GETs a URL and decodes the JSON body, optionally with a bearer token
+func fetchJSON(ctx context.Context, url, bearer string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// discoverOIDC loads the provider metadata from its issuer
+func discoverOIDC(ctx context.Context, issuer string) (*oidcDiscovery, error) {
+	var doc oidcDiscovery
+	if err := fetchJSON(ctx, issuer+"/.well-known/openid-configuration", "", &doc); err != nil {
+		return nil, err
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document for %s is missing endpoints", issuer)
+	}
+	// Entra's multi-tenant metadata uses a {tenantid} placeholder, which is
+	// resolved per token from the tid claim
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer && !strings.Contains(doc.Issuer, "{tenantid}") {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", doc.Issuer, issuer)
+	}
+	return &doc, nil
+}
+
+// oidcKeySet caches a provider's signing keys and refetches them when a token
+// uses a kid it has not seen, which is how providers roll their keys
+type oidcKeySet struct {
+	uri       string
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func (s *oidcKeySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key := s.lookup(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(s.fetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if key := s.lookup(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (s *oidcKeySet) lookup(kid string) crypto.PublicKey {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key
+		}
+	}
+	return s.keys[kid]
+}
+
+func (s *oidcKeySet) refresh(ctx context.Context) error {
+	var set struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	s.fetchedAt = time.Now()
+	if err := fetchJSON(ctx, s.uri, "", &set); err != nil {
+		return err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if use, _ := jwk["use"].(string); use == "enc" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			continue
+		}
+		kid, _ := jwk["kid"].(string)
+		keys[kid] = key
+	}
+	s.keys = keys
+	return nil
+}
+
+// parseJWK converts an RSA or EC public JWK into a Go public key
+func parseJWK(jwk map[string]interface{}) (crypto.PublicKey, error) {
+	field := func(name string) (*big.Int, error) {
+		value, _ := jwk[name].(string)
+		raw, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(raw) == 0 {
+			return nil, fmt.Errorf("invalid JWK field %s", name)
+		}
+		return new(big.Int).SetBytes(raw), nil
+	}
+
+	switch jwk["kty"] {
+	case "RSA":
+		n, err := field("n")
+		if err != nil {
+			return nil, err
+		}
+		e, err := field("e")
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk["crv"] {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %v", jwk["crv"])
+		}
+		x, err := field("x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := field("y")
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %v", jwk["kty"])
+}
+
+// verifyIDToken checks the ID token signature against the provider's keys and
+// validates issuer, audience, expiry and nonce
+func verifyIDToken(ctx context.Context, raw string, discovery *oidcDiscovery, keys *oidcKeySet, clientID, nonce string) (*oidcClaims, error) {
+	claims := &oidcClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	issuer := strings.ReplaceAll(discovery.Issuer, "{tenantid}", claims.TenantID)
+	if claims.Issuer != issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != clientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party %q", ErrInvalidIDToken, claims.AuthorizedParty)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
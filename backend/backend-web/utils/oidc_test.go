@@ -0,0 +1,390 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"backend-web/configs"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testClientID = "kale-test-client"
+	testAuthCode = "test-code"
+	testSubject  = "user-123"
+)
+
+// mockOIDCServer is a minimal OpenID provider with discovery, JWKS, token and
+// userinfo endpoints. It signs ID tokens with its own RSA key.
+type mockOIDCServer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	kid string
+	// issuer overrides the issuer in the discovery document
+	issuer string
+	// challenge is the PKCE code_challenge the token endpoint checks against
+	challenge string
+	// idToken is returned by the token endpoint
+	idToken  string
+	userinfo map[string]interface{}
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDCServer{key: key, kid: "test-key"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := m.issuer
+		if issuer == "" {
+			issuer = m.URL
+		}
+		writeTestJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"userinfo_endpoint":      m.URL + "/userinfo",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": m.kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != testAuthCode || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, map[string]string{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"id_token":     m.idToken,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, m.userinfo)
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// claims returns valid ID token claims for nonce
+func (m *mockOIDCServer) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            m.URL,
+		"sub":            testSubject,
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          "farmer@example.com",
+		"email_verified": true,
+	}
+}
+
+// sign signs claims with the server's key
+func (m *mockOIDCServer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	return signTestToken(t, m.key, m.kid, claims)
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// provider registers the server as the "mock" provider and discovers it
+func (m *mockOIDCServer) provider(t *testing.T, trustEmail bool) *OAuthProvider {
+	t.Helper()
+	configs.OAuthProviders["mock"] = configs.OAuthProviderSettings{
+		Name:        "mock",
+		Type:        configs.OAuthTypeOIDC,
+		ClientID:    testClientID,
+		Issuer:      m.URL,
+		RedirectURL: "http://localhost:8081/api/auth/oauth/mock/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+		TrustEmail:  trustEmail,
+	}
+	t.Cleanup(func() {
+		delete(configs.OAuthProviders, "mock")
+		oauthRegistry.Lock()
+		delete(oauthRegistry.providers, "mock")
+		oauthRegistry.Unlock()
+	})
+
+	provider, err := GetOAuthProvider("mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+// authorize builds the login URL and records its PKCE challenge on the server
+// as the authorization endpoint would
+func (m *mockOIDCServer) authorize(t *testing.T, provider *OAuthProvider, nonce, verifier string) url.Values {
+	t.Helper()
+	loginURL, err := url.Parse(provider.AuthCodeURL("test-state", nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := loginURL.Query()
+	m.challenge = query.Get("code_challenge")
+	return query
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	m := newMockOIDCServer(t)
+	provider := m.provider(t, false)
+	verifier := oauth2.GenerateVerifier()
+
+	query := m.authorize(t, provider, "nonce-1", verifier)
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("login URL has no S256 PKCE challenge: %v", query)
+	}
+	if query.Get("nonce") != "nonce-1" || query.Get("state") != "test-state" {
+		t.Fatalf("login URL has wrong nonce or state: %v", query)
+	}
+
+	m.idToken = m.sign(t, m.claims("nonce-1"))
+	profile, err := provider.Exchange(context.Background(), testAuthCode, verifier, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Provider != "mock" || profile.Subject != testSubject || profile.Email != "farmer@example.com" || !profile.EmailVerified {
+		t.Errorf("got profile %+v", profile)
+	}
+}
+
+func TestOIDCExchangeRejectsWrongVerifier(t *testing.T) {
+	m := newMockOIDCServer(t)
+	provider := m.provider(t, false)
+
+	m.authorize(t, provider, "nonce-1", oauth2.GenerateVerifier())
+	m.idToken = m.sign(t, m.claims("nonce-1"))
+	if _, err := provider.Exchange(context.Background(), testAuthCode, oauth2.GenerateVerifier(), "nonce-1"); err == nil {
+		t.Fatal("exchange succeeded with a verifier that does not match the challenge")
+	}
+}
+
+func TestOIDCDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	m := newMockOIDCServer(t)
+	m.issuer = "https://evil.example.com"
+
+	if _, err := discoverOIDC(context.Background(), m.URL); err == nil {
+		t.Fatal("discovery accepted a document for another issuer")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	m := newMockOIDCServer(t)
+	provider := m.provider(t, false)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	with := func(changes jwt.MapClaims) jwt.MapClaims {
+		claims := m.claims("nonce-1")
+		for name, value := range changes {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+	hmacToken := func() string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, m.claims("nonce-1"))
+		token.Header["kid"] = m.kid
+		raw, err := token.SignedString([]byte("shared-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		nonce string
+		valid bool
+	}{
+		{"valid token", m.sign(t, m.claims("nonce-1")), "nonce-1", true},
+		{"bad signature", signTestToken(t, otherKey, m.kid, m.claims("nonce-1")), "nonce-1", false},
+		{"unknown kid", signTestToken(t, otherKey, "other-key", m.claims("nonce-1")), "nonce-1", false},
+		{"HMAC algorithm", hmacToken(), "nonce-1", false},
+		{"wrong audience", m.sign(t, with(jwt.MapClaims{"aud": "someone-else"})), "nonce-1", false},
+		{"wrong nonce", m.sign(t, m.claims("nonce-1")), "nonce-2", false},
+		{"missing nonce", m.sign(t, with(jwt.MapClaims{"nonce": nil})), "nonce-1", false},
+		{"wrong issuer", m.sign(t, with(jwt.MapClaims{"iss": "https://evil.example.com"})), "nonce-1", false},
+		{"expired", m.sign(t, with(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})), "nonce-1", false},
+		{"missing expiry", m.sign(t, with(jwt.MapClaims{"exp": nil})), "nonce-1", false},
+		{"missing subject", m.sign(t, with(jwt.MapClaims{"sub": nil})), "nonce-1", false},
+		{"other authorized party", m.sign(t, with(jwt.MapClaims{"aud": []string{testClientID, "other"}, "azp": "other"})), "nonce-1", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := verifyIDToken(context.Background(), tc.token, provider.discovery, provider.keys, testClientID, tc.nonce)
+			if tc.valid {
+				if err != nil {
+					t.Fatalf("rejected a valid token: %v", err)
+				}
+				if claims.Subject != testSubject {
+					t.Errorf("got subject %q", claims.Subject)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("got %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestOIDCEmailVerification(t *testing.T) {
+	cases := []struct {
+		name         string
+		verified     interface{}
+		trustEmail   bool
+		wantVerified bool
+	}{
+		{"verified", true, false, true},
+		{"verified as a string", "true", false, true},
+		{"unverified", false, false, false},
+		{"unverified on a trusted provider", false, true, false},
+		{"missing", nil, false, false},
+		{"missing on a trusted provider", nil, true, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMockOIDCServer(t)
+			provider := m.provider(t, tc.trustEmail)
+			verifier := oauth2.GenerateVerifier()
+			m.authorize(t, provider, "nonce-1", verifier)
+
+			claims := m.claims("nonce-1")
+			delete(claims, "email_verified")
+			if tc.verified != nil {
+				claims["email_verified"] = tc.verified
+			}
+			m.idToken = m.sign(t, claims)
+
+			profile, err := provider.Exchange(context.Background(), testAuthCode, verifier, "nonce-1")
+			if tc.wantVerified {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !profile.EmailVerified {
+					t.Error("email is not marked verified")
+				}
+				return
+			}
+			if !errors.Is(err, ErrOAuthEmailUnverified) {
+				t.Fatalf("got %v, want ErrOAuthEmailUnverified", err)
+			}
+		})
+	}
+}
+
+func TestOIDCUserinfoFallback(t *testing.T) {
+	t.Run("email comes from userinfo", func(t *testing.T) {
+		m := newMockOIDCServer(t)
+		provider := m.provider(t, false)
+		verifier := oauth2.GenerateVerifier()
+		m.authorize(t, provider, "nonce-1", verifier)
+
+		claims := m.claims("nonce-1")
+		delete(claims, "email")
+		delete(claims, "email_verified")
+		m.idToken = m.sign(t, claims)
+		m.userinfo = map[string]interface{}{"sub": testSubject, "email": "grower@example.com", "email_verified": true}
+
+		profile, err := provider.Exchange(context.Background(), testAuthCode, verifier, "nonce-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if profile.Email != "grower@example.com" || !profile.EmailVerified {
+			t.Errorf("got profile %+v", profile)
+		}
+	})
+
+	t.Run("userinfo for another subject is rejected", func(t *testing.T) {
+		m := newMockOIDCServer(t)
+		provider := m.provider(t, false)
+		verifier := oauth2.GenerateVerifier()
+		m.authorize(t, provider, "nonce-1", verifier)
+
+		claims := m.claims("nonce-1")
+		delete(claims, "email")
+		m.idToken = m.sign(t, claims)
+		m.userinfo = map[string]interface{}{"sub": "someone-else", "email": "grower@example.com", "email_verified": true}
+
+		if _, err := provider.Exchange(context.Background(), testAuthCode, verifier, "nonce-1"); !errors.Is(err, ErrInvalidIDToken) {
+			t.Fatalf("got %v, want ErrInvalidIDToken", err)
+		}
+	})
+}
+
+func TestParseJWKRejectsPointOffCurve(t *testing.T) {
+	_, err := parseJWK(map[string]interface{}{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString([]byte{1}),
+		"y":   base64.RawURLEncoding.EncodeToString([]byte{2}),
+	})
+	if err == nil || !strings.Contains(err.Error(), "curve") {
+		t.Fatalf("got %v, want an off-curve error", err)
+	}
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetOrgMembership returns the user's membership in the organization, or nil if not a member
+func GetOrgMembership(orgID primitive.ObjectID, userID string) (*models.OrganizationMember, error) {
+	collection := configs.GetCollection(configs.DB, "organization_members")
+
+	var member models.OrganizationMember
+	err := collection.FindOne(context.TODO(), bson.M{"org_id": orgID, "user_id": userID}).Decode(&member)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetActiveOrgMembership returns the membership of the user's active
+// organization, or nil when the user works in their personal workspace. It
+// returns ErrOrgRequiresTwoFactor when the organization requires 2FA and the
+// user has not enabled it.
+func GetActiveOrgMembership(userID string) (*models.OrganizationMember, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	users := configs.GetCollection(configs.DB, "users")
+	var user models.User
+	if err := users.FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&user); err != nil {
+		return nil, err
+	}
+	if user.ActiveOrgID.IsZero() {
+		return nil, nil
+	}
+
+	member, err := GetOrgMembership(user.ActiveOrgID, userID)
+	if err != nil || member == nil {
+		return member, err
+	}
+	if !user.TwoFactorEnabled {
+		required, err := orgRequiresTwoFactor(user.ActiveOrgID)
+		if err != nil {
+			return nil, err
+		}
+		if required {
+			return nil, ErrOrgRequiresTwoFactor
+		}
+	}
+	return member, nil
+}
+
+// ClaimsOrgMembership returns the organization a request acts in. Requests
+// made with an API key use the key's organization, or the personal workspace
+// for personal keys; everything else uses GetActiveOrgMembership.
+func ClaimsOrgMembership(claims *models.Claims) (*models.OrganizationMember, error) {
+	if claims.APIKeyID == "" {
+		return GetActiveOrgMembership(claims.UserID)
+	}
+	if claims.OrgID == "" {
+		return nil, nil
+	}
+	orgID, err := primitive.ObjectIDFromHex(claims.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	member, err := GetOrgMembership(orgID, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return member, nil
+}
+
+// OrgMemberIDs lists the user IDs of every member of the organization
+func OrgMemberIDs(orgID string) []string {
+	objID, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return nil
+	}
+
+	collection := configs.GetCollection(configs.DB, "organization_members")
+	cursor, err := collection.Find(context.TODO(), bson.M{"org_id": objID})
+	if err != nil {
+		log.Printf("Error: Failed to query organization members - %v", err)
+		return nil
+	}
+	defer cursor.Close(context.TODO())
+
+	var members []models.OrganizationMember
+	if err := cursor.All(context.TODO(), &members); err != nil {
+		log.Printf("Error: Failed to decode organization members - %v", err)
+		return nil
+	}
+
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.UserID)
+	}
+	return ids
+}
+
+// PublishOrgEvent sends a stream event to every member of the organization,
+// or only to userID when the record is not shared with an organization
+func PublishOrgEvent(orgID, userID, eventType string, data interface{}) {
+	if orgID == "" {
+		PublishEvent(userID, eventType, data)
+		return
+	}
+	for _, memberID := range OrgMemberIDs(orgID) {
+		PublishEvent(memberID, eventType, data)
+	}
+}
+
+// EnsureOrgOwner promotes a member to owner when the organization has members
+// but no owner left, e.g. after its only owner's account was purged. The
+// oldest admin is preferred, then the oldest member of any role.
+func EnsureOrgOwner(ctx context.Context, orgID primitive.ObjectID) error {
+	collection := configs.GetCollection(configs.DB, "organization_members")
+	owners, err := collection.CountDocuments(ctx, bson.M{"org_id": orgID, "role": models.OrgRoleOwner})
+	if err != nil || owners > 0 {
+		return err
+	}
+
+	oldest := options.FindOne().SetSort(bson.D{{Key: "joinedAt", Value: 1}})
+	var member models.OrganizationMember
+	err = collection.FindOne(ctx, bson.M{"org_id": orgID, "role": models.OrgRoleAdmin}, oldest).Decode(&member)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = collection.FindOne(ctx, bson.M{"org_id": orgID}, oldest).Decode(&member)
+	}
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return err
+	}
+
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": member.ID}, bson.M{"$set": bson.M{"role": models.OrgRoleOwner}}); err != nil {
+		return err
+	}
+	log.Printf("Promoted user %s to owner of organization %s", member.UserID, orgID.Hex())
+	return nil
+}
+
+// UserOrgIDs lists the organizations where the user holds at least minRole
+func UserOrgIDs(userID, minRole string) ([]string, error) {
+	collection := configs.GetCollection(configs.DB, "organization_members")
+	cursor, err := collection.Find(context.TODO(), bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var members []models.OrganizationMember
+	if err := cursor.All(context.TODO(), &members); err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, m := range members {
+		if models.OrgRoleAtLeast(m.Role, minRole) {
+			ids = append(ids, m.OrgID.Hex())
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OTP purposes keep codes for different flows from being used for each other
+const (
+	OTPPurposeVerifyEmail   = "verify_email"
+	OTPPurposeResetPassword = "reset_password"
+	OTPPurposeChangeEmail   = "change_email"
+	OTPPurposeDeleteAccount = "delete_account"
+)
+
+// OTP policy shared by every flow
+const (
+	OTPTTL         = 10 * time.Minute
+	OTPCooldown    = time.Minute
+	MaxOTPAttempts = 5
+	otpDigits      = 6
+)
+
+var (
+	ErrOTPCooldown         = errors.New("a code was sent recently")
+	ErrOTPInvalid          = errors.New("invalid one-time code")
+	ErrOTPExpired          = errors.New("one-time code has expired")
+	ErrOTPAttemptsExceeded = errors.New("too many wrong one-time codes")
+)
+
+// generateOTPCode returns a uniformly random numeric code from crypto/rand
+func generateOTPCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < otpDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", otpDigits, n), nil
+}
+
+// hashOTPCode keys the hash with the server secret and binds it to the
+// purpose and email, so a leaked table cannot be brute forced offline
+func hashOTPCode(purpose, email, code string) string {
+	mac := hmac.New(sha256.New, []byte(configs.EnvSecret()))
+	mac.Write([]byte(purpose + "\x00" + email + "\x00" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IssueOTP replaces any pending code for the purpose and email with a new
+// one and returns it for sending. It returns ErrOTPCooldown if a code was
+// issued less than OTPCooldown ago.
+func IssueOTP(purpose, email string) (string, error) {
+	collection := configs.GetCollection(configs.DB, "otp_codes")
+	now := time.Now()
+
+	recent, err := collection.CountDocuments(context.TODO(), bson.M{
+		"purpose":   purpose,
+		"email":     email,
+		"createdAt": bson.M{"$gt": now.Add(-OTPCooldown)},
+	})
+	if err != nil {
+		return "", err
+	}
+	if recent > 0 {
+		return "", ErrOTPCooldown
+	}
+
+	if err := DeleteOTPs(purpose, email); err != nil {
+		return "", err
+	}
+
+	code, err := generateOTPCode()
+	if err != nil {
+		return "", err
+	}
+	_, err = collection.InsertOne(context.TODO(), models.OTPCode{
+		ID:        primitive.NewObjectID(),
+		Purpose:   purpose,
+		Email:     email,
+		CodeHash:  hashOTPCode(purpose, email, code),
+		CreatedAt: now,
+		ExpiresAt: now.Add(OTPTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// VerifyOTP checks a code and marks it verified. A wrong guess counts against
+// the code, which is deleted after MaxOTPAttempts wrong guesses.
+func VerifyOTP(purpose, email, code string) error {
+	collection := configs.GetCollection(configs.DB, "otp_codes")
+
+	var otp models.OTPCode
+	err := collection.FindOne(context.TODO(),
+		bson.M{"purpose": purpose, "email": email, "verifiedAt": bson.M{"$exists": false}},
+		options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	).Decode(&otp)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrOTPInvalid
+		}
+		return err
+	}
+	if time.Now().After(otp.ExpiresAt) {
+		return ErrOTPExpired
+	}
+
+	if !hmac.Equal([]byte(hashOTPCode(purpose, email, code)), []byte(otp.CodeHash)) {
+		var updated models.OTPCode
+		err := collection.FindOneAndUpdate(context.TODO(),
+			bson.M{"_id": otp.ID},
+			bson.M{"$inc": bson.M{"attempts": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return ErrOTPInvalid
+			}
+			return err
+		}
+		if updated.Attempts >= MaxOTPAttempts {
+			if _, err := collection.DeleteOne(context.TODO(), bson.M{"_id": otp.ID}); err != nil {
+				return err
+			}
+			return ErrOTPAttemptsExceeded
+		}
+		return ErrOTPInvalid
+	}
+
+	result, err := collection.UpdateOne(context.TODO(),
+		bson.M{"_id": otp.ID, "verifiedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"verifiedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return ErrOTPInvalid
+	}
+	return nil
+}
+
+// DeleteOTPs removes every code for the purpose and email
+func DeleteOTPs(purpose, email string) error {
+	collection := configs.GetCollection(configs.DB, "otp_codes")
+	_, err := collection.DeleteMany(context.TODO(), bson.M{"purpose": purpose, "email": email})
+	return err
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"backend-web/configs"
+)
+
+// bcrypt ignores everything after 72 bytes, so longer passwords are rejected
+// rather than silently truncated
+const maxPasswordBytes = 72
+
+// PasswordViolation is one failed password rule. Code is stable for clients,
+// Message is shown to the user.
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicy holds the configurable password rules
+type PasswordPolicy struct {
+	MinLength     int  `json:"minLength"`
+	RequireUpper  bool `json:"requireUpper"`
+	RequireLower  bool `json:"requireLower"`
+	RequireDigit  bool `json:"requireDigit"`
+	RequireSymbol bool `json:"requireSymbol"`
+}
+
+// CurrentPasswordPolicy returns the policy configured in the environment
+func CurrentPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     configs.EnvPasswordMinLength(),
+		RequireUpper:  configs.EnvPasswordRequireUpper(),
+		RequireLower:  configs.EnvPasswordRequireLower(),
+		RequireDigit:  configs.EnvPasswordRequireDigit(),
+		RequireSymbol: configs.EnvPasswordRequireSymbol(),
+	}
+}
+
+// ValidatePassword checks a new password against the policy and the breached
+// password list. Username and email are the account's own, which the password
+// may not contain. An empty result means the password is acceptable.
+func ValidatePassword(password, username, email string) []PasswordViolation {
+	policy := CurrentPasswordPolicy()
+	var violations []PasswordViolation
+
+	if len([]rune(password)) < policy.MinLength {
+		violations = append(violations, PasswordViolation{"too_short", fmt.Sprintf("Password must be at least %d characters long", policy.MinLength)})
+	}
+	if len(password) > maxPasswordBytes {
+		violations = append(violations, PasswordViolation{"too_long", fmt.Sprintf("Password must be at most %d bytes long", maxPasswordBytes)})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, PasswordViolation{"missing_uppercase", "Password must contain an uppercase letter"})
+	}
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, PasswordViolation{"missing_lowercase", "Password must contain a lowercase letter"})
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{"missing_digit", "Password must contain a number"})
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordViolation{"missing_symbol", "Password must contain a symbol"})
+	}
+
+	lower := strings.ToLower(password)
+	if containsIdentity(lower, username) {
+		violations = append(violations, PasswordViolation{"contains_username", "Password must not contain your username"})
+	}
+	localPart, _, _ := strings.Cut(email, "@")
+	if containsIdentity(lower, localPart) {
+		violations = append(violations, PasswordViolation{"contains_email", "Password must not contain your email address"})
+	}
+
+	breached, err := IsBreachedPassword(password)
+	if err != nil {
+		// Fail open so a broken list does not block every password change
+		log.Println("Error checking breached passwords:", err)
+	}
+	if breached {
+		violations = append(violations, PasswordViolation{"breached", "This password has appeared in a data breach. Please choose a different one."})
+	}
+
+	return violations
+}
+
+// containsIdentity reports whether the lowercased password contains value.
+// Very short values are ignored since they match too many passwords.
+func containsIdentity(lowerPassword, value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	return len(value) >= 3 && strings.Contains(lowerPassword, value)
+}
+
+var missingBreachListOnce sync.Once
+
+// IsBreachedPassword looks the password up in the local breached password
+// list. The list uses the k-anonymity range format: the upper-case SHA-1 of
+// the password is split after 5 characters, and <dir>/<PREFIX>.txt holds one
+// "SUFFIX:COUNT" line per breached password with that prefix. Range
+// responses from the Pwned Passwords API can be saved there unchanged.
+func IsBreachedPassword(password string) (bool, error) {
+	dir := configs.EnvBreachedPasswordsDir()
+	if dir == "" {
+		return false, nil
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			if _, statErr := os.Stat(dir); statErr != nil {
+				missingBreachListOnce.Do(func() {
+					log.Printf("Warning: breached password list %q not found, skipping the check", dir)
+				})
+			}
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ResetTokenTTL is how long the user has to choose a new password after
+// verifying the reset OTP
+const ResetTokenTTL = 15 * time.Minute
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreateResetToken replaces any outstanding reset token for the user and
+// returns the raw value of the new one
+func CreateResetToken(user *models.User) (string, error) {
+	raw, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	collection := configs.GetCollection(configs.DB, "password_reset_tokens")
+	userID := user.Id.Hex()
+	if _, err := collection.DeleteMany(context.TODO(), bson.M{"user_id": userID}); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = collection.InsertOne(context.TODO(), models.PasswordResetToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Email:     user.Email,
+		TokenHash: HashToken(raw),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ResetTokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// GetResetToken loads an unexpired reset token without using it up
+func GetResetToken(raw string) (*models.PasswordResetToken, error) {
+	collection := configs.GetCollection(configs.DB, "password_reset_tokens")
+
+	var token models.PasswordResetToken
+	err := collection.FindOne(context.TODO(), bson.M{
+		"token_hash": HashToken(raw),
+		"expiresAt":  bson.M{"$gt": time.Now()},
+	}).Decode(&token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidResetToken
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ConsumeResetToken deletes an unexpired reset token and returns it. The
+// delete is atomic, so concurrent requests cannot both use the same token.
+func ConsumeResetToken(raw string) (*models.PasswordResetToken, error) {
+	collection := configs.GetCollection(configs.DB, "password_reset_tokens")
+
+	var token models.PasswordResetToken
+	err := collection.FindOneAndDelete(context.TODO(), bson.M{
+		"token_hash": HashToken(raw),
+		"expiresAt":  bson.M{"$gt": time.Now()},
+	}).Decode(&token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidResetToken
+		}
+		return nil, err
+	}
+	return &token, nil
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReuse   = errors.New("refresh token reuse detected")
+)
+
+// TokenPair is the access/refresh token pair returned by every login method
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	SessionID    string
+	ExpiresIn    int64
+}
+
+// IssueTokenPair signs an access token and stores a new refresh token. An
+// empty sessionID records a new session (i.e. a new login); the session ID is
+// also the refresh token family ID.
+func IssueTokenPair(c *fiber.Ctx, user *models.User, sessionID string) (*TokenPair, error) {
+	if sessionID == "" {
+		session, err := CreateSession(c, user.Id.Hex())
+		if err != nil {
+			return nil, err
+		}
+		sessionID = session.ID.Hex()
+	}
+	familyID := sessionID
+
+	access, err := GenerateJWT(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	collection := configs.GetCollection(configs.DB, "refresh_tokens")
+	_, err = collection.InsertOne(context.TODO(), models.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.Id.Hex(),
+		FamilyID:  familyID,
+		TokenHash: HashToken(raw),
+		CreatedAt: now,
+		ExpiresAt: now.Add(configs.EnvRefreshTokenTTL()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: raw,
+		SessionID:    sessionID,
+		ExpiresIn:    int64(configs.EnvAccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// RotateRefreshToken consumes a refresh token and issues a new pair in the
+// same family. Presenting a token that was already used or revoked revokes
+// the whole family, since it means the token has leaked.
+func RotateRefreshToken(c *fiber.Ctx, raw string) (*models.User, *TokenPair, error) {
+	if raw == "" {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	collection := configs.GetCollection(configs.DB, "refresh_tokens")
+	hash := HashToken(raw)
+	now := time.Now()
+
+	// Claim the token atomically so two concurrent refreshes cannot both succeed
+	var current models.RefreshToken
+	err := collection.FindOneAndUpdate(context.TODO(),
+		bson.M{
+			"token_hash": hash,
+			"usedAt":     bson.M{"$exists": false},
+			"revokedAt":  bson.M{"$exists": false},
+			"expiresAt":  bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"usedAt": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&current)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil, err
+		}
+
+		var stale models.RefreshToken
+		if err := collection.FindOne(context.TODO(), bson.M{"token_hash": hash}).Decode(&stale); err == nil &&
+			(!stale.UsedAt.IsZero() || !stale.RevokedAt.IsZero()) {
+			log.Printf("Warning: refresh token reuse for user %s, revoking family %s", stale.UserID, stale.FamilyID)
+			if err := RevokeRefreshFamily(stale.UserID, stale.FamilyID); err != nil {
+				log.Println("Error revoking refresh token family:", err)
+			}
+			return nil, nil, ErrRefreshTokenReuse
+		}
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	// The session may have been signed out from another device
+	if !IsSessionActive(current.FamilyID) {
+		RevokeRefreshFamily(current.UserID, current.FamilyID)
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(current.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	var user models.User
+	users := configs.GetCollection(configs.DB, "users")
+	if err := users.FindOne(context.TODO(), bson.M{"_id": userObjID}).Decode(&user); err != nil || user.Disabled {
+		RevokeRefreshFamily(current.UserID, current.FamilyID)
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	pair, err := IssueTokenPair(c, &user, current.FamilyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ExtendSession(c, current.FamilyID); err != nil {
+		log.Println("Error extending session:", err)
+	}
+	return &user, pair, nil
+}
+
+// RevokeRefreshFamily revokes every token of one of the user's families
+func RevokeRefreshFamily(userID, familyID string) error {
+	collection := configs.GetCollection(configs.DB, "refresh_tokens")
+	_, err := collection.UpdateMany(context.TODO(),
+		bson.M{"user_id": userID, "family_id": familyID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	return err
+}
+
+// RefreshTokenSession returns the session ID (family) of a refresh token
+func RefreshTokenSession(raw string) (string, error) {
+	collection := configs.GetCollection(configs.DB, "refresh_tokens")
+	var token models.RefreshToken
+	err := collection.FindOne(context.TODO(), bson.M{"token_hash": HashToken(raw)}).Decode(&token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", nil
+		}
+		return "", err
+	}
+	return token.FamilyID, nil
+}
+
+// SetAuthCookies stores the token pair in cookies for browser flows. The
+// refresh token cookie is HTTP-only and only sent to the auth endpoints.
+func SetAuthCookies(c *fiber.Ctx, pair *TokenPair) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "token",
+		Value:    pair.AccessToken,
+		Expires:  time.Now().Add(configs.EnvAccessTokenTTL()),
+		HTTPOnly: false,
+		Secure:   false,
+		SameSite: "Lax",
+	})
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    pair.RefreshToken,
+		Path:     "/api/auth",
+		Expires:  time.Now().Add(configs.EnvRefreshTokenTTL()),
+		HTTPOnly: true,
+		Secure:   false,
+		SameSite: "Lax",
+	})
+}
+
+// ClearAuthCookies removes the cookies set by SetAuthCookies
+func ClearAuthCookies(c *fiber.Ctx) {
+	c.ClearCookie("token")
+	c.Cookie(&fiber.Cookie{
+		Name:    "refresh_token",
+		Value:   "",
+		Path:    "/api/auth",
+		Expires: time.Unix(0, 0),
+	})
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	"backend-web/configs"
+)
+
+// secretAEAD returns AES-256-GCM keyed from SIGNING_KEY_KEK. The info string
+// derives a separate key for each kind of secret.
+func secretAEAD(info string) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, []byte(configs.EnvSigningKeyKEK()), nil, info, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSecret encrypts plaintext for storage. The owner, e.g. a key ID or user
+// ID, is authenticated with it so a ciphertext cannot be moved to another
+// record.
+func sealSecret(info, owner string, plaintext []byte) (string, error) {
+	aead, err := secretAEAD(info)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(owner))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openSecret decrypts a value from sealSecret
+func openSecret(info, owner, encrypted string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := secretAEAD(info)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed secret is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(owner))
+}
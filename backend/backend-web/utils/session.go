@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// lastSeenResolution limits how often a session's last-seen time is written
+const lastSeenResolution = time.Minute
+
+// describeDevice turns a User-Agent into a short "Browser on OS" label
+func describeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"), strings.Contains(ua, "python"), strings.Contains(ua, "go-http-client"):
+		browser = "Script"
+	}
+
+	platform := "unknown OS"
+	switch {
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"):
+		platform = "iOS"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os"):
+		platform = "macOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	return browser + " on " + platform
+}
+
+// CreateSession records a new login from the request's device and IP
+func CreateSession(c *fiber.Ctx, userID string) (*models.Session, error) {
+	return insertSession(c, userID, configs.EnvRefreshTokenTTL(), "")
+}
+
+// CreateImpersonationSession records an admin acting as the user. It lasts as
+// long as the impersonation token and is revoked with the user's other
+// sessions, e.g. when the account is disabled or purged.
+func CreateImpersonationSession(c *fiber.Ctx, userID, adminID string) (*models.Session, error) {
+	return insertSession(c, userID, impersonationTokenTTL, adminID)
+}
+
+func insertSession(c *fiber.Ctx, userID string, ttl time.Duration, impersonatorID string) (*models.Session, error) {
+	now := time.Now()
+	userAgent := c.Get("User-Agent")
+	session := models.Session{
+		ID:             primitive.NewObjectID(),
+		UserID:         userID,
+		Device:         describeDevice(userAgent),
+		UserAgent:      userAgent,
+		IP:             c.IP(),
+		CreatedAt:      now,
+		LastSeenAt:     now,
+		ExpiresAt:      now.Add(ttl),
+		ImpersonatorID: impersonatorID,
+	}
+
+	collection := configs.GetCollection(configs.DB, "sessions")
+	if _, err := collection.InsertOne(context.TODO(), session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// IsSessionActive reports whether the session exists, is not revoked and has
+// not expired. It also refreshes the session's last-seen time.
+func IsSessionActive(sessionID string) bool {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return false
+	}
+
+	collection := configs.GetCollection(configs.DB, "sessions")
+	var session models.Session
+	if err := collection.FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&session); err != nil {
+		return false
+	}
+	now := time.Now()
+	if !session.RevokedAt.IsZero() || now.After(session.ExpiresAt) {
+		return false
+	}
+
+	if now.Sub(session.LastSeenAt) > lastSeenResolution {
+		go func() {
+			_, err := collection.UpdateOne(context.TODO(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"lastSeenAt": now}})
+			if err != nil {
+				log.Println("Error updating session last-seen:", err)
+			}
+		}()
+	}
+	return true
+}
+
+// ExtendSession pushes back the expiry of a session after a token refresh
+func ExtendSession(c *fiber.Ctx, sessionID string) error {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	collection := configs.GetCollection(configs.DB, "sessions")
+	_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": objID}, bson.M{"$set": bson.M{
+		"lastSeenAt": now,
+		"ip":         c.IP(),
+		"expiresAt":  now.Add(configs.EnvRefreshTokenTTL()),
+	}})
+	return err
+}
+
+// RevokeSession signs out one session of the user, including its refresh tokens
+func RevokeSession(userID, sessionID string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return false, nil
+	}
+
+	collection := configs.GetCollection(configs.DB, "sessions")
+	result, err := collection.UpdateOne(context.TODO(),
+		bson.M{"_id": objID, "user_id": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	// Nothing else to do for another user's or an already revoked session
+	if result.MatchedCount == 0 {
+		return false, nil
+	}
+	forgetSessions(userID, sessionID)
+	if err := RevokeRefreshFamily(userID, sessionID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RevokeUserSessions signs the user out everywhere, optionally keeping one session
+func RevokeUserSessions(userID, exceptSessionID string) error {
+	filter := bson.M{"user_id": userID, "revokedAt": bson.M{"$exists": false}}
+	refreshFilter := bson.M{"user_id": userID, "revokedAt": bson.M{"$exists": false}}
+	if exceptSessionID != "" {
+		if objID, err := primitive.ObjectIDFromHex(exceptSessionID); err == nil {
+			filter["_id"] = bson.M{"$ne": objID}
+		}
+		refreshFilter["family_id"] = bson.M{"$ne": exceptSessionID}
+	}
+
+	now := time.Now()
+	sessions := configs.GetCollection(configs.DB, "sessions")
+	if _, err := sessions.UpdateMany(context.TODO(), filter, bson.M{"$set": bson.M{"revokedAt": now}}); err != nil {
+		return err
+	}
+	forgetSessions(userID, "")
+	refreshTokens := configs.GetCollection(configs.DB, "refresh_tokens")
+	_, err := refreshTokens.UpdateMany(context.TODO(), refreshFilter, bson.M{"$set": bson.M{"revokedAt": now}})
+	return err
+}
@@ -0,0 +1,318 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// keyRingRefreshInterval is how often keys are reloaded and rotation is checked
+	keyRingRefreshInterval = time.Minute
+	// keyReloadCooldown limits reloads triggered by tokens with an unknown kid
+	keyReloadCooldown = 10 * time.Second
+)
+
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// signingKeyKEKInfo separates the key derived from SIGNING_KEY_KEK from any
+// other use of the same secret
+const signingKeyKEKInfo = "kale signing key encryption v1"
+
+type loadedKey struct {
+	kid       string
+	alg       string
+	private   crypto.Signer
+	public    crypto.PublicKey
+	createdAt time.Time
+}
+
+// keyRing holds the parsed signing keys. current is the key new tokens are
+// signed with; keys holds every key that may still verify a token.
+var keyRing = struct {
+	sync.RWMutex
+	current  *loadedKey
+	keys     map[string]*loadedKey
+	loadedAt time.Time
+}{keys: make(map[string]*loadedKey)}
+
+// generateSigningKey creates a new key pair for the algorithm
+func generateSigningKey(alg string) (*models.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return nil, errors.New("unsupported signing algorithm " + alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	kid, err := GenerateSecureToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := encryptPrivateKey(kid, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SigningKey{
+		ID:                  primitive.NewObjectID(),
+		Kid:                 kid,
+		Algorithm:           alg,
+		EncryptedPrivateKey: encrypted,
+		PublicKey:           string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		CreatedAt:           time.Now(),
+	}, nil
+}
+
+// encryptPrivateKey seals a private key PEM for storage, bound to its kid
+func encryptPrivateKey(kid string, privatePEM []byte) (string, error) {
+	return sealSecret(signingKeyKEKInfo, kid, privatePEM)
+}
+
+func decryptPrivateKey(kid, encrypted string) ([]byte, error) {
+	return openSecret(signingKeyKEKInfo, kid, encrypted)
+}
+
+func parsePrivateKey(privatePEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(privatePEM)
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	return private, nil
+}
+
+func parsePublicKey(publicPEM string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicPEM))
+	if block == nil {
+		return nil, errors.New("invalid public key PEM")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// parseSigningKey loads a stored key. A private key that cannot be decrypted,
+// e.g. after SIGNING_KEY_KEK changed, still verifies tokens with the public
+// key but never signs again.
+func parseSigningKey(key models.SigningKey) (*loadedKey, error) {
+	loaded := &loadedKey{kid: key.Kid, alg: key.Algorithm, createdAt: key.CreatedAt}
+
+	privatePEM, err := decryptPrivateKey(key.Kid, key.EncryptedPrivateKey)
+	if err == nil {
+		loaded.private, err = parsePrivateKey(privatePEM)
+	}
+	if err != nil {
+		log.Printf("Error: Failed to load private signing key %s, it will only verify - %v", key.Kid, err)
+		loaded.public, err = parsePublicKey(key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		return loaded, nil
+	}
+	loaded.public = loaded.private.Public()
+	return loaded, nil
+}
+
+// loadSigningKeys replaces the key ring with the keys stored in Mongo
+func loadSigningKeys() error {
+	collection := configs.GetCollection(configs.DB, "signing_keys")
+	filter := bson.M{"$or": []bson.M{
+		{"expiresAt": bson.M{"$exists": false}},
+		{"expiresAt": bson.M{"$gt": time.Now()}},
+	}}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return err
+	}
+	var stored []models.SigningKey
+	if err := cursor.All(context.TODO(), &stored); err != nil {
+		return err
+	}
+
+	alg := configs.EnvJWTAlgorithm()
+	keys := make(map[string]*loadedKey, len(stored))
+	var current *loadedKey
+	for _, key := range stored {
+		loaded, err := parseSigningKey(key)
+		if err != nil {
+			log.Printf("Error: Failed to parse signing key %s - %v", key.Kid, err)
+			continue
+		}
+		keys[key.Kid] = loaded
+		if current == nil && loaded.private != nil && key.RetiredAt.IsZero() && key.Algorithm == alg {
+			current = loaded
+		}
+	}
+
+	keyRing.Lock()
+	keyRing.current = current
+	keyRing.keys = keys
+	keyRing.loadedAt = time.Now()
+	keyRing.Unlock()
+	return nil
+}
+
+// rotateSigningKeys creates a new signing key when there is none for the
+// configured algorithm or the current one is older than the rotation period.
+// Older keys are retired but stay published until their tokens expire.
+func rotateSigningKeys() error {
+	if err := loadSigningKeys(); err != nil {
+		return err
+	}
+
+	alg := configs.EnvJWTAlgorithm()
+	if alg == "HS256" {
+		return nil
+	}
+
+	keyRing.RLock()
+	current := keyRing.current
+	keyRing.RUnlock()
+	if current != nil && time.Since(current.createdAt) < configs.EnvSigningKeyRotation() {
+		return nil
+	}
+
+	key, err := generateSigningKey(alg)
+	if err != nil {
+		return err
+	}
+	collection := configs.GetCollection(configs.DB, "signing_keys")
+	if _, err := collection.InsertOne(context.TODO(), key); err != nil {
+		return err
+	}
+
+	// Only retire keys older than the new one, so instances rotating at the
+	// same time still agree that the newest key is current
+	verifyWindow := configs.EnvAccessTokenTTL()
+	if impersonationTokenTTL > verifyWindow {
+		verifyWindow = impersonationTokenTTL
+	}
+	_, err = collection.UpdateMany(context.TODO(),
+		bson.M{"createdAt": bson.M{"$lt": key.CreatedAt}, "retiredAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"retiredAt": key.CreatedAt, "expiresAt": key.CreatedAt.Add(verifyWindow + time.Minute)}},
+	)
+	if err != nil {
+		return err
+	}
+	log.Printf("Rotated %s signing key, new kid %s", alg, key.Kid)
+	return loadSigningKeys()
+}
+
+// StartKeyRotation loads the signing keys, creating the first one if needed,
+// and keeps them rotated and in sync with other server instances
+func StartKeyRotation() {
+	if err := rotateSigningKeys(); err != nil {
+		log.Println("⚠️ Failed to load signing keys:", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(keyRingRefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := rotateSigningKeys(); err != nil {
+				log.Println("Signing key rotation error:", err)
+			}
+		}
+	}()
+}
+
+func currentSigningKey() (*loadedKey, error) {
+	keyRing.RLock()
+	current := keyRing.current
+	keyRing.RUnlock()
+	if current == nil {
+		return nil, errors.New("no active signing key")
+	}
+	return current, nil
+}
+
+// verificationKey returns the public key for a kid, reloading the key ring
+// when the kid was created by another instance after the last load
+func verificationKey(kid, alg string) (crypto.PublicKey, error) {
+	keyRing.RLock()
+	key, ok := keyRing.keys[kid]
+	loadedAt := keyRing.loadedAt
+	keyRing.RUnlock()
+
+	if !ok && time.Since(loadedAt) > keyReloadCooldown {
+		if err := loadSigningKeys(); err != nil {
+			return nil, err
+		}
+		keyRing.RLock()
+		key, ok = keyRing.keys[kid]
+		keyRing.RUnlock()
+	}
+	if !ok || key.alg != alg {
+		return nil, ErrUnknownSigningKey
+	}
+	return key.public, nil
+}
+
+// PublicJWKS returns the public half of every key that may still verify a
+// token, in JSON Web Key format
+func PublicJWKS() []map[string]string {
+	keyRing.RLock()
+	defer keyRing.RUnlock()
+
+	encode := base64.RawURLEncoding.EncodeToString
+	jwks := make([]map[string]string, 0, len(keyRing.keys))
+	for _, key := range keyRing.keys {
+		jwk := map[string]string{"kid": key.kid, "alg": key.alg, "use": "sig"}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = encode(public.N.Bytes())
+			jwk["e"] = encode(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			jwk["kty"] = "EC"
+			jwk["crv"] = public.Curve.Params().Name
+			jwk["x"] = encode(public.X.FillBytes(make([]byte, 32)))
+			jwk["y"] = encode(public.Y.FillBytes(make([]byte, 32)))
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
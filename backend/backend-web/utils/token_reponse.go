@@ -4,25 +4,117 @@ import (
 	"context"
 	"backend-web/configs"
 	"backend-web/models"
+	"log"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// revocationSyncInterval is how often tokens blacklisted by other instances
+// are pulled into the in-memory cache
+const revocationSyncInterval = 15 * time.Second
+
+// revokedTokens mirrors the blacklisted_tokens collection in memory, keyed by
+// token hash, so requests never need a database round trip to check it
+var revokedTokens = struct {
+	sync.RWMutex
+	entries  map[string]time.Time
+	syncedAt time.Time
+}{entries: make(map[string]time.Time)}
+
+// BlacklistToken revokes an access token until it would have expired anyway.
+// Only the token's hash is stored.
 func BlacklistToken(token string, expiration time.Duration) error {
 	collection := configs.GetCollection(configs.DB, "blacklisted_tokens")
 
-	_, err := collection.InsertOne(context.TODO(), models.BlacklistedToken{
-		Token:     token,
+	entry := models.BlacklistedToken{
+		ID:        primitive.NewObjectID(),
+		Token:     HashToken(token),
 		ExpiredAt: time.Now().Add(expiration),
-	})
-	return err
+	}
+	if _, err := collection.InsertOne(context.TODO(), entry); err != nil {
+		return err
+	}
+
+	revokedTokens.Lock()
+	revokedTokens.entries[entry.Token] = entry.ExpiredAt
+	revokedTokens.Unlock()
+	forgetVerifiedToken(entry.Token)
+	return nil
 }
 
+// IsTokenBlacklisted checks the in-memory revocation cache
 func IsTokenBlacklisted(token string) bool {
+	return isTokenHashRevoked(HashToken(token))
+}
+
+func isTokenHashRevoked(tokenHash string) bool {
+	revokedTokens.RLock()
+	expiredAt, ok := revokedTokens.entries[tokenHash]
+	revokedTokens.RUnlock()
+	return ok && time.Now().Before(expiredAt)
+}
+
+// syncRevokedTokens loads blacklist entries added since the last sync and
+// drops entries whose tokens have expired
+func syncRevokedTokens() error {
 	collection := configs.GetCollection(configs.DB, "blacklisted_tokens")
 
-	var result models.BlacklistedToken
-	err := collection.FindOne(context.TODO(), bson.M{"token": token}).Decode(&result)
-	return err == nil
+	revokedTokens.RLock()
+	syncedAt := revokedTokens.syncedAt
+	revokedTokens.RUnlock()
+
+	// ObjectIDs from other instances are only roughly ordered, so each sync
+	// overlaps the previous one instead of resuming from the last ID seen
+	now := time.Now()
+	filter := bson.M{"expired_at": bson.M{"$gt": now}}
+	if !syncedAt.IsZero() {
+		filter["_id"] = bson.M{"$gte": primitive.NewObjectIDFromTimestamp(syncedAt.Add(-revocationSyncInterval))}
+	}
+	cursor, err := collection.Find(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	var entries []models.BlacklistedToken
+	if err := cursor.All(context.TODO(), &entries); err != nil {
+		return err
+	}
+
+	revokedTokens.Lock()
+	for hash, expiredAt := range revokedTokens.entries {
+		if now.After(expiredAt) {
+			delete(revokedTokens.entries, hash)
+		}
+	}
+	for _, entry := range entries {
+		revokedTokens.entries[entry.Token] = entry.ExpiredAt
+	}
+	revokedTokens.syncedAt = now
+	revokedTokens.Unlock()
+
+	for _, entry := range entries {
+		forgetVerifiedToken(entry.Token)
+	}
+	return nil
+}
+
+// StartRevocationSync loads the token blacklist and keeps it in sync with
+// revocations made by other server instances
+func StartRevocationSync() {
+	if err := syncRevokedTokens(); err != nil {
+		log.Println("⚠️ Failed to load token blacklist:", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(revocationSyncInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := syncRevokedTokens(); err != nil {
+				log.Println("Token blacklist sync error:", err)
+			}
+			pruneVerifiedTokens()
+		}
+	}()
 }
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// verifiedTokenTTL bounds how long a verified token and its session state are
+// trusted before they are checked again
+const verifiedTokenTTL = 30 * time.Second
+
+var (
+	ErrInvalidToken   = errors.New("invalid or expired token")
+	ErrTokenRevoked   = errors.New("token has been revoked")
+	ErrSessionRevoked = errors.New("session has been signed out")
+)
+
+type verifiedToken struct {
+	claims    *models.Claims
+	expiresAt time.Time
+}
+
+type sessionState struct {
+	userID    string
+	active    bool
+	checkedAt time.Time
+}
+
+var verifierCache = struct {
+	sync.RWMutex
+	tokens   map[string]verifiedToken
+	sessions map[string]sessionState
+}{
+	tokens:   make(map[string]verifiedToken),
+	sessions: make(map[string]sessionState),
+}
+
+// ExtractToken reads the access token from the token cookie or the
+// Authorization bearer header
+func ExtractToken(c *fiber.Ctx) string {
+	if token := c.Cookies("token"); token != "" {
+		return token
+	}
+	authHeader := c.Get("Authorization")
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		return authHeader[7:]
+	}
+	return ""
+}
+
+// VerifyToken checks an access token's signature, expiry, revocation and
+// login session. Results are cached briefly so most requests skip both the
+// signature check and the database.
+func VerifyToken(tokenStr string) (*models.Claims, error) {
+	tokenHash := HashToken(tokenStr)
+	if isTokenHashRevoked(tokenHash) {
+		return nil, ErrTokenRevoked
+	}
+
+	now := time.Now()
+	verifierCache.RLock()
+	cached, ok := verifierCache.tokens[tokenHash]
+	verifierCache.RUnlock()
+
+	claims := cached.claims
+	if !ok || now.After(cached.expiresAt) {
+		var err error
+		claims, err = parseAccessToken(tokenStr)
+		if err != nil {
+			return nil, err
+		}
+
+		expiresAt := now.Add(verifiedTokenTTL)
+		if claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(expiresAt) {
+			expiresAt = claims.ExpiresAt.Time
+		}
+		verifierCache.Lock()
+		verifierCache.tokens[tokenHash] = verifiedToken{claims: claims, expiresAt: expiresAt}
+		verifierCache.Unlock()
+	}
+
+	if claims.SessionID != "" && !isSessionActiveCached(claims.UserID, claims.SessionID) {
+		return nil, ErrSessionRevoked
+	}
+	return claims, nil
+}
+
+// acceptedAlgorithms lists the algorithms tokens may be signed with. HS256 is
+// only trusted while it is still the configured algorithm; after switching,
+// clients swap their old access tokens through the refresh endpoint.
+func acceptedAlgorithms() []string {
+	if configs.EnvJWTAlgorithm() == jwt.SigningMethodHS256.Alg() {
+		return []string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}
+	}
+	return []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}
+}
+
+func parseAccessToken(tokenStr string) (*models.Claims, error) {
+	claims := &models.Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		alg := token.Method.Alg()
+		if alg == jwt.SigningMethodHS256.Alg() {
+			return []byte(configs.EnvSecret()), nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		return verificationKey(kid, alg)
+	}, jwt.WithValidMethods(acceptedAlgorithms()), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	if _, err := primitive.ObjectIDFromHex(claims.UserID); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func isSessionActiveCached(userID, sessionID string) bool {
+	now := time.Now()
+	verifierCache.RLock()
+	state, ok := verifierCache.sessions[sessionID]
+	verifierCache.RUnlock()
+	if ok && now.Sub(state.checkedAt) < verifiedTokenTTL {
+		return state.active
+	}
+
+	active := IsSessionActive(sessionID)
+	verifierCache.Lock()
+	verifierCache.sessions[sessionID] = sessionState{userID: userID, active: active, checkedAt: now}
+	verifierCache.Unlock()
+	return active
+}
+
+// forgetSessions drops cached session state for the user so a revocation
+// takes effect on this instance immediately. An empty sessionID forgets all
+// of the user's sessions.
+func forgetSessions(userID, sessionID string) {
+	verifierCache.Lock()
+	defer verifierCache.Unlock()
+	for id, state := range verifierCache.sessions {
+		if id == sessionID || (sessionID == "" && state.userID == userID) {
+			delete(verifierCache.sessions, id)
+		}
+	}
+}
+
+func forgetVerifiedToken(tokenHash string) {
+	verifierCache.Lock()
+	delete(verifierCache.tokens, tokenHash)
+	verifierCache.Unlock()
+}
+
+// pruneVerifiedTokens evicts stale cache entries
+func pruneVerifiedTokens() {
+	now := time.Now()
+	verifierCache.Lock()
+	defer verifierCache.Unlock()
+	for hash, entry := range verifierCache.tokens {
+		if now.After(entry.expiresAt) {
+			delete(verifierCache.tokens, hash)
+		}
+	}
+	for id, state := range verifierCache.sessions {
+		if now.Sub(state.checkedAt) >= verifiedTokenTTL {
+			delete(verifierCache.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// TOTPIssuer is the account label shown in authenticator apps
+	TOTPIssuer = "Kale"
+
+	MFAChallengeTTL         = 5 * time.Minute
+	maxMFAChallengeAttempts = 5
+	recoveryCodeCount       = 10
+
+	// totpSecretInfo separates the key that seals TOTP secrets from other
+	// uses of SIGNING_KEY_KEK
+	totpSecretInfo = "kale totp secret encryption v1"
+)
+
+var (
+	ErrInvalidMFAChallenge  = errors.New("invalid or expired MFA challenge")
+	ErrOrgRequiresTwoFactor = errors.New("organization requires two-factor authentication")
+)
+
+// SealTOTPSecret encrypts a TOTP secret for the user's twoFactorSecret and
+// twoFactorPending fields. The sealed value only opens for the same user.
+func SealTOTPSecret(userID, secret string) (string, error) {
+	return sealSecret(totpSecretInfo, userID, []byte(secret))
+}
+
+// OpenTOTPSecret decrypts a secret stored by SealTOTPSecret
+func OpenTOTPSecret(userID, sealed string) (string, error) {
+	secret, err := openSecret(totpSecretInfo, userID, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// normalizeRecoveryCode makes recovery codes case and separator insensitive
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// GenerateRecoveryCodes returns new one-time recovery codes for display and
+// their hashes for storage
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := GenerateSecureToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, HashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// VerifySecondFactor accepts either a current TOTP code or an unused recovery
+// code. Both are consumed atomically so a code works only once. If the stored
+// secret cannot be decrypted, e.g. after SIGNING_KEY_KEK changed, only
+// recovery codes work.
+func VerifySecondFactor(user *models.User, code string) (bool, error) {
+	users := configs.GetCollection(configs.DB, "users")
+
+	secret, err := OpenTOTPSecret(user.Id.Hex(), user.TwoFactorSecret)
+	if err != nil {
+		log.Printf("Error: Failed to decrypt TOTP secret of user %s - %v", user.Id.Hex(), err)
+	}
+	if step, ok := ValidateTOTP(secret, code, time.Now()); err == nil && ok {
+		result, err := users.UpdateOne(context.TODO(),
+			bson.M{"_id": user.Id, "$or": []bson.M{
+				{"twoFactorLastStep": bson.M{"$exists": false}},
+				{"twoFactorLastStep": bson.M{"$lt": step}},
+			}},
+			bson.M{"$set": bson.M{"twoFactorLastStep": step}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount > 0, nil
+	}
+
+	hash := HashToken(normalizeRecoveryCode(code))
+	result, err := users.UpdateOne(context.TODO(),
+		bson.M{"_id": user.Id, "recoveryCodes": hash},
+		bson.M{"$pull": bson.M{"recoveryCodes": hash}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// CreateMFAChallenge starts the second login step and returns the raw token
+func CreateMFAChallenge(userID string) (string, error) {
+	raw, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	collection := configs.GetCollection(configs.DB, "mfa_challenges")
+	_, err = collection.InsertOne(context.TODO(), models.MFAChallenge{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		TokenHash: HashToken(raw),
+		CreatedAt: now,
+		ExpiresAt: now.Add(MFAChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// GetMFAChallenge loads a challenge that is unexpired and has attempts left
+func GetMFAChallenge(raw string) (*models.MFAChallenge, error) {
+	collection := configs.GetCollection(configs.DB, "mfa_challenges")
+
+	var challenge models.MFAChallenge
+	err := collection.FindOne(context.TODO(), bson.M{
+		"token_hash": HashToken(raw),
+		"expiresAt":  bson.M{"$gt": time.Now()},
+		"attempts":   bson.M{"$lt": maxMFAChallengeAttempts},
+	}).Decode(&challenge)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidMFAChallenge
+		}
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// RecordMFAFailure counts a wrong code against the challenge
+func RecordMFAFailure(challengeID primitive.ObjectID) error {
+	collection := configs.GetCollection(configs.DB, "mfa_challenges")
+	_, err := collection.UpdateOne(context.TODO(), bson.M{"_id": challengeID}, bson.M{"$inc": bson.M{"attempts": 1}})
+	return err
+}
+
+// CompleteMFAChallenge deletes the challenge, failing if another request
+// already used it
+func CompleteMFAChallenge(challengeID primitive.ObjectID) error {
+	collection := configs.GetCollection(configs.DB, "mfa_challenges")
+	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": challengeID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrInvalidMFAChallenge
+	}
+	return nil
+}
+
+// CheckOrgTwoFactor returns ErrOrgRequiresTwoFactor when the organization
+// requires 2FA and the user has not enabled it
+func CheckOrgTwoFactor(orgID primitive.ObjectID, userID string) error {
+	required, err := orgRequiresTwoFactor(orgID)
+	if err != nil || !required {
+		return err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	var user models.User
+	users := configs.GetCollection(configs.DB, "users")
+	if err := users.FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&user); err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled {
+		return ErrOrgRequiresTwoFactor
+	}
+	return nil
+}
+
+func orgRequiresTwoFactor(orgID primitive.ObjectID) (bool, error) {
+	var org models.Organization
+	orgs := configs.GetCollection(configs.DB, "organizations")
+	if err := orgs.FindOne(context.TODO(), bson.M{"_id": orgID}).Decode(&org); err != nil {
+		return false, err
+	}
+	return org.Require2FA, nil
+}
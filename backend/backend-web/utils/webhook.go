@@ -0,0 +1,324 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"backend-web/configs"
+	"backend-web/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = 6 * time.Hour
+	webhookPollInterval = 5 * time.Second
+	webhookLockTimeout  = 2 * time.Minute
+)
+
+var ErrWebhookAddressBlocked = errors.New("webhook address is not publicly routable")
+
+// webhookClient only connects to public addresses. The check runs on the
+// resolved IP of every connection, so DNS names pointing inside the network
+// are refused too. Redirects are not followed.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+					return ErrWebhookAddressBlocked
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// carrierGradeNAT is the shared address space (100.64.0.0/10)
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIP reports whether webhooks may be delivered to the address. Loopback,
+// private, link-local (including cloud metadata), multicast and unspecified
+// addresses are refused.
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		carrierGradeNAT.Contains(ip))
+}
+
+// IsPublicWebhookHost resolves the host and reports whether all of its
+// addresses are public. Delivery checks again at connect time, since DNS
+// answers can change.
+func IsPublicWebhookHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return IsPublicIP(ip)
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateWebhookSecret returns a random secret used to sign deliveries
+func GenerateWebhookSecret() (string, error) {
+	secret, err := GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + secret, nil
+}
+
+// SignWebhookPayload computes the HMAC-SHA256 signature of "<timestamp>.<body>"
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// EmitWebhookEvent queues a delivery for every active subscription that
+// listens to the given event: the user's personal subscriptions, plus the
+// organization's when orgID is set. Failures are logged and never returned to
+// the caller so that the originating request is not affected.
+func EmitWebhookEvent(userID, orgID, event string, data interface{}) {
+	if userID == "" {
+		return
+	}
+
+	owners := []bson.M{{"user_id": userID, "org_id": bson.M{"$exists": false}}}
+	if orgID != "" {
+		owners = append(owners, bson.M{"org_id": orgID})
+	}
+
+	collection := configs.GetCollection(configs.DB, "webhook_subscriptions")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{
+		"$or":    owners,
+		"active": true,
+		"events": event,
+	})
+	if err != nil {
+		log.Printf("Error: Failed to query webhook subscriptions - %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var subscriptions []models.WebhookSubscription
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		log.Printf("Error: Failed to decode webhook subscriptions - %v", err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	eventID := primitive.NewObjectID()
+	payload, err := json.Marshal(bson.M{
+		"id":         eventID.Hex(),
+		"event":      event,
+		"created_at": time.Now().UTC(),
+		"data":       data,
+	})
+	if err != nil {
+		log.Printf("Error: Failed to encode webhook payload - %v", err)
+		return
+	}
+
+	deliveries := configs.GetCollection(configs.DB, "webhook_deliveries")
+	now := time.Now()
+	for _, sub := range subscriptions {
+		delivery := models.WebhookDelivery{
+			ID:             primitive.NewObjectID(),
+			SubscriptionID: sub.ID,
+			UserID:         sub.UserID,
+			Event:          event,
+			Payload:        string(payload),
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			ExpiresAt:      now.Add(configs.EnvWebhookDeliveryRetention()),
+		}
+		if _, err := deliveries.InsertOne(ctx, delivery); err != nil {
+			log.Printf("Error: Failed to queue webhook delivery for subscription %s - %v", sub.ID.Hex(), err)
+		}
+	}
+}
+
+// StartWebhookWorker polls the persistent delivery queue and sends due deliveries
+func StartWebhookWorker() {
+	go func() {
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			releaseStaleWebhookLocks()
+			for processNextWebhookDelivery() {
+			}
+		}
+	}()
+	log.Println("✅ Webhook delivery worker started")
+}
+
+// releaseStaleWebhookLocks puts back deliveries that were claimed by a worker
+// that never finished them (e.g. the process was restarted mid-send)
+func releaseStaleWebhookLocks() {
+	collection := configs.GetCollection(configs.DB, "webhook_deliveries")
+	_, err := collection.UpdateMany(context.TODO(),
+		bson.M{
+			"status":    models.WebhookDeliverySending,
+			"locked_at": bson.M{"$lt": time.Now().Add(-webhookLockTimeout)},
+		},
+		bson.M{"$set": bson.M{"status": models.WebhookDeliveryPending}},
+	)
+	if err != nil {
+		log.Printf("Error: Failed to release stale webhook deliveries - %v", err)
+	}
+}
+
+// processNextWebhookDelivery claims and sends one due delivery. It reports
+// whether a delivery was found so the caller can keep draining the queue.
+func processNextWebhookDelivery() bool {
+	collection := configs.GetCollection(configs.DB, "webhook_deliveries")
+	now := time.Now()
+
+	var delivery models.WebhookDelivery
+	err := collection.FindOneAndUpdate(context.TODO(),
+		bson.M{
+			"status":          models.WebhookDeliveryPending,
+			"next_attempt_at": bson.M{"$lte": now},
+		},
+		bson.M{"$set": bson.M{"status": models.WebhookDeliverySending, "locked_at": now}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&delivery)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Error: Failed to claim webhook delivery - %v", err)
+		}
+		return false
+	}
+
+	subscriptions := configs.GetCollection(configs.DB, "webhook_subscriptions")
+	var sub models.WebhookSubscription
+	err = subscriptions.FindOne(context.TODO(), bson.M{"_id": delivery.SubscriptionID}).Decode(&sub)
+	if err != nil || !sub.Active {
+		finishWebhookDelivery(delivery, 0, errors.New("subscription not found or inactive"), true)
+		return true
+	}
+
+	statusCode, sendErr := sendWebhook(sub, delivery)
+	finishWebhookDelivery(delivery, statusCode, sendErr, false)
+	return true
+}
+
+func sendWebhook(sub models.WebhookSubscription, delivery models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest("POST", sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Kale-Webhooks/1.0")
+	req.Header.Set("X-Kale-Event", delivery.Event)
+	req.Header.Set("X-Kale-Delivery", delivery.ID.Hex())
+	req.Header.Set("X-Kale-Timestamp", timestamp)
+	req.Header.Set("X-Kale-Signature", SignWebhookPayload(sub.Secret, timestamp, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func finishWebhookDelivery(delivery models.WebhookDelivery, statusCode int, sendErr error, permanent bool) {
+	collection := configs.GetCollection(configs.DB, "webhook_deliveries")
+	attempts := delivery.Attempts + 1
+	now := time.Now()
+
+	set := bson.M{
+		"attempts":         attempts,
+		"last_status_code": statusCode,
+	}
+	if sendErr == nil {
+		set["status"] = models.WebhookDeliveryDelivered
+		set["deliveredAt"] = now
+		set["last_error"] = ""
+	} else {
+		set["last_error"] = sendErr.Error()
+		if permanent || attempts >= webhookMaxAttempts {
+			set["status"] = models.WebhookDeliveryFailed
+		} else {
+			set["status"] = models.WebhookDeliveryPending
+			set["next_attempt_at"] = now.Add(webhookBackoff(attempts))
+		}
+		log.Printf("Webhook delivery %s attempt %d failed: %v", delivery.ID.Hex(), attempts, sendErr)
+	}
+
+	_, err := collection.UpdateOne(context.TODO(),
+		bson.M{"_id": delivery.ID},
+		bson.M{"$set": set, "$unset": bson.M{"locked_at": ""}},
+	)
+	if err != nil {
+		log.Printf("Error: Failed to update webhook delivery %s - %v", delivery.ID.Hex(), err)
+	}
+}
+
+// webhookBackoff returns the delay before the next attempt: 30s, 1m, 2m, 4m ... capped at 6h
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return delay
+}
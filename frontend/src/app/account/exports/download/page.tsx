@@ -0,0 +1,133 @@
+"use client";
+
+import Link from "next/link";
+import { useEffect, useRef, useState } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import { motion } from "framer-motion";
+import Cookies from "js-cookie";
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardFooter,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import { AlertBox } from "@/components/ui/alert";
+import { useLayout } from "@/components/ui/LayoutContext";
+import { authFetch } from "@/lib/auth";
+
+// fileNameFrom reads the attachment name the API suggests for the zip
+const fileNameFrom = (disposition: string | null) => {
+  const match = disposition?.match(/filename="?([^";]+)"?/);
+  return match ? match[1] : "kale-export.zip";
+};
+
+export default function DownloadDataExportPage() {
+  const router = useRouter();
+  const searchParams = useSearchParams();
+  const { setShowNavAndFooter } = useLayout();
+  const [error, setError] = useState("");
+  const [downloaded, setDownloaded] = useState(false);
+  // Start the download once, even when the effect runs twice
+  const started = useRef(false);
+
+  useEffect(() => {
+    setShowNavAndFooter(false);
+    return () => setShowNavAndFooter(true);
+  }, [setShowNavAndFooter]);
+
+  useEffect(() => {
+    if (started.current) return;
+    started.current = true;
+
+    const id = searchParams.get("id");
+    if (!id) {
+      setError("This download link is incomplete. Request a new export.");
+      return;
+    }
+
+    // Exports are downloaded by their signed-in owner, so sign in first and
+    // come back to this link
+    if (!Cookies.get("token") && !Cookies.get("refresh_token")) {
+      const returnTo = `/account/exports/download?id=${encodeURIComponent(id)}`;
+      router.replace(`/auth/login?return_to=${encodeURIComponent(returnTo)}`);
+      return;
+    }
+
+    const download = async () => {
+      try {
+        const res = await authFetch(
+          `http://localhost:8081/api/account/exports/${encodeURIComponent(
+            id
+          )}/download`
+        );
+
+        if (!res.ok) {
+          const data = await res.json().catch(() => ({}));
+          throw new Error(data.message || "Could not download the export");
+        }
+
+        const blob = await res.blob();
+        const url = URL.createObjectURL(blob);
+        const link = document.createElement("a");
+        link.href = url;
+        link.download = fileNameFrom(res.headers.get("Content-Disposition"));
+        document.body.appendChild(link);
+        link.click();
+        link.remove();
+        URL.revokeObjectURL(url);
+        setDownloaded(true);
+      } catch (err: any) {
+        setError(err.message || "Could not download the export");
+      }
+    };
+
+    download();
+  }, [router, searchParams]);
+
+  return (
+    <div className="flex justify-center items-center min-h-screen bg-gradient-to-b from-transparent to-green-50 dark:to-green-950/20 py-10">
+      <motion.div
+        initial={{ opacity: 0, y: 20 }}
+        animate={{ opacity: 1, y: 0 }}
+        transition={{ duration: 0.5 }}
+        className="w-full max-w-md px-4"
+      >
+        <Card className="w-full shadow-lg border border-green-300 dark:border-green-700 dark:bg-gray-900">
+          <CardHeader className="space-y-1">
+            <CardTitle className="text-2xl text-center text-green-800 dark:text-green-400">
+              {error
+                ? "Download Not Available"
+                : downloaded
+                ? "Download Started"
+                : "Preparing Download"}
+            </CardTitle>
+            <CardDescription className="text-center text-green-600 dark:text-green-300">
+              {error
+                ? "Exports can be downloaded for 7 days, by the account that requested them."
+                : downloaded
+                ? "Your data export has been saved by your browser."
+                : "Please wait while we fetch your data export..."}
+            </CardDescription>
+          </CardHeader>
+          {error && (
+            <CardContent>
+              <AlertBox type="error" message={error} />
+            </CardContent>
+          )}
+          {(error || downloaded) && (
+            <CardFooter className="justify-center">
+              <Link
+                href="/"
+                className="font-bold text-sm text-green-600 hover:text-green-800 dark:text-green-400 dark:hover:text-green-500"
+              >
+                Go to Home
+              </Link>
+            </CardFooter>
+          )}
+        </Card>
+      </motion.div>
+    </div>
+  );
+}
@@ -88,6 +88,12 @@ export default function ResetPasswordPage() {
       return;
     }
 
+    const resetToken = sessionStorage.getItem("resetToken");
+    if (!resetToken) {
+      setError("Your reset session has expired. Please verify OTP again.");
+      return;
+    }
+
     setIsLoading(true);
     setError("");
     setSuccess("");
@@ -100,7 +106,7 @@ export default function ResetPasswordPage() {
           headers: {
             "Content-Type": "application/json",
           },
-          body: JSON.stringify({ email, newPassword: password }),
+          body: JSON.stringify({ resetToken, newPassword: password }),
         }
       );
 
@@ -108,11 +114,8 @@ export default function ResetPasswordPage() {
 
       if (!response.ok) {
         if (response.status === 401) {
-          setError("No verified OTP found. Please verify OTP first.");
-        } else if (response.status === 403) {
-          setError("Email not verified. Please verify your email first.");
-        } else if (response.status === 404) {
-          setError("Email not found. Please check your email address.");
+          sessionStorage.removeItem("resetToken");
+          setError("Your reset session has expired. Please verify OTP again.");
         } else {
           setError(
             data.message || "Failed to reset password. Please try again."
@@ -123,6 +126,7 @@ export default function ResetPasswordPage() {
       }
 
       // Success case
+      sessionStorage.removeItem("resetToken");
       setSuccess(
         "Password reset successfully! You will be redirected to login."
       );
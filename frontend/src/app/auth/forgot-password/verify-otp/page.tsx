@@ -243,6 +243,9 @@ export default function VerifyOTPPage() {
         return;
       }
 
+      // The reset page needs this token to set the new password
+      sessionStorage.setItem("resetToken", data.data.resetToken);
+
       setSuccess("OTP verified successfully!");
       setIsLoading(false);
 
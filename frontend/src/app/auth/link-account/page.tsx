@@ -0,0 +1,167 @@
+"use client";
+
+import Link from "next/link";
+import { useEffect, useState } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import { motion } from "framer-motion";
+import { Eye, EyeOff } from "lucide-react";
+import { Button } from "@/components/ui/button";
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardFooter,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import { Input } from "@/components/ui/input";
+import { AlertBox } from "@/components/ui/alert";
+import { useLayout } from "@/components/ui/LayoutContext";
+import { safeReturnTo, storeAuthTokens } from "@/lib/auth";
+
+const providerNames: Record<string, string> = {
+  google: "Google",
+  github: "GitHub",
+};
+
+export default function LinkAccountPage() {
+  const router = useRouter();
+  const searchParams = useSearchParams();
+  const { setShowNavAndFooter } = useLayout();
+  const [password, setPassword] = useState("");
+  const [showPassword, setShowPassword] = useState(false);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState("");
+
+  const linkToken = searchParams.get("link_token") || "";
+  const provider = searchParams.get("provider") || "";
+  const providerName = providerNames[provider] || provider || "this provider";
+
+  useEffect(() => {
+    setShowNavAndFooter(false);
+    return () => setShowNavAndFooter(true);
+  }, [setShowNavAndFooter]);
+
+  useEffect(() => {
+    if (!linkToken) {
+      router.replace("/auth/login");
+    }
+  }, [linkToken, router]);
+
+  const handleConfirm = async () => {
+    setLoading(true);
+    setError("");
+
+    try {
+      const res = await fetch(
+        "http://localhost:8081/api/auth/identities/confirm",
+        {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({ link_token: linkToken, password }),
+        }
+      );
+
+      const data = await res.json();
+      if (!res.ok) {
+        throw new Error(data.message || "Could not link your account");
+      }
+
+      // The login continues like a password login, so 2FA may still apply
+      if (data.mfa_required) {
+        const query = new URLSearchParams({ mfa_token: data.mfa_token });
+        if (data.return_to) query.set("return_to", data.return_to);
+        router.replace(`/auth/two-factor?${query.toString()}`);
+        return;
+      }
+
+      storeAuthTokens(data);
+      router.replace(safeReturnTo(data.return_to));
+    } catch (err: any) {
+      setError(err.message || "Could not link your account. Please try again.");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="flex justify-center items-center min-h-screen bg-gradient-to-b from-transparent to-green-50 dark:to-green-950/20 py-10">
+      <motion.div
+        initial={{ opacity: 0, y: 20 }}
+        animate={{ opacity: 1, y: 0 }}
+        transition={{ duration: 1.0 }}
+        className="w-full max-w-lg mt-6"
+      >
+        <Card className="w-full max-w-md sm:max-w-lg lg:max-w-lg sm:p-6 shadow-lg border border-green-300 dark:border-green-700 dark:bg-gray-900">
+          <CardHeader className="space-y-1">
+            <CardTitle className="text-2xl text-center text-green-800 dark:text-green-400">
+              Link Your {providerName} Login
+            </CardTitle>
+            <CardDescription className="text-center text-green-600 dark:text-green-300">
+              An account with this email already exists. Enter its password
+              to sign in with {providerName} from now on.
+            </CardDescription>
+          </CardHeader>
+
+          <CardContent className="space-y-4">
+            {error && (
+              <AlertBox
+                className="mb-2"
+                type="error"
+                message={error}
+                onClose={() => setError("")}
+              />
+            )}
+            <div className="space-y-2">
+              <label
+                className="text-sm font-medium text-green-700 dark:text-green-300"
+                htmlFor="password"
+              >
+                Password
+              </label>
+              <div className="relative">
+                <Input
+                  id="password"
+                  type={showPassword ? "text" : "password"}
+                  placeholder="••••••••"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  onKeyDown={(e) => {
+                    if (e.key === "Enter" && password) handleConfirm();
+                  }}
+                  className="border-green-200 dark:border-gray-600 focus:border-green-500 dark:bg-gray-800 dark:text-white"
+                  disabled={loading}
+                />
+                <button
+                  type="button"
+                  onClick={() => setShowPassword(!showPassword)}
+                  className="absolute right-3 top-1/2 -translate-y-1/2 text-green-600 dark:text-green-300 hover:text-green-800 dark:hover:text-green-500"
+                >
+                  {showPassword ? <Eye size={20} /> : <EyeOff size={20} />}
+                </button>
+              </div>
+            </div>
+          </CardContent>
+          <CardFooter className="flex flex-col space-y-4">
+            <Button
+              onClick={handleConfirm}
+              disabled={loading || !password}
+              className="w-full bg-green-600 hover:bg-green-700 dark:bg-green-500 dark:hover:bg-green-600"
+            >
+              {loading ? "Linking..." : "Link and Sign in"}
+            </Button>
+            <div className="text-center text-sm text-green-600 dark:text-green-300">
+              Not your account?{" "}
+              <Link
+                href="/auth/login"
+                className="font-bold text-green-600 hover:text-green-800 dark:text-green-400 dark:hover:text-green-500"
+              >
+                Back to Sign in
+              </Link>
+            </div>
+          </CardFooter>
+        </Card>
+      </motion.div>
+    </div>
+  );
+}
@@ -14,13 +14,15 @@ import {
 } from "@/components/ui/card";
 import { Input } from "@/components/ui/input";
 import { useState, useEffect } from "react";
-import { useRouter } from "next/navigation";
-import Cookies from "js-cookie";
+import { useRouter, useSearchParams } from "next/navigation";
 import { AlertBox } from "@/components/ui/alert";
 import { useLayout } from "@/components/ui/LayoutContext";
+import { safeReturnTo, storeAuthTokens } from "@/lib/auth";
 
 export default function LoginPage() {
   const router = useRouter();
+  // Set by pages that need a signed-in user, e.g. an invitation link
+  const returnTo = useSearchParams().get("return_to");
   const { setShowNavAndFooter } = useLayout();
   const [identity, setIdentity] = useState("");
   const [password, setPassword] = useState("");
@@ -51,12 +53,17 @@ export default function LoginPage() {
       }
 
       const data = await res.json();
-      Cookies.set("token", data.token, {
-        expires: 7,
-        secure: true,
-        sameSite: "Strict",
-      });
-      router.push("/");
+
+      // Accounts with 2FA finish signing in with a code
+      if (data.mfa_required) {
+        const query = new URLSearchParams({ mfa_token: data.mfa_token });
+        if (returnTo) query.set("return_to", returnTo);
+        router.push(`/auth/two-factor?${query.toString()}`);
+        return;
+      }
+
+      storeAuthTokens(data);
+      router.push(safeReturnTo(returnTo));
     } catch (err: any) {
       setError(err.message);
     } finally {
@@ -64,7 +71,7 @@ export default function LoginPage() {
     }
   };
   const handleGoogleLogin = () => {
-    window.location.href = "http://localhost:8081/api/auth/oauth";
+    window.location.href = "http://localhost:8081/api/auth/oauth/google";
   };
   return (
     <div className="flex justify-center items-center min-h-screen bg-gradient-to-b from-transparent to-green-50 dark:to-green-950/20 py-10">
@@ -0,0 +1,114 @@
+"use client";
+
+import Link from "next/link";
+import { useEffect, useRef, useState } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import { motion } from "framer-motion";
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardFooter,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import { AlertBox } from "@/components/ui/alert";
+import { useLayout } from "@/components/ui/LayoutContext";
+import { storeAuthTokens } from "@/lib/auth";
+
+export default function MagicLinkPage() {
+  const router = useRouter();
+  const searchParams = useSearchParams();
+  const { setShowNavAndFooter } = useLayout();
+  const [error, setError] = useState("");
+  // The link works only once, so it must not be sent twice
+  const verified = useRef(false);
+
+  useEffect(() => {
+    setShowNavAndFooter(false);
+    return () => setShowNavAndFooter(true);
+  }, [setShowNavAndFooter]);
+
+  useEffect(() => {
+    if (verified.current) return;
+    verified.current = true;
+
+    const token = searchParams.get("token");
+    if (!token) {
+      setError("This sign-in link is incomplete. Please request a new one.");
+      return;
+    }
+
+    const verify = async () => {
+      try {
+        const res = await fetch(
+          "http://localhost:8081/api/auth/magic-link/verify",
+          {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ token }),
+          }
+        );
+
+        const data = await res.json();
+        if (!res.ok) {
+          throw new Error(data.message || "Sign-in failed");
+        }
+
+        // Accounts with 2FA still have to enter a code
+        if (data.mfa_required) {
+          router.replace(
+            `/auth/two-factor?mfa_token=${encodeURIComponent(data.mfa_token)}`
+          );
+          return;
+        }
+
+        storeAuthTokens(data);
+        router.replace("/");
+      } catch (err: any) {
+        setError(err.message || "Sign-in failed. Please try again.");
+      }
+    };
+
+    verify();
+  }, [router, searchParams]);
+
+  return (
+    <div className="flex justify-center items-center min-h-screen bg-gradient-to-b from-transparent to-green-50 dark:to-green-950/20 py-10">
+      <motion.div
+        initial={{ opacity: 0, y: 20 }}
+        animate={{ opacity: 1, y: 0 }}
+        transition={{ duration: 0.5 }}
+        className="w-full max-w-md px-4"
+      >
+        <Card className="w-full shadow-lg border border-green-300 dark:border-green-700 dark:bg-gray-900">
+          <CardHeader className="space-y-1">
+            <CardTitle className="text-2xl text-center text-green-800 dark:text-green-400">
+              {error ? "Sign-in Link Not Valid" : "Signing You In"}
+            </CardTitle>
+            <CardDescription className="text-center text-green-600 dark:text-green-300">
+              {error
+                ? "Sign-in links expire after a few minutes and work only once."
+                : "Please wait while we check your sign-in link..."}
+            </CardDescription>
+          </CardHeader>
+          {error && (
+            <>
+              <CardContent>
+                <AlertBox type="error" message={error} />
+              </CardContent>
+              <CardFooter className="justify-center">
+                <Link
+                  href="/auth/login"
+                  className="font-bold text-sm text-green-600 hover:text-green-800 dark:text-green-400 dark:hover:text-green-500"
+                >
+                  Back to Sign in
+                </Link>
+              </CardFooter>
+            </>
+          )}
+        </Card>
+      </motion.div>
+    </div>
+  );
+}
@@ -0,0 +1,157 @@
+"use client";
+
+import Link from "next/link";
+import { useEffect, useState } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import { motion } from "framer-motion";
+import { Button } from "@/components/ui/button";
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardFooter,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import { Input } from "@/components/ui/input";
+import { AlertBox } from "@/components/ui/alert";
+import { useLayout } from "@/components/ui/LayoutContext";
+import { safeReturnTo, storeAuthTokens } from "@/lib/auth";
+
+export default function TwoFactorPage() {
+  const router = useRouter();
+  const searchParams = useSearchParams();
+  const { setShowNavAndFooter } = useLayout();
+  const [code, setCode] = useState("");
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState("");
+
+  const mfaToken = searchParams.get("mfa_token") || "";
+  const returnTo = searchParams.get("return_to");
+
+  useEffect(() => {
+    setShowNavAndFooter(false);
+    return () => setShowNavAndFooter(true);
+  }, [setShowNavAndFooter]);
+
+  useEffect(() => {
+    if (!mfaToken) {
+      router.replace("/auth/login");
+    }
+  }, [mfaToken, router]);
+
+  const handleVerify = async () => {
+    setLoading(true);
+    setError("");
+
+    try {
+      const res = await fetch("http://localhost:8081/api/auth/login/2fa", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ mfa_token: mfaToken, code: code.trim() }),
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        throw new Error(data.message || "Verification failed");
+      }
+
+      storeAuthTokens(data);
+      router.replace(safeReturnTo(returnTo));
+    } catch (err: any) {
+      setError(err.message || "Verification failed. Please try again.");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="flex justify-center items-center min-h-screen bg-gradient-to-b from-transparent to-green-50 dark:to-green-950/20 py-10">
+      <motion.div
+        initial={{ opacity: 0, y: 20 }}
+        animate={{ opacity: 1, y: 0 }}
+        transition={{ duration: 1.0 }}
+        className="w-full max-w-lg mt-6"
+      >
+        <Card className="w-full max-w-md sm:max-w-lg lg:max-w-lg sm:p-6 shadow-lg border border-green-300 dark:border-green-700 dark:bg-gray-900">
+          <CardHeader className="space-y-1">
+            <CardTitle className="text-2xl text-center text-green-800 dark:text-green-400">
+              Two-Factor Authentication
+            </CardTitle>
+            <CardDescription className="text-center text-green-600 dark:text-green-300">
+              {useRecoveryCode
+                ? "Enter one of your recovery codes"
+                : "Enter the 6-digit code from your authenticator app"}
+            </CardDescription>
+          </CardHeader>
+
+          <CardContent className="space-y-4">
+            {error && (
+              <AlertBox
+                className="mb-2"
+                type="error"
+                message={error}
+                onClose={() => setError("")}
+              />
+            )}
+            <div className="space-y-2">
+              <label
+                className="text-sm font-medium text-green-700 dark:text-green-300"
+                htmlFor="code"
+              >
+                {useRecoveryCode ? "Recovery code" : "Verification code"}
+              </label>
+              <Input
+                id="code"
+                type="text"
+                autoComplete="one-time-code"
+                inputMode={useRecoveryCode ? "text" : "numeric"}
+                placeholder={useRecoveryCode ? "xxxxx-xxxxx" : "123456"}
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                onKeyDown={(e) => {
+                  if (e.key === "Enter" && code.trim()) handleVerify();
+                }}
+                className="border-green-200 focus:border-green-500 dark:bg-gray-800 dark:text-white"
+                disabled={loading}
+              />
+            </div>
+            <div className="text-center text-sm">
+              <Button
+                variant="link"
+                onClick={() => {
+                  setUseRecoveryCode(!useRecoveryCode);
+                  setCode("");
+                  setError("");
+                }}
+                className="text-green-600 hover:text-green-800 dark:text-green-400 dark:hover:text-green-500 p-0 h-auto"
+              >
+                {useRecoveryCode
+                  ? "Use your authenticator app instead"
+                  : "Lost your device? Use a recovery code"}
+              </Button>
+            </div>
+          </CardContent>
+          <CardFooter className="flex flex-col space-y-4">
+            <Button
+              onClick={handleVerify}
+              disabled={loading || !code.trim()}
+              className="w-full bg-green-600 hover:bg-green-700 dark:bg-green-500 dark:hover:bg-green-600"
+            >
+              {loading ? "Verifying..." : "Verify"}
+            </Button>
+            <div className="text-center text-sm text-green-600 dark:text-green-300">
+              <Link
+                href="/auth/login"
+                className="font-bold text-green-600 hover:text-green-800 dark:text-green-400 dark:hover:text-green-500"
+              >
+                Back to Sign in
+              </Link>
+            </div>
+          </CardFooter>
+        </Card>
+      </motion.div>
+    </div>
+  );
+}
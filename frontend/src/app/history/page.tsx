@@ -3,6 +3,7 @@
 import React, { useState, useEffect } from "react";
 import { motion } from "framer-motion";
 import Cookies from "js-cookie";
+import { authFetch } from "@/lib/auth";
 import HistoryList from "@/components/history/HistoryList";
 import HistoryDetails from "@/components/history/HistoryDetails";
 import ConfirmDelete from "@/components/history/ConfirmDelete";
@@ -57,11 +58,10 @@ export default function HistoryPage() {
           throw new Error("Authentication token not found");
         }
 
-        const response = await fetch("http://localhost:8081/api/history", {
+        const response = await authFetch("http://localhost:8081/api/history", {
           method: "GET",
           headers: {
             "Content-Type": "application/json",
-            Authorization: `Bearer ${token}`,
           },
         });
 
@@ -113,10 +113,9 @@ export default function HistoryPage() {
       if (!token) {
         throw new Error("Authentication token not found");
       }
-      const response = await fetch(`http://localhost:8081/api/history/${id}`, {
+      const response = await authFetch(`http://localhost:8081/api/history/${id}`, {
         method: "DELETE",
         headers: {
-          Authorization: `Bearer ${token}`,
           "Content-Type": "application/json",
         },
       });
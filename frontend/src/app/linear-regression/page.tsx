@@ -12,7 +12,7 @@ import { Loader2, Upload, Sparkles, Download, HelpCircle } from "lucide-react";
 import UploadBox from "@/components/linear-ui/UploadBox";
 import { Button } from "@/components/ui/button";
 import ResultBox from "@/components/linear-ui/ResultBox";
-import Cookies from "js-cookie";
+import { authFetch } from "@/lib/auth";
 import { toast } from "sonner";
 import { useSearchParams } from "next/navigation";
 
@@ -90,16 +90,9 @@ export default function LinearRegressionPage() {
     selectedFiles.forEach((file) => formData.append("file", file));
 
     try {
-      const token = Cookies.get("token");
-      const headers: HeadersInit = {};
-      if (token) {
-        headers["Authorization"] = `Bearer ${token}`;
-      }
-
-      const response = await fetch("http://localhost:8081/api/predict", {
+      const response = await authFetch("http://localhost:8081/api/predict", {
         method: "POST",
         body: formData,
-        headers,
       });
 
       if (!response.ok) {
@@ -0,0 +1,124 @@
+"use client";
+
+import Link from "next/link";
+import { useEffect, useRef, useState } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import { motion } from "framer-motion";
+import Cookies from "js-cookie";
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardFooter,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import { AlertBox } from "@/components/ui/alert";
+import { useLayout } from "@/components/ui/LayoutContext";
+import { authFetch } from "@/lib/auth";
+
+export default function AcceptInvitationPage() {
+  const router = useRouter();
+  const searchParams = useSearchParams();
+  const { setShowNavAndFooter } = useLayout();
+  const [error, setError] = useState("");
+  const [accepted, setAccepted] = useState(false);
+  // The invitation works only once, so it must not be sent twice
+  const submitted = useRef(false);
+
+  useEffect(() => {
+    setShowNavAndFooter(false);
+    return () => setShowNavAndFooter(true);
+  }, [setShowNavAndFooter]);
+
+  useEffect(() => {
+    if (submitted.current) return;
+    submitted.current = true;
+
+    const token = searchParams.get("token");
+    if (!token) {
+      setError(
+        "This invitation link is incomplete. Ask for a new invitation."
+      );
+      return;
+    }
+
+    // Invitations are accepted by a signed-in account, so sign in first and
+    // come back to this link
+    if (!Cookies.get("token") && !Cookies.get("refresh_token")) {
+      const returnTo = `/orgs/invitations/accept?token=${encodeURIComponent(
+        token
+      )}`;
+      router.replace(`/auth/login?return_to=${encodeURIComponent(returnTo)}`);
+      return;
+    }
+
+    const accept = async () => {
+      try {
+        const res = await authFetch(
+          "http://localhost:8081/api/orgs/invitations/accept",
+          {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ token }),
+          }
+        );
+
+        const data = await res.json();
+        if (!res.ok) {
+          throw new Error(data.message || "Could not accept the invitation");
+        }
+        setAccepted(true);
+      } catch (err: any) {
+        setError(err.message || "Could not accept the invitation");
+      }
+    };
+
+    accept();
+  }, [router, searchParams]);
+
+  return (
+    <div className="flex justify-center items-center min-h-screen bg-gradient-to-b from-transparent to-green-50 dark:to-green-950/20 py-10">
+      <motion.div
+        initial={{ opacity: 0, y: 20 }}
+        animate={{ opacity: 1, y: 0 }}
+        transition={{ duration: 0.5 }}
+        className="w-full max-w-md px-4"
+      >
+        <Card className="w-full shadow-lg border border-green-300 dark:border-green-700 dark:bg-gray-900">
+          <CardHeader className="space-y-1">
+            <CardTitle className="text-2xl text-center text-green-800 dark:text-green-400">
+              {error
+                ? "Invitation Not Valid"
+                : accepted
+                ? "Invitation Accepted"
+                : "Joining Organization"}
+            </CardTitle>
+            <CardDescription className="text-center text-green-600 dark:text-green-300">
+              {error
+                ? "Invitations expire and work only once, for the email they were sent to."
+                : accepted
+                ? "You are now a member of the organization."
+                : "Please wait while we check your invitation..."}
+            </CardDescription>
+          </CardHeader>
+          {error && (
+            <CardContent>
+              <AlertBox type="error" message={error} />
+            </CardContent>
+          )}
+          {(error || accepted) && (
+            <CardFooter className="justify-center">
+              <Link
+                href="/"
+                className="font-bold text-sm text-green-600 hover:text-green-800 dark:text-green-400 dark:hover:text-green-500"
+              >
+                Go to Home
+              </Link>
+            </CardFooter>
+          )}
+        </Card>
+      </motion.div>
+    </div>
+  );
+}
@@ -3,6 +3,7 @@
 import React, { useState, useEffect } from "react";
 import { AnimatePresence, motion } from "framer-motion";
 import Cookies from "js-cookie";
+import { authFetch } from "@/lib/auth";
 import { Button } from "@/components/ui/button";
 import { ProfileCard } from "@/components/profile/ProfileCard";
 import { useAuth } from "@/components/ui/authcontext";
@@ -64,11 +65,10 @@ export default function ProfilePage() {
           throw new Error("No authentication token found");
         }
 
-        const response = await fetch(
+        const response = await authFetch(
           `http://localhost:8081/api/user/${user.user_id}`,
           {
             headers: {
-              Authorization: `Bearer ${token}`,
               "Content-Type": "application/json",
             },
             credentials: "include",
@@ -187,12 +187,11 @@ export default function ProfilePage() {
       if (!user?.user_id) {
         throw new Error("User ID is missing");
       }
-      const profileResponse = await fetch(
+      const profileResponse = await authFetch(
         `http://localhost:8081/api/user/${user.user_id}`,
         {
           method: "PATCH",
           headers: {
-            Authorization: `Bearer ${token}`,
             "Content-Type": "application/json",
           },
           body: JSON.stringify({
@@ -221,13 +220,10 @@ export default function ProfilePage() {
         const formDataUpload = new FormData();
         formDataUpload.append("avatar", avatarFile);
         console.log("Uploading avatar");
-        const avatarResponse = await fetch(
+        const avatarResponse = await authFetch(
           "http://localhost:8081/api/user/avatar",
           {
             method: "POST",
-            headers: {
-              Authorization: `Bearer ${token}`,
-            },
             body: formDataUpload,
             credentials: "include",
           }
@@ -1,6 +1,8 @@
 "use client";
 
 import { createContext, useContext, useEffect, useState } from "react";
+import Cookies from "js-cookie";
+import { authFetch } from "@/lib/auth";
 
 type User = {
   user_id: string;
@@ -30,21 +32,16 @@ export const AuthProvider = ({ children }: { children: React.ReactNode }) => {
   const [loading, setLoading] = useState(true);
 
   const fetchUser = async () => {
-    const token = document.cookie
-      .split("; ")
-      .find((row) => row.startsWith("token="))
-      ?.split("=")[1];
-
-    if (!token) {
+    // An expired access token is renewed by authFetch
+    if (!Cookies.get("token") && !Cookies.get("refresh_token")) {
       setUser(null);
       setLoading(false);
       return;
     }
 
     try {
-      const res = await fetch("http://localhost:8081/api/auth/user", {
+      const res = await authFetch("http://localhost:8081/api/auth/user", {
         headers: {
-          Authorization: `Bearer ${token}`,
           "Content-Type": "application/json",
         },
         credentials: "include",
@@ -7,6 +7,7 @@ import { Button } from "@/components/ui/button";
 import Cookies from "js-cookie";
 import Link from "next/link";
 import { useAuth } from "@/components/ui/authcontext";
+import { authFetch, clearAuthTokens } from "@/lib/auth";
 
 interface UserMenuProps {
   onClose: () => void;
@@ -38,25 +39,31 @@ const UserMenu: React.FC<UserMenuProps> = ({ onClose }) => {
     try {
       const token = Cookies.get("token");
       if (token) {
-        const response = await fetch("http://localhost:8081/api/auth/logout", {
-          method: "POST",
-          headers: {
-            Authorization: `Bearer ${token}`,
-            "Content-Type": "application/json",
-          },
-        });
+        // Sending the refresh token ends its session as well
+        const response = await authFetch(
+          "http://localhost:8081/api/auth/logout",
+          {
+            method: "POST",
+            headers: {
+              "Content-Type": "application/json",
+            },
+            body: JSON.stringify({
+              refresh_token: Cookies.get("refresh_token"),
+            }),
+          }
+        );
 
         if (!response.ok) {
           throw new Error("Logout failed");
         }
       }
 
-      Cookies.remove("token");
+      clearAuthTokens();
       onClose();
       window.location.href = "/";
     } catch (error) {
       console.error("Logout error:", error);
-      Cookies.remove("token");
+      clearAuthTokens();
       onClose();
       window.location.href = "/";
     }
@@ -0,0 +1,100 @@
+import Cookies from "js-cookie";
+
+const API_URL = "http://localhost:8081";
+
+// Access tokens expire after minutes; the cookies stay as long as the user
+// remains signed in, and the refresh token renews the access token
+const SESSION_DAYS = 7;
+
+type AuthTokens = {
+  token: string;
+  refresh_token?: string;
+};
+
+// storeAuthTokens keeps the tokens from a login or refresh response
+export const storeAuthTokens = (data: AuthTokens) => {
+  const options = {
+    expires: SESSION_DAYS,
+    secure: true,
+    sameSite: "Strict" as const,
+  };
+  Cookies.set("token", data.token, options);
+  if (data.refresh_token) {
+    Cookies.set("refresh_token", data.refresh_token, options);
+  }
+};
+
+export const clearAuthTokens = () => {
+  Cookies.remove("token");
+  Cookies.remove("refresh_token");
+};
+
+let refreshing: Promise<string | null> | null = null;
+
+// refreshAccessToken trades the refresh token for a new pair and returns the
+// new access token, or null when the session is over. Refresh tokens rotate
+// on every use and reusing an old one revokes the session, so concurrent
+// callers share a single request.
+export const refreshAccessToken = () => {
+  if (!refreshing) {
+    refreshing = (async () => {
+      try {
+        // OAuth logins keep the refresh token in an httpOnly cookie instead
+        const refreshToken = Cookies.get("refresh_token");
+        const res = await fetch(`${API_URL}/api/auth/refresh`, {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify(
+            refreshToken ? { refresh_token: refreshToken } : {}
+          ),
+          credentials: "include",
+        });
+        if (!res.ok) {
+          clearAuthTokens();
+          return null;
+        }
+        const data = await res.json();
+        storeAuthTokens(data);
+        return data.token as string;
+      } catch (err) {
+        console.error("Token refresh error:", err);
+        return null;
+      } finally {
+        refreshing = null;
+      }
+    })();
+  }
+  return refreshing;
+};
+
+// authFetch calls the API with the access token. When the token has expired
+// it is refreshed once and the request is retried.
+export const authFetch = async (input: string, init: RequestInit = {}) => {
+  const send = (token?: string) => {
+    const headers = new Headers(init.headers);
+    if (token) {
+      headers.set("Authorization", `Bearer ${token}`);
+    }
+    return fetch(input, { ...init, headers });
+  };
+
+  const res = await send(Cookies.get("token"));
+  if (res.status !== 401) {
+    return res;
+  }
+  const token = await refreshAccessToken();
+  return token ? send(token) : res;
+};
+
+// safeReturnTo keeps a post-login redirect on this site; anything else goes
+// to the home page
+export const safeReturnTo = (returnTo: string | null | undefined) => {
+  if (!returnTo) return "/";
+  try {
+    const target = new URL(returnTo, window.location.origin);
+    if (target.origin !== window.location.origin) return "/";
+    return target.pathname + target.search + target.hash;
+  } catch {
+    return "/";
+  }
+};
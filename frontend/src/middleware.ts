@@ -1,6 +1,16 @@
 import { NextResponse } from 'next/server';
 import type { NextRequest } from 'next/server';
 
+// Pages opened from an email link or an OAuth redirect arrive without a
+// same-origin referer
+const EXTERNAL_ENTRY_PAGES = [
+  '/auth/magic-link',
+  '/auth/two-factor',
+  '/auth/link-account',
+  '/orgs/invitations/accept',
+  '/account/exports/download',
+];
+
 export function middleware(request: NextRequest) {
   // Get the current pathname
   const pathname = request.nextUrl.pathname;
@@ -10,7 +20,8 @@ export function middleware(request: NextRequest) {
     pathname.startsWith('/api') ||
     pathname.startsWith('/_next/static') ||
     pathname.startsWith('/_next/image') ||
-    pathname === '/favicon.ico'
+    pathname === '/favicon.ico' ||
+    EXTERNAL_ENTRY_PAGES.includes(pathname)
   ) {
     return NextResponse.next();
   }